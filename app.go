@@ -38,3 +38,10 @@ func (a *App) Dispatch(router string, payload string) string {
 	}
 	return resp
 }
+
+// DispatchBatch runs several routes in one call. payload is a JSON
+// dispatcher.BatchRequest; with "atomic": true all calls share one transaction.
+func (a *App) DispatchBatch(payload string) string {
+	resp, _ := a.dispatcher.DispatchBatchJSON(a.ctx, []byte(payload))
+	return resp
+}
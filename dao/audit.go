@@ -0,0 +1,89 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditLog 为一次修改操作的审计记录，表上有触发器禁止更新与删除（见 migrations/0002_audit_log.sql）
+type AuditLog struct {
+	ID         uint      `gorm:"column:id;primaryKey"`
+	CreatedAt  time.Time `gorm:"column:created_at;not null;comment:'操作时间'"`
+	Operation  string    `gorm:"column:operation;not null;comment:'操作，如 record:activate'"`
+	Entity     string    `gorm:"column:entity;not null;comment:'实体类型'"`
+	EntityID   uint      `gorm:"column:entity_id;not null;comment:'实体主键'"`
+	BeforeJSON string    `gorm:"column:before_json;comment:'修改前 JSON，新建时为空'"`
+	AfterJSON  string    `gorm:"column:after_json;comment:'修改后 JSON，删除时为空'"`
+	RequestID  string    `gorm:"column:request_id;comment:'请求 ID'"`
+	Route      string    `gorm:"column:route;comment:'触发修改的路由'"`
+	Actor      string    `gorm:"column:actor;comment:'操作人登录名'"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
+
+// AuditFilter 为审计日志的查询条件，零值字段不参与过滤
+type AuditFilter struct {
+	Operation string
+	Entity    string
+	EntityID  uint
+	Actor     string
+	// StartDate / EndDate 为 YYYY-MM-DD，包含当天
+	StartDate string
+	EndDate   string
+}
+
+type AuditDAO interface {
+	CreateAuditLog(ctx context.Context, log *AuditLog) error
+	QueryAuditLogs(ctx context.Context, filter AuditFilter, offset int, limit int) ([]AuditLog, int64, error)
+}
+
+func NewAuditDao(db *gorm.DB) AuditDAO {
+	return &AuditGormDAO{db: db}
+}
+
+type AuditGormDAO struct {
+	db *gorm.DB
+}
+
+func (a *AuditGormDAO) CreateAuditLog(ctx context.Context, log *AuditLog) error {
+	return gorm.G[AuditLog](conn(ctx, a.db)).Create(ctx, log)
+}
+
+func (a *AuditGormDAO) QueryAuditLogs(ctx context.Context, filter AuditFilter, offset int, limit int) ([]AuditLog, int64, error) {
+	query := gorm.G[AuditLog](conn(ctx, a.db)).Where("")
+	if filter.Operation != "" {
+		query = query.Where("operation = ?", filter.Operation)
+	}
+	if filter.Entity != "" {
+		query = query.Where("entity = ?", filter.Entity)
+	}
+	if filter.EntityID != 0 {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.StartDate != "" {
+		query = query.Where("created_at >= ?", filter.StartDate)
+	}
+	if filter.EndDate != "" {
+		query = query.Where("created_at <= ?", filter.EndDate+" 23:59:59.999")
+	}
+
+	total, err := query.Count(ctx, "*")
+	if err != nil {
+		return nil, 0, err
+	}
+	if limit > 0 {
+		query = query.Offset(offset).Limit(limit)
+	}
+	logs, err := query.Order("id DESC").Find(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
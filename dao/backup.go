@@ -0,0 +1,186 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// 备份文件的种类，体现在文件名中：<库名>.<种类>.<时间>.db
+const (
+	BackupKindSnapshot   = "snapshot"
+	BackupKindPreRestore = "pre-restore"
+	// 迁移前的备份种类为 pre-vNNNN，NNNN 为迁移的目标版本
+	backupKindPreMigrate = "pre-v%04d"
+)
+
+const backupTimeLayout = "20060102-150405"
+
+// BackupFileName 返回 dbPath 对应数据库某一种类备份的文件名
+func BackupFileName(dbPath string, kind string, t time.Time) string {
+	base := strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath))
+	return fmt.Sprintf("%s.%s.%s.db", base, kind, t.Format(backupTimeLayout))
+}
+
+// ParseBackupFileName 解析 BackupFileName 生成的文件名，返回种类和备份时间
+func ParseBackupFileName(dbPath string, name string) (kind string, t time.Time, ok bool) {
+	base := strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath))
+	rest, ok := strings.CutPrefix(name, base+".")
+	if !ok {
+		return "", time.Time{}, false
+	}
+	rest, ok = strings.CutSuffix(rest, ".db")
+	if !ok {
+		return "", time.Time{}, false
+	}
+	i := strings.LastIndex(rest, ".")
+	if i <= 0 {
+		return "", time.Time{}, false
+	}
+	t, err := time.ParseInLocation(backupTimeLayout, rest[i+1:], time.Local)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return rest[:i], t, true
+}
+
+// Backup 使用 VACUUM INTO 将数据库在线写出为一致的快照文件
+func Backup(db *gorm.DB, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup file %s already exists", dest)
+	}
+	return db.Exec("VACUUM INTO ?", dest).Error
+}
+
+// CheckBackup 以只读方式打开备份文件，执行完整性检查并返回其迁移版本。
+// 版本高于当前程序支持的备份返回 ErrSchemaTooNew。
+func CheckBackup(ctx context.Context, path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+	src, err := sql.Open("sqlite3", path+"?_query_only=1")
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	var result string
+	if err := src.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&result); err != nil {
+		return 0, fmt.Errorf("integrity check: %w", err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("integrity check failed: %s", result)
+	}
+
+	var tables int
+	if err := src.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('students', 'records')").Scan(&tables); err != nil {
+		return 0, err
+	}
+	if tables != 2 {
+		return 0, errors.New("not a teaching_manage database")
+	}
+
+	// 由 AutoMigrate 创建的旧库没有 schema_migrations，视为版本 0
+	var version int
+	err = src.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil && !strings.Contains(err.Error(), "no such table") {
+		return 0, err
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+	if version > len(migrations) {
+		return version, fmt.Errorf("%w: backup is at version %d, this build supports up to %d", ErrSchemaTooNew, version, len(migrations))
+	}
+	return version, nil
+}
+
+// Restore 用 SQLite 在线备份 API 把 src 的内容整体复制到正在使用的数据库中。
+// 复制在目标库的单个连接上完成，期间其他查询会等待；调用方应先用 CheckBackup 校验 src。
+func Restore(ctx context.Context, db *gorm.DB, src string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	dst, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	srcDB, err := sql.Open("sqlite3", src+"?_query_only=1")
+	if err != nil {
+		return err
+	}
+	defer srcDB.Close()
+	srcConn, err := srcDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return dst.Raw(func(dc any) error {
+		return srcConn.Raw(func(sc any) error {
+			d, ok1 := dc.(*sqlite3.SQLiteConn)
+			s, ok2 := sc.(*sqlite3.SQLiteConn)
+			if !ok1 || !ok2 {
+				return errors.New("restore requires the sqlite3 driver")
+			}
+			bk, err := d.Backup("main", s, "main")
+			if err != nil {
+				return err
+			}
+			for {
+				done, err := bk.Step(-1)
+				if err != nil {
+					bk.Finish()
+					return err
+				}
+				if done {
+					break
+				}
+			}
+			return bk.Finish()
+		})
+	})
+}
+
+// ReplaceTableFrom 用 src 数据库中 table 表的全部行替换当前数据库中该表的内容，只复制两边都有的列。
+// 恢复备份后用它保留不应随备份回退的数据，如登录账号
+func ReplaceTableFrom(ctx context.Context, db *gorm.DB, src string, table string) error {
+	// ATTACH 不能在事务中执行，且只对当前连接有效
+	return db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("ATTACH DATABASE ? AS replace_src", src).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("DETACH DATABASE replace_src")
+
+		var columns []string
+		if err := conn.Raw("SELECT d.name FROM pragma_table_info(?, 'main') AS d JOIN pragma_table_info(?, 'replace_src') AS s ON s.name = d.name ORDER BY d.cid",
+			table, table).Scan(&columns).Error; err != nil {
+			return err
+		}
+		if len(columns) == 0 {
+			return fmt.Errorf("table %s not found in %s", table, src)
+		}
+		list := "`" + strings.Join(columns, "`,`") + "`"
+		return conn.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(fmt.Sprintf("DELETE FROM main.`%s`", table)).Error; err != nil {
+				return err
+			}
+			return tx.Exec(fmt.Sprintf("INSERT INTO main.`%[1]s` (%[2]s) SELECT %[2]s FROM replace_src.`%[1]s`", table, list)).Error
+		})
+	})
+}
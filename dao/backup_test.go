@@ -0,0 +1,153 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestParseBackupFileName(t *testing.T) {
+	at := time.Date(2025, 3, 1, 14, 5, 9, 0, time.Local)
+	tests := []struct {
+		name     string
+		dbPath   string
+		file     string
+		wantKind string
+		wantOK   bool
+	}{
+		{name: "snapshot", dbPath: "/data/teaching.db", file: BackupFileName("/data/teaching.db", BackupKindSnapshot, at), wantKind: BackupKindSnapshot, wantOK: true},
+		{name: "pre-restore", dbPath: "teaching.db", file: "teaching.pre-restore.20250301-140509.db", wantKind: BackupKindPreRestore, wantOK: true},
+		{name: "pre-migrate", dbPath: "teaching.db", file: "teaching.pre-v0007.20250301-140509.db", wantKind: "pre-v0007", wantOK: true},
+		{name: "db path without extension", dbPath: "data/teaching", file: "teaching.snapshot.20250301-140509.db", wantKind: BackupKindSnapshot, wantOK: true},
+		{name: "dotted db name", dbPath: "my.school.db", file: "my.school.snapshot.20250301-140509.db", wantKind: BackupKindSnapshot, wantOK: true},
+		{name: "other database", dbPath: "teaching.db", file: "other.snapshot.20250301-140509.db"},
+		{name: "missing .db suffix", dbPath: "teaching.db", file: "teaching.snapshot.20250301-140509"},
+		{name: "missing kind", dbPath: "teaching.db", file: "teaching.20250301-140509.db"},
+		{name: "empty kind", dbPath: "teaching.db", file: "teaching..20250301-140509.db"},
+		{name: "invalid time", dbPath: "teaching.db", file: "teaching.snapshot.20251301-140509.db"},
+		{name: "time in another layout", dbPath: "teaching.db", file: "teaching.snapshot.2025-03-01.db"},
+		{name: "database itself", dbPath: "teaching.db", file: "teaching.db"},
+		{name: "wal file", dbPath: "teaching.db", file: "teaching.snapshot.20250301-140509.db-wal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, got, ok := ParseBackupFileName(tt.dbPath, tt.file)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseBackupFileName(%q, %q) ok = %v, want %v", tt.dbPath, tt.file, ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if kind != tt.wantKind {
+				t.Fatalf("ParseBackupFileName(%q, %q) kind = %q, want %q", tt.dbPath, tt.file, kind, tt.wantKind)
+			}
+			if !got.Equal(at) {
+				t.Fatalf("ParseBackupFileName(%q, %q) time = %v, want %v", tt.dbPath, tt.file, got, at)
+			}
+		})
+	}
+}
+
+func TestCheckBackup(t *testing.T) {
+	ctx := context.Background()
+	db, path, backupDir := openMigrateTestDB(t)
+	if err := Migrate(db, path, backupDir); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations() error = %v", err)
+	}
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.db")
+	if err := Backup(db, valid); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	content, err := os.ReadFile(valid)
+	if err != nil {
+		t.Fatalf("read backup: %v", err)
+	}
+
+	write := func(name string, data []byte) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		return p
+	}
+	// 保留 SQLite 文件头但截断数据页
+	truncated := write("truncated.db", content[:len(content)/2])
+	// 破坏第二页之后的内容，文件头完整
+	damaged := append([]byte{}, content...)
+	for i := 4096; i < len(damaged); i++ {
+		damaged[i] = 0xAB
+	}
+	corrupt := write("corrupt.db", damaged)
+	notSQLite := write("text.db", []byte("this is not a database"))
+	empty := write("empty.db", nil)
+
+	other := filepath.Join(dir, "other.db")
+	otherDB, err := gorm.Open(sqlite.Open(other), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open other db: %v", err)
+	}
+	if err := otherDB.Exec("CREATE TABLE notes (id integer PRIMARY KEY, body text)").Error; err != nil {
+		t.Fatalf("create other table: %v", err)
+	}
+	if sqlDB, err := otherDB.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	newer := filepath.Join(dir, "newer.db")
+	if err := Backup(db, newer); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	newerDB, err := gorm.Open(sqlite.Open(newer), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open newer db: %v", err)
+	}
+	if err := newerDB.Create(&SchemaMigration{Version: len(migrations) + 1, Name: "future", AppliedAt: time.Now()}).Error; err != nil {
+		t.Fatalf("record future migration: %v", err)
+	}
+	if sqlDB, err := newerDB.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		wantVersion int
+		wantErr     bool
+		wantTooNew  bool
+	}{
+		{name: "valid backup", path: valid, wantVersion: len(migrations)},
+		{name: "missing file", path: filepath.Join(dir, "missing.db"), wantErr: true},
+		{name: "truncated file", path: truncated, wantErr: true},
+		{name: "corrupt pages", path: corrupt, wantErr: true},
+		{name: "not a sqlite file", path: notSQLite, wantErr: true},
+		{name: "empty file", path: empty, wantErr: true},
+		{name: "other sqlite database", path: other, wantErr: true},
+		{name: "newer schema", path: newer, wantVersion: len(migrations) + 1, wantErr: true, wantTooNew: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := CheckBackup(ctx, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckBackup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrSchemaTooNew) != tt.wantTooNew {
+				t.Fatalf("CheckBackup() error = %v, want ErrSchemaTooNew %v", err, tt.wantTooNew)
+			}
+			if version != tt.wantVersion {
+				t.Fatalf("CheckBackup() version = %d, want %d", version, tt.wantVersion)
+			}
+		})
+	}
+}
@@ -13,7 +13,8 @@ var global_db *gorm.DB
 var ErrDuplicatedKey = gorm.ErrDuplicatedKey
 var ErrRecordNotFound = gorm.ErrRecordNotFound
 
-func InitDB(path string) error {
+// InitDB 打开 path 处的数据库并执行迁移，迁移前的备份写入 backupDir
+func InitDB(path string, backupDir string) error {
 	// 确保数据库目录存在
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return err
@@ -40,8 +41,8 @@ func InitDB(path string) error {
 	sqlDB.SetMaxIdleConns(1)
 	sqlDB.SetConnMaxLifetime(0)
 
-	// 生产环境建议使用版本化迁移工具；AutoMigrate 可用于开发/快速原型
-	if err := db.AutoMigrate(&Student{}, &Teacher{}, &Order{}, &Record{}); err != nil {
+	// 版本化迁移
+	if err := Migrate(db, path, backupDir); err != nil {
 		return err
 	}
 	global_db = db
@@ -0,0 +1,153 @@
+package dao
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"teaching_manage/pkg/logger"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 迁移脚本按 NNNN_name.sql 命名，版本号从 1 开始连续递增；已发布的脚本不可再修改。
+//
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// ErrSchemaTooNew 表示数据库已被更新版本的程序迁移过，当前程序不能安全地使用它。
+var ErrSchemaTooNew = errors.New("database schema is newer than this build")
+
+// Migration 是一个编号的向上迁移脚本
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// SchemaMigration 记录已执行的迁移
+type SchemaMigration struct {
+	Version   int       `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;not null"`
+	AppliedAt time.Time `gorm:"column:applied_at;not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrations 返回内嵌的全部迁移，按版本号升序排列
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]Migration, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		num, rest, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+		content, err := migrationFS.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: rest, SQL: string(content)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must be consecutive, missing version %d", i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// SchemaVersion 返回数据库当前的迁移版本，未迁移过的数据库为 0
+func SchemaVersion(db *gorm.DB) (int, error) {
+	var version int
+	err := db.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// Migrate 将 dbPath 对应的数据库迁移到最新版本。
+// 数据库版本高于程序已知的最新版本时返回 ErrSchemaTooNew；
+// 已有数据的数据库在执行迁移前会先备份到 backupDir。
+func Migrate(db *gorm.DB, dbPath string, backupDir string) error {
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	return migrate(db, migrations, dbPath, backupDir)
+}
+
+// migrate 依次执行 migrations 中尚未执行的迁移，每个迁移在单独的事务中执行，失败时回滚该迁移并停止
+func migrate(db *gorm.DB, migrations []Migration, dbPath string, backupDir string) error {
+	latest := len(migrations)
+
+	// 迁移前记录库里是否已有业务表，全新数据库无需备份
+	var tables int64
+	if err := db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name <> ?",
+		SchemaMigration{}.TableName()).Scan(&tables).Error; err != nil {
+		return err
+	}
+
+	if err := db.Exec("CREATE TABLE IF NOT EXISTS `schema_migrations` (`version` integer PRIMARY KEY,`name` text NOT NULL,`applied_at` datetime NOT NULL)").Error; err != nil {
+		return err
+	}
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if current > latest {
+		return fmt.Errorf("%w: database is at version %d, this build supports up to %d", ErrSchemaTooNew, current, latest)
+	}
+	if current == latest {
+		logger.Debug("数据库结构已是最新", logger.Int("version", current))
+		return nil
+	}
+
+	if tables > 0 {
+		dest := filepath.Join(backupDir, BackupFileName(dbPath, fmt.Sprintf(backupKindPreMigrate, latest), time.Now()))
+		if err := Backup(db, dest); err != nil {
+			return fmt.Errorf("backup before migration: %w", err)
+		}
+		logger.Info("迁移前已备份数据库", logger.String("path", dest))
+	}
+
+	// 重建表时需要关闭外键约束，且该 PRAGMA 在事务内无效；
+	// 每个迁移提交前用 foreign_key_check 确认没有破坏引用完整性。
+	if err := db.Exec("PRAGMA foreign_keys = OFF").Error; err != nil {
+		return err
+	}
+	defer db.Exec("PRAGMA foreign_keys = ON")
+
+	for _, m := range migrations[current:] {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(m.SQL).Error; err != nil {
+				return err
+			}
+			var violations []map[string]any
+			if err := tx.Raw("PRAGMA foreign_key_check").Scan(&violations).Error; err != nil {
+				return err
+			}
+			if len(violations) > 0 {
+				return fmt.Errorf("foreign key check failed: %v", violations)
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("apply migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		logger.Info("已执行数据库迁移", logger.Int("version", m.Version), logger.String("name", m.Name))
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package dao
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// openMigrateTestDB 以与 InitDB 相同的方式打开一个空的临时数据库，但不执行迁移
+func openMigrateTestDB(t *testing.T) (*gorm.DB, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	db, err := gorm.Open(sqlite.Open(path+"?_foreign_keys=1"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("db handle: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db, path, filepath.Join(dir, "backups")
+}
+
+func appliedMigrations(t *testing.T, db *gorm.DB) []SchemaMigration {
+	t.Helper()
+	var applied []SchemaMigration
+	if err := db.Order("version").Find(&applied).Error; err != nil {
+		t.Fatalf("read schema_migrations: %v", err)
+	}
+	return applied
+}
+
+func tableExists(t *testing.T, db *gorm.DB, name string) bool {
+	t.Helper()
+	var n int64
+	if err := db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n).Error; err != nil {
+		t.Fatalf("check table %s: %v", name, err)
+	}
+	return n > 0
+}
+
+func backupFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("read backup dir: %v", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations() error = %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatalf("Migrations() returned no migrations")
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 || m.Name == "" || strings.TrimSpace(m.SQL) == "" {
+			t.Fatalf("migration %d = {%d %q}, want consecutive versions with a name and SQL", i, m.Version, m.Name)
+		}
+	}
+}
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	db, path, backupDir := openMigrateTestDB(t)
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations() error = %v", err)
+	}
+
+	if err := Migrate(db, path, backupDir); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	version, err := SchemaVersion(db)
+	if err != nil {
+		t.Fatalf("SchemaVersion() error = %v", err)
+	}
+	if version != len(migrations) {
+		t.Fatalf("SchemaVersion() = %d, want %d", version, len(migrations))
+	}
+	applied := appliedMigrations(t, db)
+	if len(applied) != len(migrations) {
+		t.Fatalf("%d migrations recorded, want %d", len(applied), len(migrations))
+	}
+	for i, m := range applied {
+		if m.Version != migrations[i].Version || m.Name != migrations[i].Name {
+			t.Fatalf("recorded migration %d = {%d %q}, want {%d %q}", i, m.Version, m.Name, migrations[i].Version, migrations[i].Name)
+		}
+	}
+	for _, table := range []string{"teachers", "students", "records", "users", "class_sessions"} {
+		if !tableExists(t, db, table) {
+			t.Fatalf("table %s missing after migration", table)
+		}
+	}
+	if files := backupFiles(t, backupDir); len(files) != 0 {
+		t.Fatalf("fresh database was backed up: %v", files)
+	}
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	db, path, backupDir := openMigrateTestDB(t)
+	if err := Migrate(db, path, backupDir); err != nil {
+		t.Fatalf("first Migrate() error = %v", err)
+	}
+	if err := db.Exec("INSERT INTO teachers (name, created_at, updated_at) VALUES ('王老师', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)").Error; err != nil {
+		t.Fatalf("insert teacher: %v", err)
+	}
+	before := appliedMigrations(t, db)
+
+	if err := Migrate(db, path, backupDir); err != nil {
+		t.Fatalf("second Migrate() error = %v", err)
+	}
+	after := appliedMigrations(t, db)
+	if len(after) != len(before) {
+		t.Fatalf("%d migrations recorded after re-run, want %d", len(after), len(before))
+	}
+	for i := range after {
+		if !after[i].AppliedAt.Equal(before[i].AppliedAt) {
+			t.Fatalf("migration %d was applied again", after[i].Version)
+		}
+	}
+	var teachers int64
+	if err := db.Table("teachers").Count(&teachers).Error; err != nil {
+		t.Fatalf("count teachers: %v", err)
+	}
+	if teachers != 1 {
+		t.Fatalf("%d teachers after re-run, want 1", teachers)
+	}
+	if files := backupFiles(t, backupDir); len(files) != 0 {
+		t.Fatalf("up-to-date database was backed up: %v", files)
+	}
+}
+
+func TestMigrateFailureRollsBack(t *testing.T) {
+	db, path, backupDir := openMigrateTestDB(t)
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations() error = %v", err)
+	}
+	if err := Migrate(db, path, backupDir); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	before := appliedMigrations(t, db)
+
+	latest := len(migrations)
+	broken := append(append([]Migration{}, migrations...),
+		Migration{Version: latest + 1, Name: "broken", SQL: "CREATE TABLE `broken_new` (`id` integer PRIMARY KEY);\n" +
+			"ALTER TABLE `records` ADD COLUMN `broken_col` text;\n" +
+			"INSERT INTO `no_such_table` VALUES (1);"},
+		Migration{Version: latest + 2, Name: "after_broken", SQL: "CREATE TABLE `after_broken` (`id` integer PRIMARY KEY);"},
+	)
+	err = migrate(db, broken, path, backupDir)
+	if err == nil {
+		t.Fatalf("migrate() with a failing migration returned no error")
+	}
+	if !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("migrate() error = %v, want it to name the failing migration", err)
+	}
+
+	after := appliedMigrations(t, db)
+	if len(after) != len(before) {
+		t.Fatalf("%d migrations recorded after failure, want %d", len(after), len(before))
+	}
+	if version, _ := SchemaVersion(db); version != latest {
+		t.Fatalf("SchemaVersion() = %d after failure, want %d", version, latest)
+	}
+	for _, table := range []string{"broken_new", "after_broken"} {
+		if tableExists(t, db, table) {
+			t.Fatalf("table %s exists after failed migration", table)
+		}
+	}
+	var columns int64
+	if err := db.Raw("SELECT COUNT(*) FROM pragma_table_info('records') WHERE name = 'broken_col'").Scan(&columns).Error; err != nil {
+		t.Fatalf("read records columns: %v", err)
+	}
+	if columns != 0 {
+		t.Fatalf("records.broken_col exists after failed migration")
+	}
+	// 已有业务表的数据库在迁移前会备份
+	if files := backupFiles(t, backupDir); len(files) != 1 {
+		t.Fatalf("backups = %v, want one pre-migration backup", files)
+	}
+}
+
+func TestMigrateSchemaTooNew(t *testing.T) {
+	db, path, backupDir := openMigrateTestDB(t)
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations() error = %v", err)
+	}
+	if err := Migrate(db, path, backupDir); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if err := migrate(db, migrations[:len(migrations)-1], path, backupDir); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("migrate() with fewer migrations error = %v, want ErrSchemaTooNew", err)
+	}
+}
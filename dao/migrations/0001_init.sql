@@ -0,0 +1,20 @@
+-- 初始表结构，与此前 AutoMigrate 生成的结构一致；
+-- 使用 IF NOT EXISTS 以便接管由 AutoMigrate 创建的旧数据库。
+CREATE TABLE IF NOT EXISTS `teachers` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`name` text NOT NULL,`gender` text,`phone` text,`remark` text,CONSTRAINT `uni_teachers_name` UNIQUE (`name`));
+CREATE INDEX IF NOT EXISTS `idx_teachers_name` ON `teachers`(`name`);
+CREATE INDEX IF NOT EXISTS `idx_teachers_deleted_at` ON `teachers`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `students` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`name` text NOT NULL,`gender` text,`hours` integer DEFAULT 0,`phone` text,`teacher_id` integer NOT NULL,`remark` text,CONSTRAINT `fk_students_teacher` FOREIGN KEY (`teacher_id`) REFERENCES `teachers`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE,CONSTRAINT `uni_students_name` UNIQUE (`name`));
+CREATE INDEX IF NOT EXISTS `idx_students_name` ON `students`(`name`);
+CREATE INDEX IF NOT EXISTS `idx_students_deleted_at` ON `students`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `orders` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`student_id` integer,`hours` int(11) NOT NULL,`comment` varchar(50),`active` numeric NOT NULL DEFAULT true,CONSTRAINT `fk_orders_student` FOREIGN KEY (`student_id`) REFERENCES `students`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE);
+CREATE INDEX IF NOT EXISTS `idx_orders_student_id` ON `orders`(`student_id`);
+CREATE INDEX IF NOT EXISTS `idx_orders_deleted_at` ON `orders`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `records` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`student_id` integer NOT NULL,`teacher_id` integer NOT NULL,`teaching_date` date NOT NULL,`teaching_date_ms` integer,`start_time` text NOT NULL,`end_time` text NOT NULL,`active` numeric NOT NULL DEFAULT false,`remark` text,CONSTRAINT `fk_records_student` FOREIGN KEY (`student_id`) REFERENCES `students`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE,CONSTRAINT `fk_records_teacher` FOREIGN KEY (`teacher_id`) REFERENCES `teachers`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE);
+CREATE INDEX IF NOT EXISTS `idx_records_teaching_date_ms` ON `records`(`teaching_date_ms`);
+CREATE INDEX IF NOT EXISTS `idx_records_teacher_id` ON `records`(`teacher_id`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_stu_teach_date_time` ON `records`(`student_id`,`teacher_id`,`teaching_date`,`start_time`,`end_time`);
+CREATE INDEX IF NOT EXISTS `idx_records_student_id` ON `records`(`student_id`);
+CREATE INDEX IF NOT EXISTS `idx_records_deleted_at` ON `records`(`deleted_at`);
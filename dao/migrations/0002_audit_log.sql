@@ -0,0 +1,15 @@
+-- 审计日志：与业务修改在同一事务中写入，只允许追加。
+CREATE TABLE `audit_log` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime NOT NULL,`operation` text NOT NULL,`entity` text NOT NULL,`entity_id` integer NOT NULL,`before_json` text,`after_json` text,`request_id` text,`route` text);
+CREATE INDEX `idx_audit_log_created_at` ON `audit_log`(`created_at`);
+CREATE INDEX `idx_audit_log_operation` ON `audit_log`(`operation`);
+CREATE INDEX `idx_audit_log_entity` ON `audit_log`(`entity`,`entity_id`);
+
+CREATE TRIGGER `trg_audit_log_no_update` BEFORE UPDATE ON `audit_log`
+BEGIN
+	SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER `trg_audit_log_no_delete` BEFORE DELETE ON `audit_log`
+BEGIN
+	SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
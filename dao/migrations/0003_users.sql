@@ -0,0 +1,5 @@
+-- 登录用户与角色；审计日志记录操作人。
+CREATE TABLE `users` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`username` text NOT NULL,`password_hash` text NOT NULL,`display_name` text,`role` text NOT NULL,`disabled` numeric NOT NULL DEFAULT false,CONSTRAINT `uni_users_username` UNIQUE (`username`));
+CREATE INDEX `idx_users_deleted_at` ON `users`(`deleted_at`);
+
+ALTER TABLE `audit_log` ADD COLUMN `actor` text;
@@ -0,0 +1,2 @@
+-- 上课记录消耗的课时；此前每条记录固定消耗 1 课时。
+ALTER TABLE `records` ADD COLUMN `hours` integer NOT NULL DEFAULT 1;
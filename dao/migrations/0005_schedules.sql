@@ -0,0 +1,10 @@
+-- 固定课表：按星期重复的上课规则，提前生成待激活的上课记录；生成的记录通过 schedule_id 关联所属课表。
+CREATE TABLE `schedules` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`student_id` integer NOT NULL,`teacher_id` integer NOT NULL,`weekday` integer NOT NULL,`start_time` text NOT NULL,`end_time` text NOT NULL,`start_date` date NOT NULL,`end_date` date,`skip_holidays` numeric NOT NULL DEFAULT true,`allow_overlap` numeric NOT NULL DEFAULT false,`hours` integer,`remark` text,`generated_until` date,CONSTRAINT `fk_schedules_student` FOREIGN KEY (`student_id`) REFERENCES `students`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE,CONSTRAINT `fk_schedules_teacher` FOREIGN KEY (`teacher_id`) REFERENCES `teachers`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE);
+CREATE INDEX `idx_schedules_student_id` ON `schedules`(`student_id`);
+CREATE INDEX `idx_schedules_deleted_at` ON `schedules`(`deleted_at`);
+
+-- 节假日，课表设置跳过节假日时不在这些日期生成记录。
+CREATE TABLE `holidays` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`date` date NOT NULL,`name` text,CONSTRAINT `uni_holidays_date` UNIQUE (`date`));
+
+ALTER TABLE `records` ADD COLUMN `schedule_id` integer REFERENCES `schedules`(`id`);
+CREATE INDEX `idx_records_schedule_id` ON `records`(`schedule_id`);
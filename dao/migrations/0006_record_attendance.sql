@@ -0,0 +1,4 @@
+-- 上课记录的出勤状态；补课记录通过 makeup_for_id 关联被补的课。已有记录均视为正常出勤。
+ALTER TABLE `records` ADD COLUMN `attendance` text NOT NULL DEFAULT 'attended';
+ALTER TABLE `records` ADD COLUMN `makeup_for_id` integer REFERENCES `records`(`id`);
+CREATE INDEX `idx_records_makeup_for_id` ON `records`(`makeup_for_id`);
@@ -0,0 +1,8 @@
+-- 小组课：一节课对应多名学生，每名学生仍是一条上课记录，通过 session_id 关联所属课次。
+CREATE TABLE `class_sessions` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`teacher_id` integer NOT NULL,`teaching_date` date NOT NULL,`teaching_date_ms` integer,`start_time` text NOT NULL,`end_time` text NOT NULL,`title` text,`remark` text,CONSTRAINT `fk_class_sessions_teacher` FOREIGN KEY (`teacher_id`) REFERENCES `teachers`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE);
+CREATE INDEX `idx_class_sessions_teacher_id` ON `class_sessions`(`teacher_id`);
+CREATE INDEX `idx_class_sessions_teaching_date_ms` ON `class_sessions`(`teaching_date_ms`);
+CREATE INDEX `idx_class_sessions_deleted_at` ON `class_sessions`(`deleted_at`);
+
+ALTER TABLE `records` ADD COLUMN `session_id` integer REFERENCES `class_sessions`(`id`);
+CREATE INDEX `idx_records_session_id` ON `records`(`session_id`);
@@ -17,7 +17,7 @@ type Order struct {
 }
 
 type OrderDAO interface {
-	CreateOrder(ctx context.Context, order Order) error
+	CreateOrder(ctx context.Context, order *Order) error
 	GetOrdersByStudentID(ctx context.Context, studentID uint, offset int, limit int) ([]Order, int64, error)
 }
 
@@ -29,13 +29,13 @@ type OrderGormDAO struct {
 	db *gorm.DB
 }
 
-func (o *OrderGormDAO) CreateOrder(ctx context.Context, order Order) error {
-	return gorm.G[Order](o.db).Create(ctx, &order)
+func (o *OrderGormDAO) CreateOrder(ctx context.Context, order *Order) error {
+	return gorm.G[Order](conn(ctx, o.db)).Create(ctx, order)
 }
 
 func (o *OrderGormDAO) GetOrdersByStudentID(ctx context.Context, studentID uint, offset int, limit int) ([]Order, int64, error) {
 	var orders []Order
-	query := gorm.G[Order](o.db).Where("student_id = ?", studentID)
+	query := gorm.G[Order](conn(ctx, o.db)).Where("student_id = ?", studentID)
 	total, err := query.Count(ctx, "*")
 	if err != nil {
 		return nil, 0, err
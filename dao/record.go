@@ -3,12 +3,41 @@ package dao
 import (
 	"context"
 	"errors"
+	"fmt"
 	"teaching_manage/pkg/logger"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// 上课记录的出勤状态
+const (
+	// AttendanceAttended 正常上课，激活时扣除课时
+	AttendanceAttended = "attended"
+	// AttendanceAbsent 无故缺课，照常扣除课时
+	AttendanceAbsent = "absent"
+	// AttendanceLeave 请假，不扣课时，可另行安排补课
+	AttendanceLeave = "leave"
+	// AttendanceMakeup 补课，扣除课时，关联被补的请假或缺课记录
+	AttendanceMakeup = "makeup"
+)
+
+// ChargedHoursSQL 返回 table 中记录激活时实际扣除课时的 SQL 表达式，与 service 中的扣课规则一致
+func ChargedHoursSQL(table string) string {
+	return fmt.Sprintf("(CASE WHEN %s.attendance = '%s' THEN 0 ELSE %s.hours END)", table, AttendanceLeave, table)
+}
+
+// LessonKeySQL 返回 table 中记录所属课次的 SQL 表达式：同一小组课的记录相同，一对一的课各不相同，
+// 用 COUNT(DISTINCT ...) 统计课次而不是学生人次
+func LessonKeySQL(table string) string {
+	return fmt.Sprintf("(CASE WHEN %s.session_id IS NULL THEN -%s.id ELSE %s.session_id END)", table, table, table)
+}
+
+// TaughtSQL 返回 table 中记录实际上过课（正常上课或补课）的 SQL 条件
+func TaughtSQL(table string) string {
+	return fmt.Sprintf("%s.attendance IN ('%s', '%s')", table, AttendanceAttended, AttendanceMakeup)
+}
+
 type Record struct {
 	gorm.Model
 	StudentID uint `gorm:"column:student_id;not null;comment:'学生主键';index;uniqueIndex:idx_stu_teach_date_time"`
@@ -23,17 +52,41 @@ type Record struct {
 	StartTime      string    `gorm:"column:start_time;not null;comment:'上课开始时间';uniqueIndex:idx_stu_teach_date_time"`
 	EndTime        string    `gorm:"column:end_time;not null;comment:'上课结束时间';uniqueIndex:idx_stu_teach_date_time"`
 	Active         bool      `gorm:"column:active;not null;default:false;comment:'是否生效'"`
+	Hours          int       `gorm:"column:hours;not null;default:1;comment:'激活时扣除的课时'"`
 	Remark         string    `gorm:"column:remark;size:255;comment:'备注字段'"`
+	// ScheduleID 为生成该记录的课表，手工创建或导入的记录为空
+	ScheduleID *uint  `gorm:"column:schedule_id;index;comment:'所属课表'"`
+	Attendance string `gorm:"column:attendance;not null;default:attended;comment:'出勤状态'"`
+	// MakeupForID 为补课记录所补的请假或缺课记录
+	MakeupForID *uint `gorm:"column:makeup_for_id;index;comment:'被补的记录'"`
+	// SessionID 为所属的小组课课次，一对一的课为空
+	SessionID *uint `gorm:"column:session_id;index;comment:'所属课次'"`
 }
 
 type RecordDAO interface {
-	CreateRecord(ctx context.Context, record Record) error
+	CreateRecord(ctx context.Context, record *Record) error
+	UpdateRecord(ctx context.Context, record *Record) error
+	// GetRecordList 按学生、教师姓名与日期范围查询记录，attendance 不为空时只返回该出勤状态的记录
 	GetRecordList(ctx context.Context, stuKey string, teachKey string,
-		startDate string, endDate string, offset int, limit int) ([]Record, int64, int64, error)
+		startDate string, endDate string, attendance string, offset int, limit int) ([]Record, int64, int64, error)
 	ActivateRecord(ctx context.Context, recordID uint) error
+	DeactivateRecord(ctx context.Context, recordID uint) error
 	GetRecordByID(ctx context.Context, d uint) (*Record, error)
 	DeleteRecordByID(ctx context.Context, id uint) error
 	GetAllPendingRecordList(ctx context.Context) ([]Record, error)
+	GetRecordsOfDate(ctx context.Context, date time.Time, studentID uint, teacherID uint) ([]Record, error)
+	// GetRecordsOfSession 返回小组课课次的全部学生记录
+	GetRecordsOfSession(ctx context.Context, sessionID uint) ([]Record, error)
+	// CountMakeupRecords 统计补 originalID 这节课的补课记录，不含 excludeID
+	CountMakeupRecords(ctx context.Context, originalID uint, excludeID uint) (int64, error)
+	// GetRecordsOfSchedule 返回课表生成的、上课日期不早于 from 的记录
+	GetRecordsOfSchedule(ctx context.Context, scheduleID uint, from time.Time) ([]Record, error)
+	// GetDeletedRecordsOfSchedule 返回课表生成后被删除的、上课日期不早于 from 的记录
+	GetDeletedRecordsOfSchedule(ctx context.Context, scheduleID uint, from time.Time) ([]Record, error)
+	// CountMakeupReferences 统计 makeup_for_id 指向 originalID 的记录，包括已删除的补课记录
+	CountMakeupReferences(ctx context.Context, originalID uint) (int64, error)
+	// PurgeRecordByID 彻底删除记录，不保留软删除的行
+	PurgeRecordByID(ctx context.Context, id uint) error
 }
 
 func NewRecordDao(db *gorm.DB) RecordDAO {
@@ -44,9 +97,9 @@ type RecordGormDAO struct {
 	db *gorm.DB
 }
 
-func (r *RecordGormDAO) CreateRecord(ctx context.Context, record Record) error {
-	convertRecordTimeToUnixMs(&record)
-	err := gorm.G[Record](r.db).Create(ctx, &record)
+func (r *RecordGormDAO) CreateRecord(ctx context.Context, record *Record) error {
+	convertRecordTimeToUnixMs(record)
+	err := gorm.G[Record](conn(ctx, r.db)).Create(ctx, record)
 	if err != nil {
 		if errors.Is(err, gorm.ErrDuplicatedKey) {
 			return ErrDuplicatedKey
@@ -56,6 +109,31 @@ func (r *RecordGormDAO) CreateRecord(ctx context.Context, record Record) error {
 	return nil
 }
 
+// UpdateRecord 更新记录的学生、教师、时间、课时与备注，不修改激活状态
+func (r *RecordGormDAO) UpdateRecord(ctx context.Context, record *Record) error {
+	record.TeachingDateMs = 0
+	convertRecordTimeToUnixMs(record)
+	_, err := gorm.G[Record](conn(ctx, r.db)).Where("id = ?", record.ID).
+		Select("student_id", "teacher_id", "teaching_date", "teaching_date_ms", "start_time", "end_time", "hours", "remark",
+			"attendance", "makeup_for_id").
+		Updates(ctx, Record{
+			StudentID:      record.StudentID,
+			TeacherID:      record.TeacherID,
+			TeachingDate:   record.TeachingDate,
+			TeachingDateMs: record.TeachingDateMs,
+			StartTime:      record.StartTime,
+			EndTime:        record.EndTime,
+			Hours:          record.Hours,
+			Remark:         record.Remark,
+			Attendance:     record.Attendance,
+			MakeupForID:    record.MakeupForID,
+		})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicatedKey
+	}
+	return err
+}
+
 func convertRecordTimeToUnixMs(r *Record) {
 	// 若未提供 TeachingDateMs，则从 TeachingDate 生成 Unix 毫秒（UTC）
 	if r.TeachingDateMs == 0 && !r.TeachingDate.IsZero() {
@@ -64,12 +142,12 @@ func convertRecordTimeToUnixMs(r *Record) {
 }
 
 func (r *RecordGormDAO) GetRecordList(ctx context.Context, stuKey string, teachKey string,
-	startDate string, endDate string, offset int, limit int) ([]Record, int64, int64, error) {
+	startDate string, endDate string, attendance string, offset int, limit int) ([]Record, int64, int64, error) {
 	var records []Record
 
 	// Unscoped 用于包含记录中学生和老师被软删除的记录
 	// 构建查询，关联学生和教师表以进行模糊搜索
-	query := r.db.WithContext(ctx).Model(&Record{}).Unscoped().Where("records.deleted_at is null")
+	query := conn(ctx, r.db).WithContext(ctx).Model(&Record{}).Unscoped().Where("records.deleted_at is null")
 	query = query.Joins("Teacher").Joins("Student")
 
 	if stuKey != "" {
@@ -87,6 +165,9 @@ func (r *RecordGormDAO) GetRecordList(ctx context.Context, stuKey string, teachK
 		// 增加时间部分以包含当天的记录
 		query = query.Where("teaching_date <= ?", endDate+" 23:59:59")
 	}
+	if attendance != "" {
+		query = query.Where("records.attendance = ?", attendance)
+	}
 
 	// 获取总记录数
 	total := int64(0)
@@ -96,7 +177,7 @@ func (r *RecordGormDAO) GetRecordList(ctx context.Context, stuKey string, teachK
 	}
 
 	pendingTotal := int64(0)
-	err = r.db.WithContext(ctx).Model(&Record{}).Where("active = ?", false).Count(&pendingTotal).Error
+	err = conn(ctx, r.db).WithContext(ctx).Model(&Record{}).Where("active = ?", false).Count(&pendingTotal).Error
 
 	// 应用分页参数并执行查询
 	err = query.Offset(offset).Limit(limit).Order("records.teaching_date_ms DESC").Find(&records).Error
@@ -107,16 +188,21 @@ func (r *RecordGormDAO) GetRecordList(ctx context.Context, stuKey string, teachK
 }
 
 func (r *RecordGormDAO) ActivateRecord(ctx context.Context, recordID uint) error {
-	_, err := gorm.G[Record](r.db).Where("id = ?", recordID).Update(ctx, "active", true)
+	_, err := gorm.G[Record](conn(ctx, r.db)).Where("id = ?", recordID).Update(ctx, "active", true)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+func (r *RecordGormDAO) DeactivateRecord(ctx context.Context, recordID uint) error {
+	_, err := gorm.G[Record](conn(ctx, r.db)).Where("id = ?", recordID).Update(ctx, "active", false)
+	return err
+}
+
 func (r *RecordGormDAO) GetRecordByID(ctx context.Context, d uint) (*Record, error) {
 	var record Record
-	record, err := gorm.G[Record](r.db).Where("id = ?", d).First(ctx)
+	record, err := gorm.G[Record](conn(ctx, r.db)).Where("id = ?", d).First(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -124,9 +210,9 @@ func (r *RecordGormDAO) GetRecordByID(ctx context.Context, d uint) (*Record, err
 }
 
 func (r *RecordGormDAO) DeleteRecordByID(ctx context.Context, id uint) error {
-	_, err := gorm.G[Record](r.db).Where("id = ?", id).Delete(ctx)
+	_, err := gorm.G[Record](conn(ctx, r.db)).Where("id = ?", id).Delete(ctx)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		logger.Error("delete record but id not found", logger.ErrorType(err), logger.UInt("record_id", id))
+		logger.ErrorContext(ctx, "delete record but id not found", logger.ErrorType(err), logger.UInt("record_id", id))
 		return nil
 	}
 
@@ -138,7 +224,65 @@ func (r *RecordGormDAO) DeleteRecordByID(ctx context.Context, id uint) error {
 
 func (r *RecordGormDAO) GetAllPendingRecordList(ctx context.Context) ([]Record, error) {
 	var records []Record
-	records, err := gorm.G[Record](r.db).Where("active = ?", false).Find(ctx)
+	records, err := gorm.G[Record](conn(ctx, r.db)).Where("active = ?", false).Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// GetRecordsOfDate 返回 date 当天属于该学生或该教师的记录，用于检查上课时间冲突
+func (r *RecordGormDAO) GetRecordsOfDate(ctx context.Context, date time.Time, studentID uint, teacherID uint) ([]Record, error) {
+	var records []Record
+	err := conn(ctx, r.db).WithContext(ctx).Model(&Record{}).Unscoped().Where("records.deleted_at is null").
+		Joins("Teacher").Joins("Student").
+		Where("records.teaching_date_ms = ?", date.UTC().UnixMilli()).
+		Where("records.student_id = ? OR records.teacher_id = ?", studentID, teacherID).
+		Order("records.start_time").
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (r *RecordGormDAO) GetRecordsOfSchedule(ctx context.Context, scheduleID uint, from time.Time) ([]Record, error) {
+	return gorm.G[Record](conn(ctx, r.db)).
+		Where("schedule_id = ? AND teaching_date_ms >= ?", scheduleID, from.UTC().UnixMilli()).
+		Order("teaching_date_ms").
+		Find(ctx)
+}
+
+func (r *RecordGormDAO) GetDeletedRecordsOfSchedule(ctx context.Context, scheduleID uint, from time.Time) ([]Record, error) {
+	var records []Record
+	err := conn(ctx, r.db).WithContext(ctx).Unscoped().
+		Where("schedule_id = ? AND teaching_date_ms >= ? AND deleted_at IS NOT NULL", scheduleID, from.UTC().UnixMilli()).
+		Order("teaching_date_ms").
+		Find(&records).Error
+	return records, err
+}
+
+func (r *RecordGormDAO) CountMakeupReferences(ctx context.Context, originalID uint) (int64, error) {
+	var count int64
+	err := conn(ctx, r.db).WithContext(ctx).Unscoped().Model(&Record{}).Where("makeup_for_id = ?", originalID).Count(&count).Error
+	return count, err
+}
+
+func (r *RecordGormDAO) PurgeRecordByID(ctx context.Context, id uint) error {
+	return conn(ctx, r.db).WithContext(ctx).Unscoped().Where("id = ?", id).Delete(&Record{}).Error
+}
+
+func (r *RecordGormDAO) CountMakeupRecords(ctx context.Context, originalID uint, excludeID uint) (int64, error) {
+	return gorm.G[Record](conn(ctx, r.db)).Where("makeup_for_id = ? AND id <> ?", originalID, excludeID).Count(ctx, "*")
+}
+
+func (r *RecordGormDAO) GetRecordsOfSession(ctx context.Context, sessionID uint) ([]Record, error) {
+	var records []Record
+	err := conn(ctx, r.db).WithContext(ctx).Model(&Record{}).Unscoped().Where("records.deleted_at is null").
+		Joins("Teacher").Joins("Student").
+		Where("records.session_id = ?", sessionID).
+		Order("records.id").
+		Find(&records).Error
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,165 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Schedule 为学生每周固定时间的上课规则，按规则提前生成待激活的上课记录
+type Schedule struct {
+	gorm.Model
+	StudentID uint    `gorm:"column:student_id;not null;index;comment:'学生主键'"`
+	TeacherID uint    `gorm:"column:teacher_id;not null;comment:'教师主键'"`
+	Student   Student `gorm:"foreignKey:StudentID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
+	Teacher   Teacher `gorm:"foreignKey:TeacherID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
+
+	Weekday   int        `gorm:"column:weekday;not null;comment:'星期几，0 为星期日'"`
+	StartTime string     `gorm:"column:start_time;not null;comment:'上课开始时间'"`
+	EndTime   string     `gorm:"column:end_time;not null;comment:'上课结束时间'"`
+	StartDate time.Time  `gorm:"column:start_date;type:date;not null;comment:'首次上课日期'"`
+	EndDate   *time.Time `gorm:"column:end_date;type:date;comment:'最后上课日期，为空表示不结束'"`
+
+	SkipHolidays bool   `gorm:"column:skip_holidays;not null;default:true;comment:'节假日不生成记录'"`
+	AllowOverlap bool   `gorm:"column:allow_overlap;not null;default:false;comment:'允许与其他记录时间重叠'"`
+	Hours        *int   `gorm:"column:hours;comment:'每节课消耗的课时，为空时按课时规则计算'"`
+	Remark       string `gorm:"column:remark;size:255;comment:'备注字段'"`
+	// GeneratedUntil 为已生成记录的最后日期，之后只从该日期之后继续生成，手工删除的记录不会被重新生成
+	GeneratedUntil *time.Time `gorm:"column:generated_until;type:date;comment:'已生成记录的最后日期'"`
+}
+
+// Holiday 为节假日，跳过节假日的课表不在这些日期生成记录
+type Holiday struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+	Date      time.Time `gorm:"column:date;type:date;not null;unique;comment:'日期'"`
+	Name      string    `gorm:"column:name;comment:'名称'"`
+}
+
+type ScheduleDao interface {
+	CreateSchedule(ctx context.Context, s *Schedule) error
+	UpdateSchedule(ctx context.Context, s *Schedule) error
+	SetGeneratedUntil(ctx context.Context, id uint, date *time.Time) error
+	DeleteSchedule(ctx context.Context, id uint) error
+	GetScheduleByID(ctx context.Context, id uint) (*Schedule, error)
+	// GetScheduleList 返回未取消的课表，studentID 为 0 时返回全部学生的课表，limit 为 -1 时不分页
+	GetScheduleList(ctx context.Context, studentID uint, offset int, limit int) ([]Schedule, int64, error)
+
+	CreateHoliday(ctx context.Context, h *Holiday) error
+	DeleteHoliday(ctx context.Context, id uint) error
+	GetHolidayByID(ctx context.Context, id uint) (*Holiday, error)
+	// GetHolidayList 返回 [from, to] 内的节假日，日期为零值时不限制该端
+	GetHolidayList(ctx context.Context, from time.Time, to time.Time) ([]Holiday, error)
+}
+
+type ScheduleGormDao struct {
+	db *gorm.DB
+}
+
+func NewScheduleDao(db *gorm.DB) ScheduleDao {
+	return &ScheduleGormDao{db: db}
+}
+
+func (s ScheduleGormDao) CreateSchedule(ctx context.Context, schedule *Schedule) error {
+	return gorm.G[Schedule](conn(ctx, s.db)).Create(ctx, schedule)
+}
+
+func (s ScheduleGormDao) UpdateSchedule(ctx context.Context, schedule *Schedule) error {
+	_, err := gorm.G[Schedule](conn(ctx, s.db)).Where("id = ?", schedule.ID).
+		Select("teacher_id", "weekday", "start_time", "end_time", "start_date", "end_date",
+			"skip_holidays", "allow_overlap", "hours", "remark").
+		Updates(ctx, Schedule{
+			TeacherID:    schedule.TeacherID,
+			Weekday:      schedule.Weekday,
+			StartTime:    schedule.StartTime,
+			EndTime:      schedule.EndTime,
+			StartDate:    schedule.StartDate,
+			EndDate:      schedule.EndDate,
+			SkipHolidays: schedule.SkipHolidays,
+			AllowOverlap: schedule.AllowOverlap,
+			Hours:        schedule.Hours,
+			Remark:       schedule.Remark,
+		})
+	return err
+}
+
+func (s ScheduleGormDao) SetGeneratedUntil(ctx context.Context, id uint, date *time.Time) error {
+	_, err := gorm.G[Schedule](conn(ctx, s.db)).Where("id = ?", id).Update(ctx, "generated_until", date)
+	return err
+}
+
+func (s ScheduleGormDao) DeleteSchedule(ctx context.Context, id uint) error {
+	_, err := gorm.G[Schedule](conn(ctx, s.db)).Where("id = ?", id).Delete(ctx)
+	return err
+}
+
+func (s ScheduleGormDao) GetScheduleByID(ctx context.Context, id uint) (*Schedule, error) {
+	var schedule Schedule
+	err := conn(ctx, s.db).WithContext(ctx).Model(&Schedule{}).
+		Joins("Student").Joins("Teacher").
+		Where("schedules.id = ?", id).
+		First(&schedule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (s ScheduleGormDao) GetScheduleList(ctx context.Context, studentID uint, offset int, limit int) ([]Schedule, int64, error) {
+	query := conn(ctx, s.db).WithContext(ctx).Model(&Schedule{}).Joins("Student").Joins("Teacher")
+	if studentID != 0 {
+		query = query.Where("schedules.student_id = ?", studentID)
+	}
+
+	total := int64(0)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var schedules []Schedule
+	err := query.Offset(offset).Limit(limit).Order("schedules.weekday, schedules.start_time, schedules.id").Find(&schedules).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return schedules, total, nil
+}
+
+func (s ScheduleGormDao) CreateHoliday(ctx context.Context, h *Holiday) error {
+	err := gorm.G[Holiday](conn(ctx, s.db)).Create(ctx, h)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicatedKey
+	}
+	return err
+}
+
+func (s ScheduleGormDao) DeleteHoliday(ctx context.Context, id uint) error {
+	_, err := gorm.G[Holiday](conn(ctx, s.db)).Where("id = ?", id).Delete(ctx)
+	return err
+}
+
+func (s ScheduleGormDao) GetHolidayByID(ctx context.Context, id uint) (*Holiday, error) {
+	h, err := gorm.G[Holiday](conn(ctx, s.db)).Where("id = ?", id).First(ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+func (s ScheduleGormDao) GetHolidayList(ctx context.Context, from time.Time, to time.Time) ([]Holiday, error) {
+	query := gorm.G[Holiday](conn(ctx, s.db)).Where("")
+	if !from.IsZero() {
+		query = query.Where("date >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("date <= ?", to)
+	}
+	return query.Order("date").Find(ctx)
+}
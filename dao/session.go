@@ -0,0 +1,125 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ClassSession 为一节小组课，每名参加的学生对应一条 Record
+type ClassSession struct {
+	gorm.Model
+	TeacherID uint    `gorm:"column:teacher_id;not null;index;comment:'教师主键'"`
+	Teacher   Teacher `gorm:"foreignKey:TeacherID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
+
+	TeachingDate   time.Time `gorm:"column:teaching_date;type:date;not null;comment:'上课日期'"`
+	TeachingDateMs int64     `gorm:"column:teaching_date_ms;index;comment:'上课时间 Unix 毫秒(UTC) 整数表示'"`
+	StartTime      string    `gorm:"column:start_time;not null;comment:'上课开始时间'"`
+	EndTime        string    `gorm:"column:end_time;not null;comment:'上课结束时间'"`
+	Title          string    `gorm:"column:title;comment:'课程名称'"`
+	Remark         string    `gorm:"column:remark;size:255;comment:'备注字段'"`
+}
+
+// SessionAttendeeCount 为课次的学生人数与已激活人数
+type SessionAttendeeCount struct {
+	SessionID uint
+	Total     int
+	Active    int
+}
+
+type SessionDao interface {
+	CreateSession(ctx context.Context, s *ClassSession) error
+	UpdateSession(ctx context.Context, s *ClassSession) error
+	DeleteSession(ctx context.Context, id uint) error
+	GetSessionByID(ctx context.Context, id uint) (*ClassSession, error)
+	GetSessionList(ctx context.Context, teacherKey string, startDate string, endDate string, offset int, limit int) ([]ClassSession, int64, error)
+	GetAttendeeCounts(ctx context.Context, sessionIDs []uint) ([]SessionAttendeeCount, error)
+}
+
+type SessionGormDao struct {
+	db *gorm.DB
+}
+
+func NewSessionDao(db *gorm.DB) SessionDao {
+	return &SessionGormDao{db: db}
+}
+
+func (s SessionGormDao) CreateSession(ctx context.Context, session *ClassSession) error {
+	session.TeachingDateMs = session.TeachingDate.UTC().UnixMilli()
+	return gorm.G[ClassSession](conn(ctx, s.db)).Create(ctx, session)
+}
+
+func (s SessionGormDao) UpdateSession(ctx context.Context, session *ClassSession) error {
+	session.TeachingDateMs = session.TeachingDate.UTC().UnixMilli()
+	_, err := gorm.G[ClassSession](conn(ctx, s.db)).Where("id = ?", session.ID).
+		Select("teacher_id", "teaching_date", "teaching_date_ms", "start_time", "end_time", "title", "remark").
+		Updates(ctx, ClassSession{
+			TeacherID:      session.TeacherID,
+			TeachingDate:   session.TeachingDate,
+			TeachingDateMs: session.TeachingDateMs,
+			StartTime:      session.StartTime,
+			EndTime:        session.EndTime,
+			Title:          session.Title,
+			Remark:         session.Remark,
+		})
+	return err
+}
+
+func (s SessionGormDao) DeleteSession(ctx context.Context, id uint) error {
+	_, err := gorm.G[ClassSession](conn(ctx, s.db)).Where("id = ?", id).Delete(ctx)
+	return err
+}
+
+func (s SessionGormDao) GetSessionByID(ctx context.Context, id uint) (*ClassSession, error) {
+	var session ClassSession
+	err := conn(ctx, s.db).WithContext(ctx).Model(&ClassSession{}).Joins("Teacher").
+		Where("class_sessions.id = ?", id).
+		First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s SessionGormDao) GetSessionList(ctx context.Context, teacherKey string, startDate string, endDate string, offset int, limit int) ([]ClassSession, int64, error) {
+	query := conn(ctx, s.db).WithContext(ctx).Model(&ClassSession{}).Joins("Teacher")
+	if teacherKey != "" {
+		query = query.Where("Teacher.name LIKE ?", "%"+teacherKey+"%")
+	}
+	if startDate != "" {
+		query = query.Where("class_sessions.teaching_date >= ?", startDate)
+	}
+	if endDate != "" {
+		query = query.Where("class_sessions.teaching_date <= ?", endDate+" 23:59:59")
+	}
+
+	total := int64(0)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var sessions []ClassSession
+	err := query.Offset(offset).Limit(limit).Order("class_sessions.teaching_date_ms DESC, class_sessions.start_time DESC").Find(&sessions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return sessions, total, nil
+}
+
+func (s SessionGormDao) GetAttendeeCounts(ctx context.Context, sessionIDs []uint) ([]SessionAttendeeCount, error) {
+	var counts []SessionAttendeeCount
+	if len(sessionIDs) == 0 {
+		return counts, nil
+	}
+	err := conn(ctx, s.db).WithContext(ctx).Model(&Record{}).
+		Select("session_id, COUNT(*) AS total, SUM(CASE WHEN active THEN 1 ELSE 0 END) AS active").
+		Where("session_id IN ?", sessionIDs).
+		Group("session_id").
+		Scan(&counts).Error
+	return counts, err
+}
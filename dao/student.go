@@ -40,7 +40,7 @@ type Student struct {
 }
 
 func (s StudentGormDao) CreateStudent(ctx context.Context, stu *Student) error {
-	err := gorm.G[Student](s.db).Create(ctx, stu)
+	err := gorm.G[Student](conn(ctx, s.db)).Create(ctx, stu)
 	if errors.Is(err, gorm.ErrDuplicatedKey) {
 		return ErrDuplicatedKey
 	}
@@ -48,7 +48,7 @@ func (s StudentGormDao) CreateStudent(ctx context.Context, stu *Student) error {
 }
 
 func (s StudentGormDao) UpdateStudent(ctx context.Context, stu *Student) error {
-	_, err := gorm.G[Student](s.db).Where("id = ?", stu.ID).Select(
+	_, err := gorm.G[Student](conn(ctx, s.db)).Where("id = ?", stu.ID).Select(
 		"name",
 		"gender",
 		"phone",
@@ -62,7 +62,7 @@ func (s StudentGormDao) UpdateStudent(ctx context.Context, stu *Student) error {
 }
 
 func (s StudentGormDao) UpdateStudentHours(ctx context.Context, id uint, diff int) error {
-	_, err := gorm.G[Student](s.db).Where("id = ?", id).Update(ctx, "hours", gorm.Expr("hours + ?", diff))
+	_, err := gorm.G[Student](conn(ctx, s.db)).Where("id = ?", id).Update(ctx, "hours", gorm.Expr("hours + ?", diff))
 	if err != nil {
 		return err
 	}
@@ -70,8 +70,8 @@ func (s StudentGormDao) UpdateStudentHours(ctx context.Context, id uint, diff in
 }
 
 func (s StudentGormDao) UpdateStudentHoursWithDeleted(ctx context.Context, id uint, diff int) error {
-	_, err := s.db.Unscoped().WithContext(ctx).Model(&Student{}).
-		Where("id = ?", id).Update("hours", gorm.Expr("hours + ?", diff)).RowsAffected, s.db.Error
+	err := conn(ctx, s.db).Unscoped().WithContext(ctx).Model(&Student{}).
+		Where("id = ?", id).Update("hours", gorm.Expr("hours + ?", diff)).Error
 	if err != nil {
 		return err
 	}
@@ -79,7 +79,7 @@ func (s StudentGormDao) UpdateStudentHoursWithDeleted(ctx context.Context, id ui
 }
 
 func (s StudentGormDao) DeleteStudent(ctx context.Context, id uint) error {
-	_, err := gorm.G[Student](s.db).Where("id = ?", id).Delete(ctx)
+	_, err := gorm.G[Student](conn(ctx, s.db)).Where("id = ?", id).Delete(ctx)
 	if err != nil {
 		return err
 	}
@@ -87,7 +87,7 @@ func (s StudentGormDao) DeleteStudent(ctx context.Context, id uint) error {
 }
 
 func (s StudentGormDao) GetStudentByID(ctx context.Context, id uint) (*Student, error) {
-	stu, err := gorm.G[Student](s.db).Where("id = ?", id).Preload("Teacher", nil).First(ctx)
+	stu, err := gorm.G[Student](conn(ctx, s.db)).Where("id = ?", id).Preload("Teacher", nil).First(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -98,7 +98,7 @@ func (s StudentGormDao) GetStudentByID(ctx context.Context, id uint) (*Student,
 func (s StudentGormDao) GetStudentByIdWithDeleted(ctx context.Context, id uint) (*Student, error) {
 	stu := Student{}
 
-	err := s.db.Unscoped().WithContext(ctx).Where("id = ?", id).Preload("Teacher", nil).First(&stu).Error
+	err := conn(ctx, s.db).Unscoped().WithContext(ctx).Where("id = ?", id).Preload("Teacher", nil).First(&stu).Error
 	if err != nil {
 		return nil, err
 	}
@@ -108,7 +108,7 @@ func (s StudentGormDao) GetStudentByIdWithDeleted(ctx context.Context, id uint)
 func (s StudentGormDao) GetStudentList(ctx context.Context, key string, offset int, limit int) ([]Student, int64, error) {
 	var students []Student
 	var total int64
-	query := gorm.G[Student](s.db).Where("")
+	query := gorm.G[Student](conn(ctx, s.db)).Where("")
 	if key != "" {
 		query = query.Where("name LIKE ?", "%"+key+"%")
 	}
@@ -125,7 +125,7 @@ func (s StudentGormDao) GetStudentList(ctx context.Context, key string, offset i
 
 func (s StudentGormDao) GetStudentByName(ctx context.Context, name string) (*Student, error) {
 
-	stu, err := gorm.G[Student](s.db).Where("name = ?", name).Preload("Teacher", nil).First(ctx)
+	stu, err := gorm.G[Student](conn(ctx, s.db)).Where("name = ?", name).Preload("Teacher", nil).First(ctx)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, ErrRecordNotFound
 	}
@@ -46,20 +46,25 @@ type Teacher struct {
 }
 
 func (s TeacherGormDao) CreateTeacher(ctx context.Context, t *Teacher) error {
-	err := gorm.G[Teacher](s.db).Create(ctx, &Teacher{
+	created := Teacher{
 		Name:   t.Name,
 		Gender: t.Gender,
 		Phone:  t.Phone,
 		Remark: t.Remark,
-	})
+	}
+	err := gorm.G[Teacher](conn(ctx, s.db)).Create(ctx, &created)
 	if errors.Is(err, gorm.ErrDuplicatedKey) {
 		return ErrDuplicatedKey
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	t.Model = created.Model
+	return nil
 }
 
 func (s TeacherGormDao) UpdateTeacher(ctx context.Context, t *Teacher) error {
-	_, err := gorm.G[Teacher](s.db).Where("id = ?", t.ID).Select("name", "gender", "phone", "remark").Updates(ctx, Teacher{
+	_, err := gorm.G[Teacher](conn(ctx, s.db)).Where("id = ?", t.ID).Select("name", "gender", "phone", "remark").Updates(ctx, Teacher{
 		Name:   t.Name,
 		Gender: t.Gender,
 		Phone:  t.Phone,
@@ -72,7 +77,7 @@ func (s TeacherGormDao) UpdateTeacher(ctx context.Context, t *Teacher) error {
 }
 
 func (s TeacherGormDao) DeleteTeacher(ctx context.Context, id uint) error {
-	_, err := gorm.G[Teacher](s.db).Where("id = ?", id).Delete(ctx)
+	_, err := gorm.G[Teacher](conn(ctx, s.db)).Where("id = ?", id).Delete(ctx)
 	if err != nil {
 		return err
 	}
@@ -80,7 +85,7 @@ func (s TeacherGormDao) DeleteTeacher(ctx context.Context, id uint) error {
 }
 
 func (s TeacherGormDao) GetTeacherByID(ctx context.Context, id uint) (*Teacher, error) {
-	t, err := gorm.G[Teacher](s.db).Where("id = ?", id).First(ctx)
+	t, err := gorm.G[Teacher](conn(ctx, s.db)).Where("id = ?", id).First(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -90,7 +95,7 @@ func (s TeacherGormDao) GetTeacherByID(ctx context.Context, id uint) (*Teacher,
 // Get teacher list
 func (s TeacherGormDao) GetTeacherList(ctx context.Context, key string, offset int, limit int) ([]Teacher, int64, error) {
 	var teachers []Teacher
-	query := gorm.G[Teacher](s.db).Where("")
+	query := gorm.G[Teacher](conn(ctx, s.db)).Where("")
 
 	if key != "" {
 		query = query.Where("name LIKE ?", "%"+key+"%")
@@ -0,0 +1,47 @@
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// WithTx binds tx to ctx. DAOs and GetDBFromContext prefer the bound
+// transaction over their own connection, so several handlers can share one
+// transaction (SQLite only has a single connection, see InitDB).
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// conn returns the transaction bound to ctx, falling back to db.
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok && tx != nil {
+		return tx
+	}
+	return db
+}
+
+// GetDBFromContext returns the transaction bound to ctx or the global DB,
+// with ctx attached so deadlines and cancellation reach the queries.
+func GetDBFromContext(ctx context.Context) *gorm.DB {
+	return conn(ctx, global_db).WithContext(ctx)
+}
+
+// Transactor runs functions inside a database transaction bound to ctx.
+type Transactor struct{}
+
+// InTx runs fn in a transaction; fn must use the ctx it receives. A nested
+// call joins the outer transaction through a savepoint.
+func (Transactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(WithTx(ctx, tx))
+	})
+}
+
+// HasTx reports whether a transaction is bound to ctx.
+func HasTx(ctx context.Context) bool {
+	tx, ok := ctx.Value(txKey{}).(*gorm.DB)
+	return ok && tx != nil
+}
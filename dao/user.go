@@ -0,0 +1,105 @@
+package dao
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// User 为可以登录系统的账号，密码只保存 bcrypt 哈希
+type User struct {
+	gorm.Model
+	Username     string `gorm:"column:username;not null;unique;comment:登录名"`
+	PasswordHash string `gorm:"column:password_hash;not null;comment:密码哈希"`
+	DisplayName  string `gorm:"column:display_name;comment:显示名称"`
+	Role         string `gorm:"column:role;not null;comment:角色"`
+	Disabled     bool   `gorm:"column:disabled;not null;default:false;comment:是否停用"`
+}
+
+type UserDao interface {
+	CreateUser(ctx context.Context, u *User) error
+	UpdateUser(ctx context.Context, u *User) error
+	UpdatePassword(ctx context.Context, id uint, hash string) error
+	GetUserByID(ctx context.Context, id uint) (*User, error)
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	GetUserList(ctx context.Context, offset int, limit int) ([]User, int64, error)
+	// CountActiveUsers 统计未停用的账号，role 为空时统计全部角色
+	CountActiveUsers(ctx context.Context, role string) (int64, error)
+}
+
+type UserGormDao struct {
+	db *gorm.DB
+}
+
+func NewUserDao(db *gorm.DB) UserDao {
+	return &UserGormDao{db: db}
+}
+
+func (u UserGormDao) CreateUser(ctx context.Context, user *User) error {
+	err := gorm.G[User](conn(ctx, u.db)).Create(ctx, user)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicatedKey
+	}
+	return err
+}
+
+func (u UserGormDao) UpdateUser(ctx context.Context, user *User) error {
+	_, err := gorm.G[User](conn(ctx, u.db)).Where("id = ?", user.ID).Select("display_name", "role", "disabled").Updates(ctx, User{
+		DisplayName: user.DisplayName,
+		Role:        user.Role,
+		Disabled:    user.Disabled,
+	})
+	return err
+}
+
+func (u UserGormDao) UpdatePassword(ctx context.Context, id uint, hash string) error {
+	_, err := gorm.G[User](conn(ctx, u.db)).Where("id = ?", id).Update(ctx, "password_hash", hash)
+	return err
+}
+
+func (u UserGormDao) GetUserByID(ctx context.Context, id uint) (*User, error) {
+	user, err := gorm.G[User](conn(ctx, u.db)).Where("id = ?", id).First(ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (u UserGormDao) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	user, err := gorm.G[User](conn(ctx, u.db)).Where("username = ?", username).First(ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (u UserGormDao) GetUserList(ctx context.Context, offset int, limit int) ([]User, int64, error) {
+	query := gorm.G[User](conn(ctx, u.db)).Where("")
+	total, err := query.Count(ctx, "*")
+	if err != nil {
+		return nil, 0, err
+	}
+	if limit > 0 {
+		query = query.Offset(offset).Limit(limit)
+	}
+	users, err := query.Order("id").Find(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (u UserGormDao) CountActiveUsers(ctx context.Context, role string) (int64, error) {
+	query := gorm.G[User](conn(ctx, u.db)).Where("disabled = ?", false)
+	if role != "" {
+		query = query.Where("role = ?", role)
+	}
+	return query.Count(ctx, "*")
+}
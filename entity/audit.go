@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+type AuditLog struct {
+	ID        uint
+	CreatedAt time.Time
+	Operation string
+	Entity    string
+	EntityID  uint
+	// Before / After 为实体修改前后的 JSON
+	Before    string
+	After     string
+	RequestID string
+	Route     string
+	Actor     string
+}
@@ -12,5 +12,15 @@ type Record struct {
 	StartTime    string
 	EndTime      string
 	Active       bool
-	Remark       string
+	// Hours 为激活时从学生余额扣除的课时
+	Hours  int
+	Remark string
+	// ScheduleID 为生成该记录的课表，0 表示不属于任何课表
+	ScheduleID uint
+	// Attendance 为出勤状态，决定激活时扣除的课时
+	Attendance string
+	// MakeupForID 为补课记录所补的记录，0 表示不是补课
+	MakeupForID uint
+	// SessionID 为所属的小组课课次，0 表示一对一的课
+	SessionID uint
 }
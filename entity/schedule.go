@@ -0,0 +1,32 @@
+package entity
+
+import "time"
+
+// Schedule 为每周固定时间的上课规则
+type Schedule struct {
+	ID        uint
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Student   Student
+	Teacher   Teacher
+	Weekday   time.Weekday
+	StartTime string
+	EndTime   string
+	StartDate time.Time
+	// EndDate 为零值表示课表不结束
+	EndDate      time.Time
+	SkipHolidays bool
+	AllowOverlap bool
+	// Hours 为空时按课时规则计算每节课的课时
+	Hours  *int
+	Remark string
+	// GeneratedUntil 为已生成记录的最后日期，零值表示尚未生成
+	GeneratedUntil time.Time
+}
+
+type Holiday struct {
+	ID        uint
+	CreatedAt time.Time
+	Date      time.Time
+	Name      string
+}
@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+// ClassSession 为一节小组课，参加的每名学生各有一条 Record
+type ClassSession struct {
+	ID           uint
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Teacher      Teacher
+	TeachingDate time.Time
+	StartTime    string
+	EndTime      string
+	Title        string
+	Remark       string
+	// Attendees 与 ActiveAttendees 为学生人数与已激活的人数，仅列表查询时填充
+	Attendees       int
+	ActiveAttendees int
+}
@@ -3,4 +3,6 @@
 
 export function Dispatch(arg1:string,arg2:string):Promise<string>;
 
+export function DispatchBatch(arg1:string):Promise<string>;
+
 export function Greet(arg1:string):Promise<string>;
@@ -1,32 +1,60 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"embed"
+	"flag"
+	"os"
+	"os/signal"
+	"strings"
 	"teaching_manage/dao"
+	"teaching_manage/pkg/auth"
+	"teaching_manage/pkg/config"
 	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/jobs"
 	"teaching_manage/pkg/logger"
 	"teaching_manage/repository"
 	"teaching_manage/service"
+	requestx "teaching_manage/service/request"
 	"teaching_manage/wirex"
 
 	"github.com/wailsapp/wails/v2"
 
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 //go:embed all:frontend/dist
 var assets embed.FS
 
 func main() {
+	httpAddr := flag.String("http", "", "also serve the dispatcher routes over HTTP on this address, e.g. :8080; binds 127.0.0.1 unless a host is given")
+	headless := flag.Bool("headless", false, "run without the desktop window, requires -http")
+	genTS := flag.String("gen-ts", "", "write TypeScript types of all routes to this file and exit")
+	configPath := flag.String("config", "", "config file, defaults to config.yaml in the user config dir")
+	createAdmin := flag.String("create-admin", "", "create an admin user with this name, reading the password from stdin, and exit")
+	flag.Parse()
+
+	// load settings
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		println("Error:", err.Error())
+		os.Exit(1)
+	}
 
 	// setup logger
-	zaplog := wirex.InitLogger()
+	zaplog, err := wirex.InitLogger(cfg.Log)
+	if err != nil {
+		println("Error: invalid log config:", err.Error())
+		os.Exit(1)
+	}
 	logger.SetGlobalLogger(zaplog)
+	logger.Info("config loaded", logger.String("db_path", cfg.Database.Path), logger.String("log_file", cfg.Log.FilePath()))
 
 	// Setup database
-	db, err := wirex.NewGormDB()
+	db, err := wirex.NewGormDB(cfg.Database, cfg.Backup)
 	if err != nil {
 		logger.Error("failed to connect database", logger.ErrorType(err))
 		panic(err)
@@ -47,19 +75,121 @@ func main() {
 	orderRepository := repository.NewOrderRepository(orderDao)
 	orderManager := service.NewOrderManager(orderRepository, studentRepository)
 
+	// Setup job manager
+	jobRunner := jobs.NewManager(auth.CopyPrincipal, service.JobOwner)
+	jobManager := service.NewJobManager(jobRunner)
+
 	// Setup record manager
 	recordDao := dao.NewRecordDao(db)
 	recordRepository := repository.NewRecordRepository(recordDao)
-	recordManager := service.NewRecordManager(recordRepository, studentRepository)
+	hourRule, err := service.NewHourRule(cfg.Hours)
+	if err != nil {
+		logger.Error("invalid hours config", logger.ErrorType(err))
+		println("Error:", err.Error())
+		os.Exit(1)
+	}
+	recordManager := service.NewRecordManager(recordRepository, studentRepository, jobRunner, hourRule)
+
+	// Setup schedule manager
+	scheduleRepository := repository.NewScheduleRepository(dao.NewScheduleDao(db))
+	scheduleManager := service.NewScheduleManager(scheduleRepository, studentRepository, hourRule, cfg.Schedule)
+
+	// Setup session manager
+	sessionRepository := repository.NewSessionRepository(dao.NewSessionDao(db))
+	sessionManager := service.NewSessionManager(sessionRepository, recordRepository, hourRule)
 
 	// Setup Dashboard manager
 	dashboardManager := service.NewDashboardManager()
 
+	// Setup audit manager
+	auditManager := service.NewAuditManager(repository.NewAuditRepository(dao.NewAuditDao(db)))
+
+	// Setup ledger manager
+	ledgerManager := service.NewLedgerManager()
+
+	// Setup system manager
+	systemManager := service.NewSystemManager(cfg.Database.Path, cfg.Backup)
+
+	// Setup user manager
+	userManager := service.NewUserManager(repository.NewUserRepository(dao.NewUserDao(db)))
+
+	if *createAdmin != "" {
+		if err := createAdminUser(userManager, *createAdmin); err != nil {
+			println("Error:", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Setup dispatcher
-	dispatcher := dispatcher.New()
+	policy := service.NewPolicy()
+	dis := dispatcher.New()
+	dis.Use(dispatcher.Logging(), auth.Enforce(policy, userManager))
+	dis.SetTransactor(dao.Transactor{})
+
+	// setup binds the managers to ctx and registers their routes
+	setup := func(ctx context.Context) {
+		teacherManager.Ctx = ctx
+		studentManager.Ctx = ctx
+		orderManager.Ctx = ctx
+		recordManager.Ctx = ctx
+		scheduleManager.Ctx = ctx
+		sessionManager.Ctx = ctx
+		dashboardManager.Ctx = ctx
+		jobManager.Ctx = ctx
+		systemManager.Ctx = ctx
+		ledgerManager.Ctx = ctx
+		auditManager.Ctx = ctx
+		userManager.Ctx = ctx
+
+		// Register routes
+		studentManager.RegisterRoute(dis)
+		teacherManager.RegisterRoute(dis)
+		orderManager.RegisterRoute(dis)
+		recordManager.RegisterRoute(dis)
+		scheduleManager.RegisterRoute(dis)
+		sessionManager.RegisterRoute(dis)
+		dashboardManager.RegisterRoute(dis)
+		jobManager.RegisterRoute(dis)
+		systemManager.RegisterRoute(dis)
+		ledgerManager.RegisterRoute(dis)
+		auditManager.RegisterRoute(dis)
+		userManager.RegisterRoute(dis)
+		dispatcher.RegisterMetaRoutes(dis)
+
+		if missing := policy.Uncovered(dis.Routes()); len(missing) > 0 {
+			logger.Warn("routes without permission policy are denied", logger.Any("routes", missing))
+		}
+	}
+
+	if *genTS != "" {
+		setup(context.Background())
+		if err := os.WriteFile(*genTS, []byte(dis.TypeScript()), 0o644); err != nil {
+			println("Error:", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *headless {
+		if *httpAddr == "" {
+			println("Error: -headless requires -http")
+			os.Exit(2)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		setup(ctx)
+		go systemManager.RunSchedule(ctx)
+		go scheduleManager.RunGenerate(ctx)
+		if err := dispatcher.ListenAndServe(ctx, *httpAddr, dis); err != nil {
+			logger.Error("http transport stopped", logger.ErrorType(err))
+			println("Error:", err.Error())
+		}
+		return
+	}
 
 	// Create an instance of the app structure
-	app := NewApp(dispatcher)
+	app := NewApp(dis)
 
 	// Create application with options
 	err = wails.Run(&options.App{
@@ -78,18 +208,20 @@ func main() {
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup: func(ctx context.Context) {
 			app.startup(ctx)
-			teacherManager.Ctx = ctx
-			studentManager.Ctx = ctx
-			orderManager.Ctx = ctx
-			recordManager.Ctx = ctx
-			dashboardManager.Ctx = ctx
-
-			// Register routes
-			studentManager.RegisterRoute(dispatcher)
-			teacherManager.RegisterRoute(dispatcher)
-			orderManager.RegisterRoute(dispatcher)
-			recordManager.RegisterRoute(dispatcher)
-			dashboardManager.RegisterRoute(dispatcher)
+			setup(ctx)
+			go systemManager.RunSchedule(ctx)
+			go scheduleManager.RunGenerate(ctx)
+			jobRunner.SetEmitter(func(event string, data any) {
+				wailsruntime.EventsEmit(ctx, event, data)
+			})
+
+			if *httpAddr != "" {
+				go func() {
+					if err := dispatcher.ListenAndServe(ctx, *httpAddr, dis); err != nil {
+						logger.Error("http transport stopped", logger.ErrorType(err))
+					}
+				}()
+			}
 		},
 		Bind: []interface{}{
 			app,
@@ -100,3 +232,23 @@ func main() {
 		println("Error:", err.Error())
 	}
 }
+
+// createAdminUser creates the first admin of a headless installation, which
+// cannot use the desktop setup mode. The password is read from stdin.
+func createAdminUser(um *service.UserManager, username string) error {
+	println("Password for", username+":")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return err
+	}
+	_, err = um.CreateUser(context.Background(), &requestx.CreateUserRequest{
+		Username: username,
+		Password: strings.TrimRight(password, "\r\n"),
+		Role:     service.RoleAdmin,
+	})
+	if err != nil {
+		return err
+	}
+	println("admin", username, "created")
+	return nil
+}
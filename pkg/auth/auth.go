@@ -0,0 +1,195 @@
+// Package auth authenticates dispatcher callers and checks their role against
+// a per-route permission policy.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/wraper"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Permission is the right needed to call a route.
+type Permission string
+
+const (
+	// Public routes can be called without logging in, e.g. the login route.
+	Public Permission = ""
+	// All grants every permission to a role.
+	All Permission = "*"
+)
+
+// Principal is the logged in user a request runs as.
+type Principal struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+type principalKey struct{}
+
+type policyKey struct{}
+
+// WithPrincipal stores p in ctx.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored in ctx.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// CopyPrincipal stores the principal of from, if any, in to, together with the
+// policy it was checked against so that Require keeps working. It carries the
+// logged in user over to work that outlives the request, see jobs.DetachFunc.
+func CopyPrincipal(from, to context.Context) context.Context {
+	if policy, ok := from.Value(policyKey{}).(*Policy); ok {
+		to = context.WithValue(to, policyKey{}, policy)
+	}
+	if p, ok := PrincipalFrom(from); ok {
+		return WithPrincipal(to, p)
+	}
+	return to
+}
+
+// ErrUnauthenticated is returned for routes that need a login when the caller
+// has no valid session.
+var ErrUnauthenticated = errorx.Unauthenticated("login required")
+
+// Policy maps roles to their permissions and routes to the permission they
+// require.
+type Policy struct {
+	roles  map[string]map[Permission]bool
+	routes map[string]Permission
+}
+
+// NewPolicy builds a Policy. Routes missing from routes are denied to everyone.
+func NewPolicy(roles map[string][]Permission, routes map[string]Permission) *Policy {
+	p := &Policy{roles: make(map[string]map[Permission]bool, len(roles)), routes: routes}
+	for role, perms := range roles {
+		set := make(map[Permission]bool, len(perms))
+		for _, perm := range perms {
+			set[perm] = true
+		}
+		p.roles[role] = set
+	}
+	return p
+}
+
+// HasRole reports whether role is known to the policy.
+func (p *Policy) HasRole(role string) bool {
+	_, ok := p.roles[role]
+	return ok
+}
+
+// Allowed reports whether role holds perm.
+func (p *Policy) Allowed(role string, perm Permission) bool {
+	if perm == Public {
+		return true
+	}
+	set := p.roles[role]
+	return set[All] || set[perm]
+}
+
+// RoutePermission returns the permission required by route.
+func (p *Policy) RoutePermission(route string) (Permission, bool) {
+	perm, ok := p.routes[route]
+	return perm, ok
+}
+
+// Uncovered returns the routes of infos that have no entry in the policy.
+func (p *Policy) Uncovered(infos []dispatcher.RouteInfo) []string {
+	var missing []string
+	for _, info := range infos {
+		if _, ok := p.routes[info.Name]; !ok {
+			missing = append(missing, info.Name)
+		}
+	}
+	return missing
+}
+
+// Authenticator resolves the principal of a caller. It returns
+// ErrUnauthenticated when the caller has not logged in.
+type Authenticator interface {
+	Authenticate(ctx context.Context, caller dispatcher.Caller) (Principal, error)
+}
+
+// Enforce is a global middleware that authenticates the caller and checks the
+// permission the policy requires for the route. Public routes still get the
+// principal when the caller happens to be logged in.
+func Enforce(policy *Policy, authn Authenticator) dispatcher.Middleware {
+	return func(next dispatcher.Handler) dispatcher.Handler {
+		return dispatcher.HandlerFunc(func(ctx context.Context, payload json.RawMessage) (string, error) {
+			route := dispatcher.RouteName(ctx)
+			perm, ok := policy.RoutePermission(route)
+			if !ok {
+				err := errorx.PermissionDenied(fmt.Sprintf("route [%s] has no permission policy", route))
+				return errorResponse(err), err
+			}
+			ctx = context.WithValue(ctx, policyKey{}, policy)
+
+			p, err := authn.Authenticate(ctx, dispatcher.CallerOf(ctx))
+			switch {
+			case err == nil:
+				ctx = WithPrincipal(ctx, p)
+			case perm == Public && errors.Is(err, ErrUnauthenticated):
+				return next.Serve(ctx, payload)
+			default:
+				return errorResponse(err), err
+			}
+
+			if !policy.Allowed(p.Role, perm) {
+				err := errorx.PermissionDenied(fmt.Sprintf("role [%s] is not allowed to call [%s]", p.Role, route))
+				return errorResponse(err), err
+			}
+			return next.Serve(ctx, payload)
+		})
+	}
+}
+
+// Require checks that the principal in ctx holds perm. Handlers use it for
+// checks that depend on the payload rather than on the route alone. Calls that
+// did not pass through Enforce (internal calls, tools) are allowed, unless ctx
+// carries a principal without the policy to check it against.
+func Require(ctx context.Context, perm Permission) error {
+	p, hasPrincipal := PrincipalFrom(ctx)
+	policy, ok := ctx.Value(policyKey{}).(*Policy)
+	if !ok {
+		if hasPrincipal {
+			return errorx.PermissionDenied(fmt.Sprintf("no permission policy to check [%s] for user [%s]", perm, p.Username))
+		}
+		return nil
+	}
+	if !hasPrincipal {
+		return ErrUnauthenticated
+	}
+	if !policy.Allowed(p.Role, perm) {
+		return errorx.PermissionDenied(fmt.Sprintf("role [%s] lacks permission [%s]", p.Role, perm))
+	}
+	return nil
+}
+
+func errorResponse(err error) string {
+	return wraper.NewErrorResponse(dispatcher.CodeOf(err), err.Error(), "").ToJSON()
+}
+
+// HashPassword returns the bcrypt hash of password.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches hash.
+func CheckPassword(hash string, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Sessions keeps logged in principals in memory, keyed by an opaque token.
+// A session expires after ttl without use. Sessions do not survive a restart.
+type Sessions struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]*session
+}
+
+type session struct {
+	principal Principal
+	expiresAt time.Time
+}
+
+func NewSessions(ttl time.Duration) *Sessions {
+	return &Sessions{ttl: ttl, sessions: make(map[string]*session)}
+}
+
+// Create starts a session for p and returns its token.
+func (s *Sessions) Create(p Principal) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	s.sessions[token] = &session{principal: p, expiresAt: time.Now().Add(s.ttl)}
+	return token, nil
+}
+
+// Get returns the principal of token and extends the session.
+func (s *Sessions) Get(token string) (Principal, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[token]
+	if !ok {
+		return Principal{}, false
+	}
+	now := time.Now()
+	if now.After(sess.expiresAt) {
+		delete(s.sessions, token)
+		return Principal{}, false
+	}
+	sess.expiresAt = now.Add(s.ttl)
+	return sess.principal, true
+}
+
+// Revoke ends the session of token.
+func (s *Sessions) Revoke(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+}
+
+// RevokeUser ends every session of the user, e.g. after the user was disabled
+// or the password was reset.
+func (s *Sessions) RevokeUser(userID uint) {
+	s.RevokeOthers(userID, "")
+}
+
+// RevokeOthers ends every session of the user except keep, e.g. after the
+// user changed the password in the session keep.
+func (s *Sessions) RevokeOthers(userID uint, keep string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, sess := range s.sessions {
+		if sess.principal.UserID == userID && token != keep {
+			delete(s.sessions, token)
+		}
+	}
+}
+
+func (s *Sessions) pruneLocked() {
+	now := time.Now()
+	for token, sess := range s.sessions {
+		if now.After(sess.expiresAt) {
+			delete(s.sessions, token)
+		}
+	}
+}
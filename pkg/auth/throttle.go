@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Throttle limits failed login attempts per key. After max failures within
+// window, attempts with that key are refused for lockout. A success clears
+// the key.
+type Throttle struct {
+	mu      sync.Mutex
+	max     int
+	window  time.Duration
+	lockout time.Duration
+	keys    map[string]*attempts
+	now     func() time.Time
+}
+
+type attempts struct {
+	failures    int
+	first       time.Time
+	lockedUntil time.Time
+}
+
+func NewThrottle(max int, window, lockout time.Duration) *Throttle {
+	return &Throttle{max: max, window: window, lockout: lockout, keys: make(map[string]*attempts), now: time.Now}
+}
+
+// Allow reports whether an attempt with key may proceed and, if not, how long
+// the key stays locked.
+func (t *Throttle) Allow(key string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a, ok := t.keys[key]
+	if !ok {
+		return 0, true
+	}
+	if wait := a.lockedUntil.Sub(t.now()); wait > 0 {
+		return wait, false
+	}
+	return 0, true
+}
+
+// Fail records a failed attempt with key.
+func (t *Throttle) Fail(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	t.pruneLocked(now)
+	a, ok := t.keys[key]
+	if !ok || now.Sub(a.first) > t.window {
+		a = &attempts{first: now}
+		t.keys[key] = a
+	}
+	a.failures++
+	if a.failures >= t.max {
+		a.lockedUntil = now.Add(t.lockout)
+		a.failures = 0
+		a.first = now
+	}
+}
+
+// Reset clears the failures of key.
+func (t *Throttle) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.keys, key)
+}
+
+func (t *Throttle) pruneLocked(now time.Time) {
+	for key, a := range t.keys {
+		if now.Sub(a.first) > t.window && now.After(a.lockedUntil) {
+			delete(t.keys, key)
+		}
+	}
+}
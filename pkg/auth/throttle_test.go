@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	type step struct {
+		after   time.Duration // advance the clock before the step
+		key     string
+		fail    bool // record a failure, otherwise a success
+		allowed bool // expected result of Allow before the attempt
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "locks after max failures",
+			steps: []step{
+				{key: "a", fail: true, allowed: true},
+				{key: "a", fail: true, allowed: true},
+				{key: "a", fail: true, allowed: true},
+				{key: "a", allowed: false},
+				{key: "b", allowed: true},
+			},
+		},
+		{
+			name: "lock expires",
+			steps: []step{
+				{key: "a", fail: true, allowed: true},
+				{key: "a", fail: true, allowed: true},
+				{key: "a", fail: true, allowed: true},
+				{after: 10 * time.Minute, key: "a", fail: true, allowed: true},
+				{key: "a", fail: true, allowed: true},
+				{key: "a", fail: true, allowed: true},
+				{key: "a", allowed: false},
+			},
+		},
+		{
+			name: "failures outside the window are forgotten",
+			steps: []step{
+				{key: "a", fail: true, allowed: true},
+				{key: "a", fail: true, allowed: true},
+				{after: 6 * time.Minute, key: "a", fail: true, allowed: true},
+				{key: "a", fail: true, allowed: true},
+				{key: "a", fail: true, allowed: true},
+				{key: "a", allowed: false},
+			},
+		},
+		{
+			name: "success resets the count",
+			steps: []step{
+				{key: "a", fail: true, allowed: true},
+				{key: "a", fail: true, allowed: true},
+				{key: "a", allowed: true},
+				{key: "a", fail: true, allowed: true},
+				{key: "a", fail: true, allowed: true},
+				{key: "a", allowed: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+			th := NewThrottle(3, 5*time.Minute, 10*time.Minute)
+			th.now = func() time.Time { return now }
+			for i, s := range tt.steps {
+				now = now.Add(s.after)
+				wait, ok := th.Allow(s.key)
+				if ok != s.allowed {
+					t.Fatalf("step %d: Allow(%q) = %v, want %v", i, s.key, ok, s.allowed)
+				}
+				if !ok {
+					if wait <= 0 {
+						t.Fatalf("step %d: Allow(%q) wait = %v, want > 0", i, s.key, wait)
+					}
+					continue
+				}
+				if s.fail {
+					th.Fail(s.key)
+				} else {
+					th.Reset(s.key)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AppName names the per-user config directory.
+const AppName = "teaching_manage"
+
+// EnvPrefix prefixes the environment variables overriding the config file,
+// e.g. TEACHING_MANAGE_DB_PATH.
+const EnvPrefix = "TEACHING_MANAGE_"
+
+type Config struct {
+	Database DatabaseConfig `yaml:"database"`
+	Log      LogConfig      `yaml:"log"`
+	Backup   BackupConfig   `yaml:"backup"`
+	Hours    HoursConfig    `yaml:"hours"`
+	Schedule ScheduleConfig `yaml:"schedule"`
+}
+
+type DatabaseConfig struct {
+	// Path of the SQLite file; relative paths are resolved against BaseDir.
+	Path string `yaml:"path"`
+}
+
+type BackupConfig struct {
+	// Dir holds the database snapshots; relative paths are resolved against
+	// BaseDir.
+	Dir string `yaml:"dir"`
+	// IntervalHours between scheduled snapshots, 0 disables the schedule.
+	IntervalHours int `yaml:"interval_hours"`
+	// Keep is the number of snapshots retained, 0 keeps all.
+	Keep int `yaml:"keep"`
+}
+
+// Hour consumption modes of HoursConfig.Mode.
+const (
+	HoursModeFixed    = "fixed"
+	HoursModeDuration = "duration"
+)
+
+// Rounding rules of HoursConfig.Rounding.
+const (
+	RoundingUp      = "up"
+	RoundingDown    = "down"
+	RoundingNearest = "nearest"
+)
+
+// HoursConfig decides how many hours a lesson record consumes when it does
+// not set its hours explicitly.
+type HoursConfig struct {
+	// Mode is "fixed" (PerRecord hours per record) or "duration" (one hour per
+	// UnitMinutes of lesson time, rounded by Rounding).
+	Mode        string `yaml:"mode"`
+	PerRecord   int    `yaml:"per_record"`
+	UnitMinutes int    `yaml:"unit_minutes"`
+	// Rounding is "up", "down" or "nearest".
+	Rounding string `yaml:"rounding"`
+}
+
+// Validate reports settings the hour rule cannot work with.
+func (h HoursConfig) Validate() error {
+	switch h.Mode {
+	case HoursModeFixed:
+		if h.PerRecord < 0 {
+			return fmt.Errorf("hours.per_record must not be negative")
+		}
+	case HoursModeDuration:
+		if h.UnitMinutes <= 0 {
+			return fmt.Errorf("hours.unit_minutes must be positive")
+		}
+		switch h.Rounding {
+		case RoundingUp, RoundingDown, RoundingNearest:
+		default:
+			return fmt.Errorf("invalid hours.rounding %q", h.Rounding)
+		}
+	default:
+		return fmt.Errorf("invalid hours.mode %q", h.Mode)
+	}
+	return nil
+}
+
+// ScheduleConfig controls how far ahead recurring schedules create lesson
+// records.
+type ScheduleConfig struct {
+	// HorizonWeeks is how many weeks ahead of today pending records are
+	// generated.
+	HorizonWeeks int `yaml:"horizon_weeks"`
+}
+
+// Validate reports settings the schedule generator cannot work with.
+func (s ScheduleConfig) Validate() error {
+	if s.HorizonWeeks <= 0 {
+		return fmt.Errorf("schedule.horizon_weeks must be positive")
+	}
+	return nil
+}
+
+type LogConfig struct {
+	// Dir holds the log files; relative paths are resolved against BaseDir.
+	Dir        string `yaml:"dir"`
+	Filename   string `yaml:"filename"`
+	Level      string `yaml:"level"`
+	MaxSizeMB  int    `yaml:"max_size_mb"`
+	MaxBackups int    `yaml:"max_backups"`
+	MaxAgeDays int    `yaml:"max_age_days"`
+	Compress   bool   `yaml:"compress"`
+	Stdout     bool   `yaml:"stdout"`
+}
+
+// FilePath returns the absolute path of the log file.
+func (l LogConfig) FilePath() string {
+	return filepath.Join(l.Dir, l.Filename)
+}
+
+// Default returns the built-in settings. They match the historical layout
+// (data/ and logs/ next to the program) but no longer depend on the working
+// directory the OS starts the program in.
+func Default() Config {
+	return Config{
+		Database: DatabaseConfig{
+			Path: filepath.Join("data", "teaching_manage.db"),
+		},
+		Backup: BackupConfig{
+			Dir:           filepath.Join("data", "backups"),
+			IntervalHours: 24,
+			Keep:          7,
+		},
+		Hours: HoursConfig{
+			Mode:        HoursModeFixed,
+			PerRecord:   1,
+			UnitMinutes: 45,
+			Rounding:    RoundingUp,
+		},
+		Schedule: ScheduleConfig{
+			HorizonWeeks: 8,
+		},
+		Log: LogConfig{
+			Dir:        "logs",
+			Filename:   "teaching_manage.log",
+			Level:      "debug",
+			MaxSizeMB:  10,
+			MaxBackups: 3,
+			Stdout:     true,
+		},
+	}
+}
+
+// BaseDir is the directory relative paths are resolved against: the
+// directory of the executable, or the working directory if that is unknown.
+func BaseDir() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return "."
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return filepath.Dir(exe)
+}
+
+// DefaultPath returns the config file location in the user config dir,
+// e.g. %AppData%\teaching_manage\config.yaml on Windows.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, AppName, "config.yaml"), nil
+}
+
+// Load reads the config file at path (DefaultPath when empty), applies
+// environment overrides and resolves relative paths. A missing file is not
+// an error; the defaults are used instead.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	if path == "" {
+		path = os.Getenv(EnvPrefix + "CONFIG")
+	}
+	if path == "" {
+		p, err := DefaultPath()
+		if err == nil {
+			path = p
+		}
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return cfg, fmt.Errorf("read config %s: %w", path, err)
+		default:
+			if err := yaml.Unmarshal(data, &cfg); err != nil {
+				return cfg, fmt.Errorf("parse config %s: %w", path, err)
+			}
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Hours.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Schedule.Validate(); err != nil {
+		return cfg, err
+	}
+
+	base := BaseDir()
+	cfg.Database.Path = resolve(base, cfg.Database.Path)
+	cfg.Log.Dir = resolve(base, cfg.Log.Dir)
+	cfg.Backup.Dir = resolve(base, cfg.Backup.Dir)
+	return cfg, nil
+}
+
+func applyEnv(cfg *Config) error {
+	strs := map[string]*string{
+		"DB_PATH":        &cfg.Database.Path,
+		"BACKUP_DIR":     &cfg.Backup.Dir,
+		"LOG_DIR":        &cfg.Log.Dir,
+		"LOG_FILENAME":   &cfg.Log.Filename,
+		"LOG_LEVEL":      &cfg.Log.Level,
+		"HOURS_MODE":     &cfg.Hours.Mode,
+		"HOURS_ROUNDING": &cfg.Hours.Rounding,
+	}
+	for key, dst := range strs {
+		if v, ok := os.LookupEnv(EnvPrefix + key); ok {
+			*dst = v
+		}
+	}
+
+	ints := map[string]*int{
+		"BACKUP_INTERVAL_HOURS":  &cfg.Backup.IntervalHours,
+		"BACKUP_KEEP":            &cfg.Backup.Keep,
+		"HOURS_PER_RECORD":       &cfg.Hours.PerRecord,
+		"HOURS_UNIT_MINUTES":     &cfg.Hours.UnitMinutes,
+		"SCHEDULE_HORIZON_WEEKS": &cfg.Schedule.HorizonWeeks,
+		"LOG_MAX_SIZE_MB":        &cfg.Log.MaxSizeMB,
+		"LOG_MAX_BACKUPS":        &cfg.Log.MaxBackups,
+		"LOG_MAX_AGE_DAYS":       &cfg.Log.MaxAgeDays,
+	}
+	for key, dst := range ints {
+		if v, ok := os.LookupEnv(EnvPrefix + key); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return fmt.Errorf("invalid %s%s: %w", EnvPrefix, key, err)
+			}
+			*dst = n
+		}
+	}
+
+	bools := map[string]*bool{
+		"LOG_COMPRESS": &cfg.Log.Compress,
+		"LOG_STDOUT":   &cfg.Log.Stdout,
+	}
+	for key, dst := range bools {
+		if v, ok := os.LookupEnv(EnvPrefix + key); ok {
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return fmt.Errorf("invalid %s%s: %w", EnvPrefix, key, err)
+			}
+			*dst = b
+		}
+	}
+	return nil
+}
+
+func resolve(base, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
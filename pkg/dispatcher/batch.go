@@ -0,0 +1,102 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"teaching_manage/pkg/errorx"
+	valdiatex "teaching_manage/pkg/valdiate"
+	"teaching_manage/pkg/wraper"
+)
+
+// Transactor runs fn inside a single transaction. The ctx passed to fn carries
+// the transaction, so every handler dispatched with it joins the transaction.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Call is one entry of a batch.
+type Call struct {
+	Route   string          `json:"route" validate:"required"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// BatchRequest is the payload accepted by DispatchBatch transports.
+type BatchRequest struct {
+	Calls []Call `json:"calls" validate:"required,min=1,dive"`
+	// Atomic runs all calls in one transaction; the first failure rolls
+	// back every call and the remaining ones are skipped.
+	Atomic bool `json:"atomic"`
+}
+
+// ErrBatchAborted is reported for calls skipped after an earlier call of an
+// atomic batch failed.
+var ErrBatchAborted = errorx.Cancelled("skipped: atomic batch aborted")
+
+// SetTransactor sets the Transactor used by atomic batches.
+func (d *Dispatcher) SetTransactor(t Transactor) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.transactor = t
+}
+
+// DispatchBatch executes calls in order and returns the wraper.Response
+// envelope of each call, wrapped in an envelope whose code is the code of the
+// first failed call. In atomic mode all calls share one transaction.
+// All calls are logged under the same request ID.
+func (d *Dispatcher) DispatchBatch(ctx context.Context, req BatchRequest) (string, error) {
+	ctx = ensureRequestID(ctx)
+	responses := make([]json.RawMessage, len(req.Calls))
+	var firstErr error
+
+	run := func(ctx context.Context) error {
+		for i, call := range req.Calls {
+			if firstErr != nil && req.Atomic {
+				responses[i] = json.RawMessage(newErrorResponse(ErrBatchAborted, ""))
+				continue
+			}
+			resp, err := d.Dispatch(ctx, call.Route, call.Payload)
+			responses[i] = json.RawMessage(resp)
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("call %d [%s]: %w", i, call.Route, err)
+			}
+		}
+		return firstErr
+	}
+
+	if req.Atomic {
+		d.mu.RLock()
+		t := d.transactor
+		d.mu.RUnlock()
+		if t == nil {
+			err := errorx.Validation("atomic batch is not supported: no transactor configured")
+			return newErrorResponse(err, ""), err
+		}
+		// the returned error is firstErr (or a commit failure), both handled below
+		if err := t.InTx(ctx, run); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	} else {
+		run(ctx)
+	}
+
+	if firstErr != nil {
+		return wraper.NewErrorResponse(CodeOf(firstErr), firstErr.Error(), responses).ToJSON(), firstErr
+	}
+	return wraper.NewSuccessResponse(responses).ToJSON(), nil
+}
+
+// DispatchBatchJSON decodes and validates a BatchRequest payload and runs it
+// through DispatchBatch.
+func (d *Dispatcher) DispatchBatchJSON(ctx context.Context, payload json.RawMessage) (string, error) {
+	var req BatchRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		err = errorx.Wrap(errorx.KindValidation, err, "failed to unmarshal JSON to object")
+		return newErrorResponse(err, ""), err
+	}
+	if err := valdiatex.ValidateStruct(&req); err != nil {
+		err = errorx.Wrap(errorx.KindValidation, err, "request validation failed: "+err.Error())
+		return newErrorResponse(err, ""), err
+	}
+	return d.DispatchBatch(ctx, req)
+}
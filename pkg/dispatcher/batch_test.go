@@ -0,0 +1,114 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"testing"
+
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/wraper"
+)
+
+type txKey struct{}
+
+// memStore is a key set whose transactions stage writes and only apply them
+// when fn succeeds.
+type memStore struct {
+	keys map[string]bool
+}
+
+func (s *memStore) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	staged := make(map[string]bool)
+	if err := fn(context.WithValue(ctx, txKey{}, staged)); err != nil {
+		return err
+	}
+	maps.Copy(s.keys, staged)
+	return nil
+}
+
+func (s *memStore) set(ctx context.Context, key string) {
+	if staged, ok := ctx.Value(txKey{}).(map[string]bool); ok {
+		staged[key] = true
+		return
+	}
+	s.keys[key] = true
+}
+
+func TestDispatchBatch(t *testing.T) {
+	calls := []Call{
+		{Route: "set", Payload: json.RawMessage(`{"key":"a"}`)},
+		{Route: "fail"},
+		{Route: "set", Payload: json.RawMessage(`{"key":"b"}`)},
+	}
+	tests := []struct {
+		name       string
+		atomic     bool
+		transactor bool
+		wantCode   int
+		// wantCodes is the response code of each call
+		wantCodes []int
+		wantKeys  []string
+	}{
+		{
+			name: "atomic rolls back", atomic: true, transactor: true,
+			wantCode:  wraper.CodeConflict,
+			wantCodes: []int{wraper.CodeSuccess, wraper.CodeConflict, wraper.CodeCancelled},
+		},
+		{
+			name: "non-atomic continues", transactor: true,
+			wantCode:  wraper.CodeConflict,
+			wantCodes: []int{wraper.CodeSuccess, wraper.CodeConflict, wraper.CodeSuccess},
+			wantKeys:  []string{"a", "b"},
+		},
+		{
+			name: "atomic without transactor", atomic: true,
+			wantCode: wraper.CodeValidation,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memStore{keys: make(map[string]bool)}
+			d := New()
+			if tt.transactor {
+				d.SetTransactor(store)
+			}
+			type setRequest struct {
+				Key string `json:"key" validate:"required"`
+			}
+			RegisterTyped(d, "set", func(ctx context.Context, req *setRequest) (string, error) {
+				store.set(ctx, req.Key)
+				return req.Key, nil
+			})
+			RegisterNoReq(d, "fail", func(context.Context) (string, error) {
+				return "", errorx.Conflict("already taken")
+			})
+
+			resp, err := d.DispatchBatch(context.Background(), BatchRequest{Calls: calls, Atomic: tt.atomic})
+			if got := CodeOf(err); got != tt.wantCode {
+				t.Fatalf("DispatchBatch() error = %v (code %d), want code %d", err, got, tt.wantCode)
+			}
+			var envelope wraper.Response[[]wraper.BaseResponse]
+			if err := json.Unmarshal([]byte(resp), &envelope); err != nil {
+				t.Fatalf("decode response %q: %v", resp, err)
+			}
+			if envelope.Code != tt.wantCode {
+				t.Errorf("batch code = %d, want %d", envelope.Code, tt.wantCode)
+			}
+			var codes []int
+			for _, r := range envelope.Data {
+				codes = append(codes, r.Code)
+			}
+			if !slices.Equal(codes, tt.wantCodes) {
+				t.Errorf("call codes = %v, want %v", codes, tt.wantCodes)
+			}
+			if tt.atomic && tt.transactor && envelope.Data[2].Message != ErrBatchAborted.Error() {
+				t.Errorf("skipped call message = %q, want %q", envelope.Data[2].Message, ErrBatchAborted.Error())
+			}
+			if keys := slices.Sorted(maps.Keys(store.keys)); !slices.Equal(keys, tt.wantKeys) {
+				t.Errorf("stored keys = %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package dispatcher
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Transport identifies how a request reached the dispatcher.
+type Transport string
+
+const (
+	TransportDesktop Transport = "desktop"
+	TransportHTTP    Transport = "http"
+)
+
+// Caller identifies who issued a request.
+type Caller struct {
+	Transport Transport `json:"transport"`
+	// Addr is the remote address for network transports, empty for the desktop.
+	Addr string `json:"addr,omitempty"`
+	// Token is the session token sent by network transports. The desktop
+	// window keeps its session on the server side instead.
+	Token string `json:"-"`
+}
+
+type callerKey struct{}
+
+type routeNameKey struct{}
+
+// WithCaller stores the caller of the request in ctx.
+func WithCaller(ctx context.Context, c Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, c)
+}
+
+// CallerOf returns the caller stored in ctx; it defaults to the desktop window.
+func CallerOf(ctx context.Context) Caller {
+	if c, ok := ctx.Value(callerKey{}).(Caller); ok {
+		return c
+	}
+	return Caller{Transport: TransportDesktop}
+}
+
+// TransportOf returns the transport of the caller stored in ctx.
+func TransportOf(ctx context.Context) Transport {
+	return CallerOf(ctx).Transport
+}
+
+func withRouteName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, routeNameKey{}, name)
+}
+
+// RouteName returns the route currently being dispatched, or "" when ctx
+// does not come from Dispatch.
+func RouteName(ctx context.Context) string {
+	name, _ := ctx.Value(routeNameKey{}).(string)
+	return name
+}
+
+// NewRequestID returns a random identifier for one dispatched request.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -5,13 +5,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
+	"runtime/debug"
 	"sync"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/logger"
 	valdiatex "teaching_manage/pkg/valdiate"
 	"teaching_manage/pkg/wraper"
 )
 
 // ErrHandlerNotFound is returned when a route/handler name is not registered.
-var ErrHandlerNotFound = errors.New("handler not found")
+var ErrHandlerNotFound = errorx.NotFound("handler not found")
+
+// ErrPanic is returned when a handler panics; the panic is recovered by Dispatch.
+var ErrPanic = errorx.Internal(nil, "internal error: handler panicked")
 
 // ErrResultTypeMismatch indicates a typed dispatch returned an unexpected result type.
 var ErrResultTypeMismatch = errors.New("result type mismatch")
@@ -29,27 +36,51 @@ func (hf HandlerFunc) Serve(ctx context.Context, payload json.RawMessage) (strin
 	return hf(ctx, payload)
 }
 
+// route is a registered handler together with its route-level middlewares.
+// reqType and resType are only known for routes registered via the typed helpers.
+type route struct {
+	handler     Handler
+	middlewares []Middleware
+	reqType     reflect.Type
+	resType     reflect.Type
+}
+
 // Dispatcher holds registered handlers
 type Dispatcher struct {
-	mu       sync.RWMutex
-	handlers map[string]Handler
+	mu          sync.RWMutex
+	handlers    map[string]route
+	middlewares []Middleware
+	transactor  Transactor
 }
 
 // New creates an empty Dispatcher.
 func New() *Dispatcher {
 	return &Dispatcher{
-		handlers: make(map[string]Handler),
+		handlers: make(map[string]route),
 	}
 }
 
+// Use appends global middlewares which wrap every route, including routes
+// registered before the call. The first middleware is the outermost one.
+func (d *Dispatcher) Use(mws ...Middleware) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.middlewares = append(d.middlewares, mws...)
+}
+
 // Register registers a handler function under a name.
 // The handler MUST have the signature: func(context.Context, *T) (R, error)
 // where `*T` is the pointer type to unmarshal the request payload into.
 // The dispatcher will store the raw handler; middlewares run at dispatch time.
-func (d *Dispatcher) Register(name string, fn HandlerFunc) error {
+// Route-level middlewares run inside the global ones registered via Use.
+func (d *Dispatcher) Register(name string, fn HandlerFunc, mws ...Middleware) error {
+	return d.register(name, route{handler: fn, middlewares: mws})
+}
+
+func (d *Dispatcher) register(name string, r route) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	d.handlers[name] = fn
+	d.handlers[name] = r
 	return nil
 }
 
@@ -61,20 +92,22 @@ func NewHandlerFunc[Req any, Res any](fn func(context.Context, *Req) (Res, error
 		req := new(Req)
 		if len(payload) > 0 {
 			if err := json.Unmarshal(payload, req); err != nil {
-				return wraper.NewBadResponse("failed to unmarshal JSON to object", "").ToJSON(), err
+				err = errorx.Wrap(errorx.KindValidation, err, "failed to unmarshal JSON to object")
+				return newErrorResponse(err, ""), err
 			}
 		}
 
 		// validate struct
 		err := valdiatex.ValidateStruct(req)
 		if err != nil {
-			return wraper.NewBadResponse("request validation failed: "+err.Error(), "").ToJSON(), err
+			err = errorx.Wrap(errorx.KindValidation, err, "request validation failed: "+err.Error())
+			return newErrorResponse(err, ""), err
 		}
 
 		// call the typed function
 		res, err := fn(ctx, req)
 		if err != nil {
-			return wraper.NewBadResponse(err.Error(), res).ToJSON(), err
+			return newErrorResponse(err, res), err
 		}
 		successResp := wraper.NewSuccessResponse(res)
 		successResp.Data = res
@@ -85,8 +118,13 @@ func NewHandlerFunc[Req any, Res any](fn func(context.Context, *Req) (Res, error
 // RegisterTyped registers a typed handler function. `fn` must be
 // `func(context.Context, *Req) (Res, error)`. The dispatcher will unmarshal
 // the incoming JSON into `*Req` and call `fn`.
-func RegisterTyped[Req any, Res any](d *Dispatcher, name string, fn func(context.Context, *Req) (Res, error)) error {
-	return d.Register(name, NewHandlerFunc(fn))
+func RegisterTyped[Req any, Res any](d *Dispatcher, name string, fn func(context.Context, *Req) (Res, error), mws ...Middleware) error {
+	return d.register(name, route{
+		handler:     NewHandlerFunc(fn),
+		middlewares: mws,
+		reqType:     reflect.TypeFor[Req](),
+		resType:     reflect.TypeFor[Res](),
+	})
 }
 
 // NewHandlerFuncNoReq wraps a typed handler func(context.Context) (Res, error)
@@ -95,7 +133,7 @@ func NewHandlerFuncNoReq[Res any](fn func(context.Context) (Res, error)) Handler
 	return HandlerFunc(func(ctx context.Context, payload json.RawMessage) (string, error) {
 		res, err := fn(ctx)
 		if err != nil {
-			return wraper.NewBadResponse(err.Error(), res).ToJSON(), err
+			return newErrorResponse(err, res), err
 		}
 		successResp := wraper.NewSuccessResponse(res)
 		successResp.Data = res
@@ -105,21 +143,56 @@ func NewHandlerFuncNoReq[Res any](fn func(context.Context) (Res, error)) Handler
 
 // RegisterNoReq registers a handler that does not take a request body.
 // fn should be func(context.Context) (Res, error).
-func RegisterNoReq[Res any](d *Dispatcher, name string, fn func(context.Context) (Res, error)) error {
-	return d.Register(name, NewHandlerFuncNoReq(fn))
+func RegisterNoReq[Res any](d *Dispatcher, name string, fn func(context.Context) (Res, error), mws ...Middleware) error {
+	return d.register(name, route{
+		handler:     NewHandlerFuncNoReq(fn),
+		middlewares: mws,
+		resType:     reflect.TypeFor[Res](),
+	})
 }
 
 // Dispatch finds a handler by name and executes it with the provided JSON payload.
-func (d *Dispatcher) Dispatch(ctx context.Context, name string, payload json.RawMessage) (string, error) {
+// A panic inside the handler or a middleware is recovered and reported as ErrPanic.
+// The handler gets its own ctx which carries a request ID (unless the caller
+// already set one) and is cancelled when Dispatch returns.
+func (d *Dispatcher) Dispatch(ctx context.Context, name string, payload json.RawMessage) (resp string, err error) {
+	ctx = ensureRequestID(ctx)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.ErrorContext(ctx, "handler panicked",
+				logger.String("route", name),
+				logger.String("panic", fmt.Sprintf("%v", r)),
+				logger.String("stack", string(debug.Stack())),
+			)
+			resp, err = newErrorResponse(ErrPanic, ""), ErrPanic
+		}
+	}()
+
 	// read handler and middleware slice under lock, then release before execution
 	d.mu.RLock()
-	h, ok := d.handlers[name]
+	r, ok := d.handlers[name]
+	global := d.middlewares
 	d.mu.RUnlock()
 
 	if !ok {
-		return wraper.NewBadResponse(fmt.Sprintf("handler [%s] not found ", name), "").ToJSON(), ErrHandlerNotFound
+		return wraper.NewErrorResponse(wraper.CodeNotFound, fmt.Sprintf("handler [%s] not found ", name), "").ToJSON(), ErrHandlerNotFound
 	}
 
-	// call the handler (fn) now that Pre hooks have completed
+	// route-level middlewares wrap the handler first, global ones wrap the result
+	h := chain(r.handler, r.middlewares)
+	h = chain(h, global)
+
+	ctx = withRouteName(ctx, name)
 	return h.Serve(ctx, payload)
 }
+
+// ensureRequestID adds a new request ID to ctx if it has none yet.
+func ensureRequestID(ctx context.Context) context.Context {
+	if logger.RequestIDFrom(ctx) != "" {
+		return ctx
+	}
+	return logger.WithRequestID(ctx, NewRequestID())
+}
@@ -0,0 +1,55 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"teaching_manage/pkg/wraper"
+)
+
+func TestDispatchErrors(t *testing.T) {
+	panicking := func(next Handler) Handler {
+		return HandlerFunc(func(context.Context, json.RawMessage) (string, error) {
+			panic("middleware bug")
+		})
+	}
+	d := New()
+	RegisterNoReq(d, "handler_panic", func(context.Context) (string, error) {
+		var m map[string]int
+		m["boom"]++
+		return "", nil
+	})
+	RegisterNoReq(d, "middleware_panic", func(context.Context) (string, error) { return "ok", nil }, panicking)
+	RegisterNoReq(d, "ok", func(context.Context) (string, error) { return "ok", nil })
+
+	tests := []struct {
+		route    string
+		wantErr  error
+		wantCode int
+	}{
+		{route: "handler_panic", wantErr: ErrPanic, wantCode: wraper.CodeInternal},
+		{route: "middleware_panic", wantErr: ErrPanic, wantCode: wraper.CodeInternal},
+		{route: "missing", wantErr: ErrHandlerNotFound, wantCode: wraper.CodeNotFound},
+		{route: "ok", wantCode: wraper.CodeSuccess},
+	}
+	for _, tt := range tests {
+		t.Run(tt.route, func(t *testing.T) {
+			resp, err := d.Dispatch(context.Background(), tt.route, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Dispatch() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := CodeOf(err); got != tt.wantCode {
+				t.Errorf("CodeOf() = %d, want %d", got, tt.wantCode)
+			}
+			var envelope wraper.BaseResponse
+			if err := json.Unmarshal([]byte(resp), &envelope); err != nil {
+				t.Fatalf("decode response %q: %v", resp, err)
+			}
+			if envelope.Code != tt.wantCode {
+				t.Errorf("response code = %d, want %d", envelope.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package dispatcher
+
+import (
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/wraper"
+)
+
+// CodeOf maps an error returned by a handler to a BaseResponse.Code.
+func CodeOf(err error) int {
+	switch errorx.KindOf(err) {
+	case "":
+		return wraper.CodeSuccess
+	case errorx.KindValidation:
+		return wraper.CodeValidation
+	case errorx.KindUnauthenticated:
+		return wraper.CodeUnauthorized
+	case errorx.KindPermissionDenied:
+		return wraper.CodeForbidden
+	case errorx.KindNotFound:
+		return wraper.CodeNotFound
+	case errorx.KindConflict:
+		return wraper.CodeConflict
+	case errorx.KindCancelled:
+		return wraper.CodeCancelled
+	default:
+		return wraper.CodeInternal
+	}
+}
+
+// newErrorResponse builds the JSON error envelope for err.
+func newErrorResponse[T any](err error, data T) string {
+	return wraper.NewErrorResponse(CodeOf(err), err.Error(), data).ToJSON()
+}
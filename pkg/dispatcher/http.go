@@ -0,0 +1,213 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/logger"
+	"teaching_manage/pkg/wraper"
+)
+
+// maxBodyBytes limits the size of a request body accepted over HTTP.
+const maxBodyBytes = 8 << 20
+
+// JSON-RPC 2.0 error codes, see https://www.jsonrpc.org/specification#error_object
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type rpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// rpcErrorData is the data of a JSON-RPC error, so RPC clients get the same
+// codes as REST ones.
+type rpcErrorData struct {
+	// Code is CodeOf(err), the code a REST call gets in its envelope
+	Code int `json:"code"`
+	// Response is the wraper.Response envelope returned by the route, if any
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// NewHTTPHandler exposes the registered routes over HTTP:
+//
+//	POST /api/{route}  body is the route payload, response is the wraper.Response envelope
+//	POST /rpc          JSON-RPC 2.0, method is the route name and params the payload;
+//	                   error data is an rpcErrorData
+//	POST /batch        a BatchRequest, see DispatchBatch
+//
+// Every call goes through Dispatch, so middlewares and error mapping are shared
+// with the desktop transport.
+func NewHTTPHandler(d *Dispatcher) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/{route}", d.serveREST)
+	mux.HandleFunc("POST /rpc", d.serveRPC)
+	mux.HandleFunc("POST /batch", d.serveBatch)
+	return mux
+}
+
+func (d *Dispatcher) serveREST(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, wraper.NewErrorResponse(wraper.CodeValidation, "failed to read request body", "").ToJSON())
+		return
+	}
+
+	ctx := WithCaller(r.Context(), httpCaller(r))
+	resp, err := d.Dispatch(ctx, r.PathValue("route"), payload)
+	writeJSON(w, httpStatusOf(err), resp)
+}
+
+func (d *Dispatcher) serveBatch(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, wraper.NewErrorResponse(wraper.CodeValidation, "failed to read request body", "").ToJSON())
+		return
+	}
+
+	ctx := WithCaller(r.Context(), httpCaller(r))
+	resp, err := d.DispatchBatchJSON(ctx, payload)
+	writeJSON(w, httpStatusOf(err), resp)
+}
+
+func (d *Dispatcher) serveRPC(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		writeRPC(w, rpcResponse{Error: &rpcError{Code: rpcParseError, Message: "failed to read request body"}})
+		return
+	}
+
+	var req rpcRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeRPC(w, rpcResponse{Error: &rpcError{Code: rpcParseError, Message: "parse error"}})
+		return
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		writeRPC(w, rpcResponse{ID: req.ID, Error: &rpcError{Code: rpcInvalidRequest, Message: "invalid request"}})
+		return
+	}
+
+	ctx := WithCaller(r.Context(), httpCaller(r))
+	resp, err := d.Dispatch(ctx, req.Method, req.Params)
+	if err != nil {
+		data := rpcErrorData{Code: CodeOf(err)}
+		if json.Valid([]byte(resp)) {
+			data.Response = json.RawMessage(resp)
+		}
+		bytes, _ := json.Marshal(data)
+		writeRPC(w, rpcResponse{ID: req.ID, Error: &rpcError{
+			Code:    rpcCodeOf(err),
+			Message: err.Error(),
+			Data:    bytes,
+		}})
+		return
+	}
+	writeRPC(w, rpcResponse{ID: req.ID, Result: json.RawMessage(resp)})
+}
+
+// httpCaller identifies the sender of r; the session token is taken from an
+// "Authorization: Bearer <token>" header.
+func httpCaller(r *http.Request) Caller {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return Caller{Transport: TransportHTTP, Addr: r.RemoteAddr, Token: strings.TrimSpace(token)}
+}
+
+// httpStatusOf maps err to the HTTP status of a REST or batch response. The
+// envelope always carries CodeOf(err); codes that are not standard HTTP
+// statuses are translated here.
+func httpStatusOf(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	switch code := CodeOf(err); code {
+	case wraper.CodeCancelled:
+		return http.StatusRequestTimeout
+	default:
+		if http.StatusText(code) == "" {
+			return http.StatusInternalServerError
+		}
+		return code
+	}
+}
+
+func rpcCodeOf(err error) int {
+	if errors.Is(err, ErrHandlerNotFound) {
+		return rpcMethodNotFound
+	}
+	if errorx.KindOf(err) == errorx.KindValidation {
+		return rpcInvalidParams
+	}
+	return rpcInternalError
+}
+
+// LoopbackAddr returns addr with its host defaulted to 127.0.0.1.
+func LoopbackAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "127.0.0.1" + addr
+	}
+	return addr
+}
+
+func writeRPC(w http.ResponseWriter, resp rpcResponse) {
+	resp.JSONRPC = "2.0"
+	if resp.ID == nil {
+		resp.ID = json.RawMessage("null")
+	}
+	bytes, err := json.Marshal(resp)
+	if err != nil {
+		logger.Error("failed to marshal json-rpc response", logger.ErrorType(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, string(bytes))
+}
+
+func writeJSON(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := io.WriteString(w, body); err != nil {
+		logger.Warn("failed to write http response", logger.ErrorType(err))
+	}
+}
+
+// ListenAndServe serves NewHTTPHandler(d) on addr until ctx is done. An addr
+// without a host, e.g. ":8080", binds to the loopback interface only; binding
+// to another interface exposes every route to the network, so only do that
+// with session auth enabled.
+func ListenAndServe(ctx context.Context, addr string, d *Dispatcher) error {
+	addr = LoopbackAddr(addr)
+	srv := &http.Server{Addr: addr, Handler: NewHTTPHandler(d)}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	logger.Info("http transport listening", logger.String("addr", addr))
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/wraper"
+)
+
+// newTestHTTPHandler returns a handler over routes that fail with err, keyed
+// by route name.
+func newTestHTTPHandler(t *testing.T, errs map[string]error) http.Handler {
+	t.Helper()
+	d := New()
+	for name, err := range errs {
+		d.Register(name, func(context.Context, json.RawMessage) (string, error) {
+			return newErrorResponse(err, ""), err
+		})
+	}
+	return NewHTTPHandler(d)
+}
+
+func TestHTTPErrorCodes(t *testing.T) {
+	errs := map[string]error{
+		"validation": errorx.Validation("bad input"),
+		"forbidden":  errorx.PermissionDenied("no"),
+		"not_found":  errorx.NotFound("missing"),
+		"conflict":   errorx.Conflict("taken"),
+		"timeout":    context.DeadlineExceeded,
+		"internal":   errorx.Internal(nil, "boom"),
+	}
+	tests := []struct {
+		route      string
+		wantStatus int
+		wantCode   int
+		wantRPC    int
+	}{
+		{route: "validation", wantStatus: http.StatusBadRequest, wantCode: wraper.CodeValidation, wantRPC: rpcInvalidParams},
+		{route: "forbidden", wantStatus: http.StatusForbidden, wantCode: wraper.CodeForbidden, wantRPC: rpcInternalError},
+		{route: "not_found", wantStatus: http.StatusNotFound, wantCode: wraper.CodeNotFound, wantRPC: rpcInternalError},
+		{route: "conflict", wantStatus: http.StatusConflict, wantCode: wraper.CodeConflict, wantRPC: rpcInternalError},
+		{route: "timeout", wantStatus: http.StatusRequestTimeout, wantCode: wraper.CodeCancelled, wantRPC: rpcInternalError},
+		{route: "internal", wantStatus: http.StatusInternalServerError, wantCode: wraper.CodeInternal, wantRPC: rpcInternalError},
+		{route: "missing", wantStatus: http.StatusNotFound, wantCode: wraper.CodeNotFound, wantRPC: rpcMethodNotFound},
+	}
+	h := newTestHTTPHandler(t, errs)
+	for _, tt := range tests {
+		t.Run(tt.route, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/"+tt.route, strings.NewReader("{}")))
+			var envelope wraper.BaseResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+				t.Fatalf("decode REST response %q: %v", rec.Body.String(), err)
+			}
+			if rec.Code != tt.wantStatus || envelope.Code != tt.wantCode {
+				t.Errorf("REST status = %d, code = %d, want %d and %d", rec.Code, envelope.Code, tt.wantStatus, tt.wantCode)
+			}
+
+			rec = httptest.NewRecorder()
+			body := `{"jsonrpc":"2.0","id":1,"method":"` + tt.route + `"}`
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body)))
+			var resp struct {
+				Error struct {
+					Code int          `json:"code"`
+					Data rpcErrorData `json:"data"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode RPC response %q: %v", rec.Body.String(), err)
+			}
+			if resp.Error.Code != tt.wantRPC || resp.Error.Data.Code != tt.wantCode {
+				t.Errorf("RPC error code = %d, data code = %d, want %d and %d",
+					resp.Error.Code, resp.Error.Data.Code, tt.wantRPC, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package dispatcher
+
+import (
+	"context"
+	"reflect"
+	"sort"
+)
+
+// RouteInfo describes a registered route and the shape of its payloads.
+// Request is empty for routes without a body; both schemas are empty for
+// routes registered through the untyped Register.
+type RouteInfo struct {
+	Name         string `json:"name"`
+	RequestType  string `json:"request_type,omitempty"`
+	ResponseType string `json:"response_type,omitempty"`
+	Request      Schema `json:"request,omitempty"`
+	Response     Schema `json:"response,omitempty"`
+
+	reqType reflect.Type
+	resType reflect.Type
+}
+
+type ListRoutesRequest struct {
+	Format string `json:"format" validate:"omitempty,oneof=json_schema typescript"`
+}
+
+type ListRoutesResponse struct {
+	Routes     []RouteInfo `json:"routes"`
+	TypeScript string      `json:"typescript,omitempty"`
+}
+
+// Routes returns every registered route sorted by name.
+func (d *Dispatcher) Routes() []RouteInfo {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	names := make([]string, 0, len(d.handlers))
+	for name := range d.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	infos := make([]RouteInfo, 0, len(names))
+	for _, name := range names {
+		r := d.handlers[name]
+		info := RouteInfo{Name: name, reqType: r.reqType, resType: r.resType}
+		if r.reqType != nil {
+			info.RequestType = r.reqType.String()
+			info.Request = JSONSchema(r.reqType)
+		}
+		if r.resType != nil {
+			info.ResponseType = r.resType.String()
+			info.Response = JSONSchema(r.resType)
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// RegisterMetaRoutes registers the `dispatcher:*` introspection routes.
+func RegisterMetaRoutes(d *Dispatcher) {
+	RegisterTyped(d, "dispatcher:list_routes", func(ctx context.Context, req *ListRoutesRequest) (ListRoutesResponse, error) {
+		resp := ListRoutesResponse{Routes: d.Routes()}
+		if req.Format == "typescript" {
+			resp.TypeScript = d.TypeScript()
+		}
+		return resp, nil
+	})
+}
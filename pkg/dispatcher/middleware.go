@@ -0,0 +1,71 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/logger"
+	"time"
+)
+
+// Middleware wraps a Handler with cross-cutting behaviour such as logging,
+// timing or permission checks. It must call next.Serve to continue the chain.
+type Middleware func(next Handler) Handler
+
+// chain wraps h so that mws[0] is the outermost middleware.
+func chain(h Handler, mws []Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Logging logs the route name, elapsed time and error (if any) of every call.
+func Logging() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, payload json.RawMessage) (string, error) {
+			start := time.Now()
+			resp, err := next.Serve(ctx, payload)
+			elapsed := time.Since(start)
+			if err != nil {
+				logger.WarnContext(ctx, "dispatch failed",
+					logger.String("route", RouteName(ctx)),
+					logger.Int64("elapsed_ms", elapsed.Milliseconds()),
+					logger.ErrorType(err),
+				)
+				return resp, err
+			}
+			logger.DebugContext(ctx, "dispatch done",
+				logger.String("route", RouteName(ctx)),
+				logger.Int64("elapsed_ms", elapsed.Milliseconds()),
+			)
+			return resp, nil
+		})
+	}
+}
+
+// DesktopOnly rejects calls that do not come from the desktop window, e.g.
+// routes which open native file dialogs.
+func DesktopOnly() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, payload json.RawMessage) (string, error) {
+			if TransportOf(ctx) != TransportDesktop {
+				err := errorx.Validation("route is only available in the desktop window")
+				return newErrorResponse(err, ""), err
+			}
+			return next.Serve(ctx, payload)
+		})
+	}
+}
+
+// Timeout gives the route a deadline of d. Handlers see it through ctx and
+// should pass ctx down to the database calls.
+func Timeout(d time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, payload json.RawMessage) (string, error) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next.Serve(ctx, payload)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"teaching_manage/pkg/wraper"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(ctx context.Context, payload json.RawMessage) (string, error) {
+				calls = append(calls, name)
+				resp, err := next.Serve(ctx, payload)
+				calls = append(calls, "/"+name)
+				return resp, err
+			})
+		}
+	}
+	d := New()
+	d.Use(record("global1"))
+	d.Register("route", func(context.Context, json.RawMessage) (string, error) {
+		calls = append(calls, "handler")
+		return "", nil
+	}, record("route1"), record("route2"))
+	// global middlewares also wrap routes registered before Use
+	d.Use(record("global2"))
+
+	if _, err := d.Dispatch(context.Background(), "route", nil); err != nil {
+		t.Fatalf("Dispatch() error = %v", err)
+	}
+	want := []string{"global1", "global2", "route1", "route2", "handler", "/route2", "/route1", "/global2", "/global1"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		// work is how long the handler runs unless ctx is done first
+		work     time.Duration
+		wantCode int
+	}{
+		{name: "deadline passes", work: time.Minute, wantCode: wraper.CodeCancelled},
+		{name: "finishes in time", work: 0, wantCode: wraper.CodeSuccess},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := New()
+			RegisterNoReq(d, "slow", func(ctx context.Context) (string, error) {
+				select {
+				case <-ctx.Done():
+					return "", ctx.Err()
+				case <-time.After(tt.work):
+					return "done", nil
+				}
+			}, Timeout(20*time.Millisecond))
+
+			start := time.Now()
+			resp, err := d.Dispatch(context.Background(), "slow", nil)
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("Dispatch() took %v, want the deadline to stop it", elapsed)
+			}
+			if got := CodeOf(err); got != tt.wantCode {
+				t.Fatalf("Dispatch() error = %v (code %d), want code %d", err, got, tt.wantCode)
+			}
+			var envelope wraper.BaseResponse
+			if err := json.Unmarshal([]byte(resp), &envelope); err != nil {
+				t.Fatalf("decode response %q: %v", resp, err)
+			}
+			if envelope.Code != tt.wantCode {
+				t.Errorf("response code = %d, want %d", envelope.Code, tt.wantCode)
+			}
+			if tt.wantCode == wraper.CodeCancelled && httpStatusOf(err) != http.StatusRequestTimeout {
+				t.Errorf("HTTP status = %d, want %d", httpStatusOf(err), http.StatusRequestTimeout)
+			}
+		})
+	}
+}
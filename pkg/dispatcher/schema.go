@@ -0,0 +1,231 @@
+package dispatcher
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema (draft 2020-12) document.
+type Schema map[string]any
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// jsonField describes one JSON property of a struct after applying the
+// encoding/json rules for tags and embedded structs.
+type jsonField struct {
+	Name      string
+	Type      reflect.Type
+	OmitEmpty bool
+	Validate  string
+}
+
+// jsonFields returns the JSON properties of struct type t, flattening
+// anonymous struct fields the same way encoding/json does.
+func jsonFields(t reflect.Type) []jsonField {
+	fields := make([]jsonField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, jsonFields(ft)...)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{
+			Name:      name,
+			Type:      ft,
+			OmitEmpty: strings.Contains(opts, "omitempty"),
+			Validate:  f.Tag.Get("validate"),
+		})
+	}
+	return fields
+}
+
+// JSONSchema builds the JSON Schema of t. `validate` tags are translated to
+// the matching JSON Schema keywords where one exists.
+func JSONSchema(t reflect.Type) Schema {
+	if t == nil {
+		return nil
+	}
+	return schemaOf(t, map[reflect.Type]bool{})
+}
+
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Schema{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": schemaOf(t.Elem(), visiting)}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": schemaOf(t.Elem(), visiting)}
+	case reflect.Struct:
+		// recursive types are rare in DTOs; stop instead of looping forever
+		if visiting[t] {
+			return Schema{"type": "object"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		props := Schema{}
+		required := []string{}
+		for _, f := range jsonFields(t) {
+			ps := schemaOf(f.Type, visiting)
+			if applyValidateTag(ps, f.Type, f.Validate) {
+				required = append(required, f.Name)
+			}
+			props[f.Name] = ps
+		}
+		s := Schema{"type": "object", "properties": props}
+		if t.Name() != "" {
+			s["title"] = t.Name()
+		}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	default:
+		return Schema{}
+	}
+}
+
+// applyValidateTag adds the keywords for a go-playground/validator tag to s
+// and reports whether the field is required. Rules after `dive` apply to the
+// elements of a slice.
+func applyValidateTag(s Schema, t reflect.Type, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	required := false
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		key, param, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "dive":
+			if items, ok := s["items"].(Schema); ok {
+				applyValidateTag(items, t.Elem(), strings.Join(rules[i+1:], ","))
+			}
+			return required
+		case "oneof":
+			values := strings.Fields(param)
+			enum := make([]any, 0, len(values))
+			for _, v := range values {
+				enum = append(enum, enumValue(t, v))
+			}
+			s["enum"] = enum
+		case "min", "max", "gte", "lte", "gt", "lt":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			applyBound(s, t, key, n)
+		case "ne":
+			s["not"] = Schema{"const": enumValue(t, param)}
+		case "datetime":
+			switch param {
+			case "2006-01-02":
+				s["format"] = "date"
+			case "15:04":
+				s["pattern"] = `^\d{2}:\d{2}$`
+			default:
+				s["description"] = "Go time layout " + param
+			}
+		}
+	}
+	return required
+}
+
+func applyBound(s Schema, t reflect.Type, key string, n float64) {
+	switch t.Kind() {
+	case reflect.String:
+		switch key {
+		case "min", "gte":
+			s["minLength"] = n
+		case "max", "lte":
+			s["maxLength"] = n
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		switch key {
+		case "min", "gte":
+			s["minItems"] = n
+		case "max", "lte":
+			s["maxItems"] = n
+		}
+	default:
+		switch key {
+		case "min", "gte":
+			s["minimum"] = n
+		case "max", "lte":
+			s["maximum"] = n
+		case "gt":
+			s["exclusiveMinimum"] = n
+		case "lt":
+			s["exclusiveMaximum"] = n
+		}
+	}
+}
+
+func enumValue(t reflect.Type, v string) any {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
@@ -0,0 +1,183 @@
+package dispatcher
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// tsGenerator collects named struct types and renders them as TypeScript
+// interfaces. Types from different packages sharing a name are prefixed with
+// their package name.
+type tsGenerator struct {
+	names map[reflect.Type]string
+	used  map[string]reflect.Type
+	order []reflect.Type
+}
+
+func newTSGenerator() *tsGenerator {
+	return &tsGenerator{
+		names: make(map[reflect.Type]string),
+		used:  make(map[string]reflect.Type),
+	}
+}
+
+// TypeScript renders the request and response types of every typed route as
+// TypeScript interfaces, followed by a `Routes` map from route name to its
+// request/response pair.
+func (d *Dispatcher) TypeScript() string {
+	routes := d.Routes()
+	g := newTSGenerator()
+
+	var routeLines []string
+	for _, r := range routes {
+		req := "void"
+		if r.reqType != nil {
+			req = g.typeOf(r.reqType)
+		}
+		res := "unknown"
+		if r.resType != nil {
+			res = g.typeOf(r.resType)
+		}
+		routeLines = append(routeLines, fmt.Sprintf("  %q: { request: %s; response: %s }", r.Name, req, res))
+	}
+
+	var b strings.Builder
+	b.WriteString("// Code generated by teaching_manage -gen-ts. DO NOT EDIT.\n\n")
+	// rendering a type may register further nested types, so walk by index
+	for i := 0; i < len(g.order); i++ {
+		g.writeInterface(&b, g.order[i])
+	}
+	b.WriteString("export interface Routes {\n")
+	for _, line := range routeLines {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func (g *tsGenerator) typeOf(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return "string"
+	case t == rawMessageType:
+		return "unknown"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string"
+		}
+		return g.typeOf(t.Elem()) + "[]"
+	case reflect.Map:
+		return fmt.Sprintf("Record<string, %s>", g.typeOf(t.Elem()))
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.inlineStruct(t)
+		}
+		return g.nameOf(t)
+	default:
+		return "unknown"
+	}
+}
+
+func (g *tsGenerator) nameOf(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := tsIdent(t.Name())
+	if other, ok := g.used[name]; ok && other != t {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = tsIdent(pkg) + name
+	}
+	g.names[t] = name
+	g.used[name] = t
+	g.order = append(g.order, t)
+	return name
+}
+
+func (g *tsGenerator) inlineStruct(t reflect.Type) string {
+	var parts []string
+	for _, f := range jsonFields(t) {
+		parts = append(parts, g.field(f))
+	}
+	return "{ " + strings.Join(parts, "; ") + " }"
+}
+
+func (g *tsGenerator) field(f jsonField) string {
+	opt := ""
+	if f.OmitEmpty {
+		opt = "?"
+	}
+	typ := g.typeOf(f.Type)
+	if enum := oneofValues(f.Validate); enum != nil && f.Type.Kind() == reflect.String {
+		quoted := make([]string, len(enum))
+		for i, v := range enum {
+			quoted[i] = fmt.Sprintf("%q", v)
+		}
+		typ = strings.Join(quoted, " | ")
+	}
+	return fmt.Sprintf("%s%s: %s", tsProp(f.Name), opt, typ)
+}
+
+// oneofValues returns the values of a top-level `oneof` validate rule.
+func oneofValues(tag string) []string {
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "dive" {
+			return nil
+		}
+		if param, ok := strings.CutPrefix(rule, "oneof="); ok {
+			return strings.Fields(param)
+		}
+	}
+	return nil
+}
+
+func (g *tsGenerator) writeInterface(b *strings.Builder, t reflect.Type) {
+	fmt.Fprintf(b, "export interface %s {\n", g.names[t])
+	for _, f := range jsonFields(t) {
+		b.WriteString("  " + g.field(f) + "\n")
+	}
+	b.WriteString("}\n\n")
+}
+
+// tsIdent turns a Go type name such as `Response[teaching_manage/x.Foo]`
+// into a valid TypeScript identifier.
+func tsIdent(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			if upper && r >= 'a' && r <= 'z' {
+				r -= 'a' - 'A'
+			}
+			b.WriteRune(r)
+			upper = false
+		default:
+			upper = true
+		}
+	}
+	return b.String()
+}
+
+func tsProp(name string) string {
+	for _, r := range name {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return fmt.Sprintf("%q", name)
+		}
+	}
+	return name
+}
@@ -0,0 +1,104 @@
+package errorx
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Kind classifies an error so the dispatcher can map it to a response code.
+type Kind string
+
+const (
+	KindValidation       Kind = "validation"
+	KindUnauthenticated  Kind = "unauthenticated"
+	KindPermissionDenied Kind = "permission_denied"
+	KindNotFound         Kind = "not_found"
+	KindConflict         Kind = "conflict"
+	KindCancelled        Kind = "cancelled"
+	KindInternal         Kind = "internal"
+)
+
+// Error is a business error carrying a Kind and a user facing message.
+// The wrapped Err (if any) is kept for logging and errors.Is/As.
+type Error struct {
+	Kind Kind
+	Msg  string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Msg != "" {
+		return e.Msg
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Kind)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New creates an Error of the given kind.
+func New(kind Kind, msg string) *Error {
+	return &Error{Kind: kind, Msg: msg}
+}
+
+// Wrap creates an Error of the given kind that wraps err.
+func Wrap(kind Kind, err error, msg string) *Error {
+	return &Error{Kind: kind, Msg: msg, Err: err}
+}
+
+func Validation(msg string) *Error {
+	return New(KindValidation, msg)
+}
+
+func Unauthenticated(msg string) *Error {
+	return New(KindUnauthenticated, msg)
+}
+
+func PermissionDenied(msg string) *Error {
+	return New(KindPermissionDenied, msg)
+}
+
+func NotFound(msg string) *Error {
+	return New(KindNotFound, msg)
+}
+
+func Conflict(msg string) *Error {
+	return New(KindConflict, msg)
+}
+
+func Cancelled(msg string) *Error {
+	return New(KindCancelled, msg)
+}
+
+func Internal(err error, msg string) *Error {
+	return Wrap(KindInternal, err, msg)
+}
+
+// KindOf reports the Kind of err. Plain gorm and context errors are
+// classified as well, a passed deadline counts as cancelled; everything else
+// is internal.
+func KindOf(err error) Kind {
+	if err == nil {
+		return ""
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return KindNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return KindConflict
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return KindCancelled
+	default:
+		return KindInternal
+	}
+}
@@ -0,0 +1,34 @@
+package errorx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Kind
+	}{
+		{name: "nil", err: nil, want: ""},
+		{name: "typed", err: Conflict("taken"), want: KindConflict},
+		{name: "wrapped typed", err: fmt.Errorf("call 0: %w", NotFound("missing")), want: KindNotFound},
+		{name: "record not found", err: gorm.ErrRecordNotFound, want: KindNotFound},
+		{name: "duplicated key", err: gorm.ErrDuplicatedKey, want: KindConflict},
+		{name: "canceled", err: context.Canceled, want: KindCancelled},
+		{name: "deadline exceeded", err: fmt.Errorf("query: %w", context.DeadlineExceeded), want: KindCancelled},
+		{name: "plain", err: errors.New("disk full"), want: KindInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KindOf(tt.err); got != tt.want {
+				t.Errorf("KindOf(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
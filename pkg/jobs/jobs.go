@@ -0,0 +1,246 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/logger"
+	"time"
+)
+
+// Events emitted to the frontend. The payload of every event is a Snapshot.
+const (
+	EventProgress = "jobs:progress"
+	EventDone     = "jobs:done"
+)
+
+// finishedJobTTL is how long finished jobs stay queryable.
+const finishedJobTTL = time.Hour
+
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+func (s Status) Finished() bool {
+	return s != StatusRunning
+}
+
+var ErrJobNotFound = errorx.NotFound("job not found")
+
+// Snapshot is a point-in-time copy of a job's state.
+type Snapshot struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Done    int    `json:"done"`
+	Total   int    `json:"total"`
+	Message string `json:"message"`
+	// Owner identifies who started the job, see OwnerFunc.
+	Owner     string `json:"owner,omitempty"`
+	Result    any    `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// ProgressFunc reports that done of total units are finished. It is safe to
+// call a nil ProgressFunc through Report.
+type ProgressFunc func(done, total int, msg string)
+
+// Report calls p if it is not nil.
+func (p ProgressFunc) Report(done, total int, msg string) {
+	if p != nil {
+		p(done, total, msg)
+	}
+}
+
+// Func is the work executed by a job. It must stop when ctx is cancelled.
+type Func func(ctx context.Context, progress ProgressFunc) (any, error)
+
+// DetachFunc copies the request-scoped values a job needs, such as the logged
+// in user, from the request context from to the job context to.
+type DetachFunc func(from, to context.Context) context.Context
+
+// OwnerFunc returns who made the request in ctx, empty if unknown.
+type OwnerFunc func(ctx context.Context) string
+
+// Emitter pushes a job event to the frontend.
+type Emitter func(event string, data any)
+
+type job struct {
+	snap   Snapshot
+	cancel context.CancelFunc
+}
+
+// Manager runs jobs in goroutines and keeps their state in memory.
+type Manager struct {
+	mu     sync.Mutex
+	jobs   map[string]*job
+	emit   Emitter
+	detach DetachFunc
+	owner  OwnerFunc
+}
+
+// NewManager returns a Manager that carries request values over to jobs with
+// detach and records the owner of each job with owner. Both may be nil.
+func NewManager(detach DetachFunc, owner OwnerFunc) *Manager {
+	return &Manager{jobs: make(map[string]*job), detach: detach, owner: owner}
+}
+
+// SetEmitter sets where job events are pushed; nil disables events.
+func (m *Manager) SetEmitter(e Emitter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.emit = e
+}
+
+// Start runs fn in a new goroutine and returns the job ID immediately.
+// The job outlives the request that started it, so only the request ID and
+// the values copied by the DetachFunc are taken over from ctx.
+func (m *Manager) Start(ctx context.Context, name string, fn Func) string {
+	base := logger.WithRequestID(context.Background(), logger.RequestIDFrom(ctx))
+	if m.detach != nil {
+		base = m.detach(ctx, base)
+	}
+	owner := ""
+	if m.owner != nil {
+		owner = m.owner(ctx)
+	}
+	ctx, cancel := context.WithCancel(base)
+	now := time.Now().UnixMilli()
+	j := &job{
+		snap: Snapshot{
+			ID:        newID(),
+			Name:      name,
+			Status:    StatusRunning,
+			Owner:     owner,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		cancel: cancel,
+	}
+
+	m.mu.Lock()
+	m.pruneLocked()
+	m.jobs[j.snap.ID] = j
+	m.mu.Unlock()
+
+	logger.InfoContext(ctx, "job started", logger.String("job_id", j.snap.ID), logger.String("job_name", name))
+	go m.run(ctx, j, fn)
+	return j.snap.ID
+}
+
+func (m *Manager) run(ctx context.Context, j *job, fn Func) {
+	defer j.cancel()
+
+	var (
+		res any
+		err error
+	)
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.ErrorContext(ctx, "job panicked",
+					logger.String("job_id", j.snap.ID),
+					logger.String("panic", fmt.Sprintf("%v", r)),
+					logger.String("stack", string(debug.Stack())),
+				)
+				err = fmt.Errorf("internal error: job panicked")
+			}
+		}()
+		res, err = fn(ctx, func(done, total int, msg string) {
+			m.update(j, EventProgress, func(s *Snapshot) {
+				s.Done, s.Total, s.Message = done, total, msg
+			})
+		})
+	}()
+
+	m.update(j, EventDone, func(s *Snapshot) {
+		switch {
+		case err == nil:
+			s.Status = StatusSucceeded
+			s.Result = res
+		case errors.Is(err, context.Canceled) || errorx.KindOf(err) == errorx.KindCancelled:
+			s.Status = StatusCancelled
+			s.Error = err.Error()
+		default:
+			s.Status = StatusFailed
+			s.Result = res
+			s.Error = err.Error()
+		}
+	})
+
+	if err != nil {
+		logger.WarnContext(ctx, "job finished with error", logger.String("job_id", j.snap.ID), logger.ErrorType(err))
+		return
+	}
+	logger.InfoContext(ctx, "job finished", logger.String("job_id", j.snap.ID))
+}
+
+// update applies fn to the job state and emits event with the new snapshot.
+func (m *Manager) update(j *job, event string, fn func(s *Snapshot)) {
+	m.mu.Lock()
+	fn(&j.snap)
+	j.snap.UpdatedAt = time.Now().UnixMilli()
+	snap := j.snap
+	emit := m.emit
+	m.mu.Unlock()
+
+	if emit != nil {
+		emit(event, snap)
+	}
+}
+
+// Get returns the current state of a job.
+func (m *Manager) Get(id string) (Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Snapshot{}, ErrJobNotFound
+	}
+	return j.snap, nil
+}
+
+// Cancel asks a running job to stop. The job reports StatusCancelled once its
+// Func has returned.
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if j.snap.Status.Finished() {
+		return errorx.Conflict(fmt.Sprintf("job already %s", j.snap.Status))
+	}
+	j.cancel()
+	return nil
+}
+
+func (m *Manager) pruneLocked() {
+	deadline := time.Now().Add(-finishedJobTTL).UnixMilli()
+	for id, j := range m.jobs {
+		if j.snap.Status.Finished() && j.snap.UpdatedAt < deadline {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type userKey struct{}
+
+func TestStartDetachesContext(t *testing.T) {
+	detach := func(from, to context.Context) context.Context {
+		return context.WithValue(to, userKey{}, from.Value(userKey{}))
+	}
+	owner := func(ctx context.Context) string {
+		user, _ := ctx.Value(userKey{}).(string)
+		return user
+	}
+	tests := []struct {
+		name      string
+		detach    DetachFunc
+		owner     OwnerFunc
+		wantUser  any
+		wantOwner string
+	}{
+		{name: "hooks", detach: detach, owner: owner, wantUser: "alice", wantOwner: "alice"},
+		{name: "no hooks", wantUser: nil, wantOwner: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(tt.detach, tt.owner)
+			reqCtx, cancelReq := context.WithCancel(context.WithValue(context.Background(), userKey{}, "alice"))
+			seen := make(chan any, 1)
+			id := m.Start(reqCtx, "test", func(ctx context.Context, progress ProgressFunc) (any, error) {
+				// the job must not end with the request that started it
+				cancelReq()
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(10 * time.Millisecond):
+				}
+				seen <- ctx.Value(userKey{})
+				return "ok", nil
+			})
+
+			if got := <-seen; got != tt.wantUser {
+				t.Fatalf("job context user = %v, want %v", got, tt.wantUser)
+			}
+			snap := waitFinished(t, m, id)
+			if snap.Status != StatusSucceeded {
+				t.Fatalf("job status = %s (%s), want %s", snap.Status, snap.Error, StatusSucceeded)
+			}
+			if snap.Owner != tt.wantOwner {
+				t.Fatalf("job owner = %q, want %q", snap.Owner, tt.wantOwner)
+			}
+		})
+	}
+}
+
+func TestCancel(t *testing.T) {
+	m := NewManager(nil, nil)
+	id := m.Start(context.Background(), "test", func(ctx context.Context, progress ProgressFunc) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err := m.Cancel(id); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if snap := waitFinished(t, m, id); snap.Status != StatusCancelled {
+		t.Fatalf("job status = %s, want %s", snap.Status, StatusCancelled)
+	}
+	if err := m.Cancel(id); err == nil {
+		t.Fatalf("Cancel() of a finished job returned no error")
+	}
+	if err := m.Cancel("missing"); err != ErrJobNotFound {
+		t.Fatalf("Cancel() of an unknown job error = %v, want ErrJobNotFound", err)
+	}
+}
+
+func waitFinished(t *testing.T, m *Manager, id string) Snapshot {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		snap, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if snap.Status.Finished() {
+			return snap
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return Snapshot{}
+}
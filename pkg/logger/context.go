@@ -0,0 +1,41 @@
+package logger
+
+import "context"
+
+type requestIDKey struct{}
+
+type loggerKey struct{}
+
+// WithRequestID stores the request ID used to correlate log lines of one operation.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the request ID stored in ctx, or "".
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextFields prepends the fields carried by ctx to args.
+func contextFields(ctx context.Context, args []Field) []Field {
+	id := RequestIDFrom(ctx)
+	if id == "" {
+		return args
+	}
+	return append([]Field{String("request_id", id)}, args...)
+}
+
+// NewContext stores l in ctx so FromContext returns it further down the call chain.
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger stored by NewContext, or a child of the
+// global logger carrying the request ID of ctx.
+func FromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(loggerKey{}).(Logger); ok {
+		return l
+	}
+	return GetLogger().With(contextFields(ctx, nil)...)
+}
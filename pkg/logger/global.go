@@ -1,6 +1,9 @@
 package logger
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 // globalLogger 默认为 NopLogger，确保未初始化时调用不会 Panic
 var (
@@ -22,6 +25,11 @@ func GetLogger() Logger {
 	return globalLogger
 }
 
+// With 返回携带固定字段的子 Logger
+func With(args ...Field) Logger {
+	return GetLogger().With(args...)
+}
+
 // --- 静态代理方法，方便直接调用 ---
 
 func Debug(msg string, args ...Field) {
@@ -39,3 +47,21 @@ func Warn(msg string, args ...Field) {
 func Error(msg string, args ...Field) {
 	GetLogger().Error(msg, args...)
 }
+
+// --- 携带 context 中 request_id 的版本 ---
+
+func DebugContext(ctx context.Context, msg string, args ...Field) {
+	GetLogger().Debug(msg, contextFields(ctx, args)...)
+}
+
+func InfoContext(ctx context.Context, msg string, args ...Field) {
+	GetLogger().Info(msg, contextFields(ctx, args)...)
+}
+
+func WarnContext(ctx context.Context, msg string, args ...Field) {
+	GetLogger().Warn(msg, contextFields(ctx, args)...)
+}
+
+func ErrorContext(ctx context.Context, msg string, args ...Field) {
+	GetLogger().Error(msg, contextFields(ctx, args)...)
+}
@@ -22,3 +22,7 @@ func (n *NopLogger) Warn(_ string, _ ...Field) {
 func (n *NopLogger) Error(_ string, _ ...Field) {
 
 }
+
+func (n *NopLogger) With(_ ...Field) Logger {
+	return n
+}
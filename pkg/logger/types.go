@@ -1,5 +1,7 @@
 package logger
 
+import "time"
+
 type Field struct {
 	Key string
 	Val any
@@ -10,6 +12,8 @@ type Logger interface {
 	Info(msg string, args ...Field)
 	Warn(msg string, args ...Field)
 	Error(msg string, args ...Field)
+	// With returns a child logger which adds args to every entry.
+	With(args ...Field) Logger
 }
 
 func ErrorType(err error) Field {
@@ -50,3 +54,24 @@ func String(key string, str string) Field {
 		Val: str,
 	}
 }
+
+func Bool(key string, b bool) Field {
+	return Field{Key: key, Val: b}
+}
+
+func Time(key string, t time.Time) Field {
+	return Field{Key: key, Val: t}
+}
+
+func Duration(key string, d time.Duration) Field {
+	return Field{Key: key, Val: d}
+}
+
+func Strings(key string, strs []string) Field {
+	return Field{Key: key, Val: strs}
+}
+
+// Any logs val with reflection based encoding; prefer a typed constructor.
+func Any(key string, val any) Field {
+	return Field{Key: key, Val: val}
+}
@@ -4,6 +4,9 @@ import "go.uber.org/zap"
 
 type ZapLogger struct {
 	zapLog *zap.Logger
+	// child is true for loggers created by With; they are called directly
+	// instead of through the package level functions, one frame less.
+	child bool
 }
 
 func NewZapLogger(l *zap.Logger) *ZapLogger {
@@ -35,3 +38,11 @@ func (z *ZapLogger) Warn(format string, args ...Field) {
 func (z *ZapLogger) Error(format string, args ...Field) {
 	z.zapLog.Error(format, z.toArgs(args)...)
 }
+
+func (z *ZapLogger) With(args ...Field) Logger {
+	l := z.zapLog.With(z.toArgs(args)...)
+	if !z.child {
+		l = l.WithOptions(zap.AddCallerSkip(-1))
+	}
+	return &ZapLogger{zapLog: l, child: true}
+}
@@ -0,0 +1,13 @@
+package wraper
+
+// Response codes returned in BaseResponse.Code.
+const (
+	CodeSuccess      = 200
+	CodeValidation   = 400
+	CodeUnauthorized = 401
+	CodeForbidden    = 403
+	CodeNotFound     = 404
+	CodeConflict     = 409
+	CodeCancelled    = 499
+	CodeInternal     = 500
+)
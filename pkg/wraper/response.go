@@ -22,7 +22,7 @@ func (r Response[T]) ToJSON() string {
 func NewSuccessResponse[T any](data T) Response[T] {
 	return Response[T]{
 		BaseResponse: BaseResponse{
-			Code:    200,
+			Code:    CodeSuccess,
 			Message: "Success",
 		},
 		Data: data,
@@ -30,9 +30,13 @@ func NewSuccessResponse[T any](data T) Response[T] {
 }
 
 func NewBadResponse[T any](message string, data T) Response[T] {
+	return NewErrorResponse(CodeInternal, message, data)
+}
+
+func NewErrorResponse[T any](code int, message string, data T) Response[T] {
 	return Response[T]{
 		BaseResponse: BaseResponse{
-			Code:    500,
+			Code:    code,
 			Message: message,
 		},
 		Data: data,
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"teaching_manage/dao"
+	"teaching_manage/entity"
+)
+
+type AuditRepository interface {
+	CreateAuditLog(ctx context.Context, log entity.AuditLog) error
+	QueryAuditLogs(ctx context.Context, filter dao.AuditFilter, offset int, limit int) ([]entity.AuditLog, int64, error)
+}
+
+type AuditRepositoryImpl struct {
+	dao dao.AuditDAO
+}
+
+func NewAuditRepository(dao dao.AuditDAO) AuditRepository {
+	return &AuditRepositoryImpl{dao: dao}
+}
+
+func (ar *AuditRepositoryImpl) CreateAuditLog(ctx context.Context, log entity.AuditLog) error {
+	return ar.dao.CreateAuditLog(ctx, &dao.AuditLog{
+		Operation:  log.Operation,
+		Entity:     log.Entity,
+		EntityID:   log.EntityID,
+		BeforeJSON: log.Before,
+		AfterJSON:  log.After,
+		RequestID:  log.RequestID,
+		Route:      log.Route,
+		Actor:      log.Actor,
+	})
+}
+
+func (ar *AuditRepositoryImpl) QueryAuditLogs(ctx context.Context, filter dao.AuditFilter, offset int, limit int) ([]entity.AuditLog, int64, error) {
+	logs, total, err := ar.dao.QueryAuditLogs(ctx, filter, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := make([]entity.AuditLog, 0, len(logs))
+	for _, l := range logs {
+		result = append(result, entity.AuditLog{
+			ID:        l.ID,
+			CreatedAt: l.CreatedAt,
+			Operation: l.Operation,
+			Entity:    l.Entity,
+			EntityID:  l.EntityID,
+			Before:    l.BeforeJSON,
+			After:     l.AfterJSON,
+			RequestID: l.RequestID,
+			Route:     l.Route,
+			Actor:     l.Actor,
+		})
+	}
+	return result, total, nil
+}
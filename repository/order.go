@@ -7,7 +7,7 @@ import (
 )
 
 type OrderRepository interface {
-	CreateOrder(ctx context.Context, order entity.Order) error
+	CreateOrder(ctx context.Context, order *entity.Order) error
 	GetOrdersByStudentID(ctx context.Context, studentID uint, offset int, limit int) ([]entity.Order, int64, error)
 }
 
@@ -20,7 +20,7 @@ func NewOrderRepository(dao dao.OrderDAO) OrderRepository {
 	return &OrderRepositoryImpl{dao: dao}
 }
 
-func (or *OrderRepositoryImpl) CreateOrder(ctx context.Context, order entity.Order) error {
+func (or *OrderRepositoryImpl) CreateOrder(ctx context.Context, order *entity.Order) error {
 	o := dao.Order{
 		StudentID: order.Student.ID,
 		Hours:     order.Hours,
@@ -28,7 +28,13 @@ func (or *OrderRepositoryImpl) CreateOrder(ctx context.Context, order entity.Ord
 		Active:    order.Active,
 	}
 
-	return (or.dao).CreateOrder(ctx, o)
+	if err := (or.dao).CreateOrder(ctx, &o); err != nil {
+		return err
+	}
+	order.Id = o.ID
+	order.CreatedAt = o.CreatedAt
+	order.UpdatedAt = o.UpdatedAt
+	return nil
 }
 
 func (or *OrderRepositoryImpl) GetOrdersByStudentID(ctx context.Context, studentID uint, offset int, limit int) ([]entity.Order, int64, error) {
@@ -4,16 +4,26 @@ import (
 	"context"
 	"teaching_manage/dao"
 	"teaching_manage/entity"
+	"time"
 )
 
 type RecordRepository interface {
 	CreateRecord(ctx context.Context, record *entity.Record) error
+	UpdateRecord(ctx context.Context, record entity.Record) error
 	GetRecordList(ctx context.Context, stuKey string, teachKey string,
-		startDate string, endDate string, offset int, limit int) ([]entity.Record, int64, int64, error)
+		startDate string, endDate string, attendance string, offset int, limit int) ([]entity.Record, int64, int64, error)
 	GetAllPendingRecordList(ctx context.Context) ([]entity.Record, error)
 	ActivateRecord(ctx context.Context, recordID uint) error
+	DeactivateRecord(ctx context.Context, recordID uint) error
 	GetRecordByID(ctx context.Context, d uint) (entity.Record, error)
 	DeleteRecordByID(ctx context.Context, id uint) error
+	GetRecordsOfDate(ctx context.Context, date time.Time, studentID uint, teacherID uint) ([]entity.Record, error)
+	GetRecordsOfSchedule(ctx context.Context, scheduleID uint, from time.Time) ([]entity.Record, error)
+	GetRecordsOfSession(ctx context.Context, sessionID uint) ([]entity.Record, error)
+	CountMakeupRecords(ctx context.Context, originalID uint, excludeID uint) (int64, error)
+	GetDeletedRecordsOfSchedule(ctx context.Context, scheduleID uint, from time.Time) ([]entity.Record, error)
+	CountMakeupReferences(ctx context.Context, originalID uint) (int64, error)
+	PurgeRecordByID(ctx context.Context, id uint) error
 }
 
 type RecordRepositoryImpl struct {
@@ -31,14 +41,49 @@ func (r *RecordRepositoryImpl) CreateRecord(ctx context.Context, record *entity.
 		TeachingDate: record.TeachingDate,
 		StartTime:    record.StartTime,
 		EndTime:      record.EndTime,
+		Hours:        record.Hours,
 		Remark:       record.Remark,
+		Attendance:   record.Attendance,
 	}
-	return r.recordDao.CreateRecord(ctx, recordModel)
+	if record.ScheduleID != 0 {
+		recordModel.ScheduleID = &record.ScheduleID
+	}
+	if record.MakeupForID != 0 {
+		recordModel.MakeupForID = &record.MakeupForID
+	}
+	if record.SessionID != 0 {
+		recordModel.SessionID = &record.SessionID
+	}
+	if err := r.recordDao.CreateRecord(ctx, &recordModel); err != nil {
+		return err
+	}
+	record.ID = recordModel.ID
+	record.CreatedAt = recordModel.CreatedAt
+	record.UpdatedAt = recordModel.UpdatedAt
+	return nil
+}
+
+func (r *RecordRepositoryImpl) UpdateRecord(ctx context.Context, record entity.Record) error {
+	recordModel := dao.Record{
+		StudentID:    record.Student.ID,
+		TeacherID:    record.Teacher.ID,
+		TeachingDate: record.TeachingDate,
+		StartTime:    record.StartTime,
+		EndTime:      record.EndTime,
+		Hours:        record.Hours,
+		Remark:       record.Remark,
+		Attendance:   record.Attendance,
+	}
+	recordModel.ID = record.ID
+	if record.MakeupForID != 0 {
+		recordModel.MakeupForID = &record.MakeupForID
+	}
+	return r.recordDao.UpdateRecord(ctx, &recordModel)
 }
 
 func (r *RecordRepositoryImpl) GetRecordList(ctx context.Context, stuKey string, teachKey string,
-	startDate string, endDate string, offset int, limit int) ([]entity.Record, int64, int64, error) {
-	records, total, pendingTotal, err := r.recordDao.GetRecordList(ctx, stuKey, teachKey, startDate, endDate, offset, limit)
+	startDate string, endDate string, attendance string, offset int, limit int) ([]entity.Record, int64, int64, error) {
+	records, total, pendingTotal, err := r.recordDao.GetRecordList(ctx, stuKey, teachKey, startDate, endDate, attendance, offset, limit)
 	if err != nil {
 		return nil, 0, 0, err
 	}
@@ -54,7 +99,12 @@ func (r *RecordRepositoryImpl) GetRecordList(ctx context.Context, stuKey string,
 			StartTime:    rec.StartTime,
 			EndTime:      rec.EndTime,
 			Active:       rec.Active,
+			Hours:        rec.Hours,
 			Remark:       rec.Remark,
+			ScheduleID:   nullableID(rec.ScheduleID),
+			Attendance:   rec.Attendance,
+			MakeupForID:  nullableID(rec.MakeupForID),
+			SessionID:    nullableID(rec.SessionID),
 		})
 	}
 	return result, total, pendingTotal, nil
@@ -76,7 +126,12 @@ func (r *RecordRepositoryImpl) GetRecordByID(ctx context.Context, d uint) (entit
 		StartTime:    dbRecord.StartTime,
 		EndTime:      dbRecord.EndTime,
 		Active:       dbRecord.Active,
+		Hours:        dbRecord.Hours,
 		Remark:       dbRecord.Remark,
+		ScheduleID:   nullableID(dbRecord.ScheduleID),
+		Attendance:   dbRecord.Attendance,
+		MakeupForID:  nullableID(dbRecord.MakeupForID),
+		SessionID:    nullableID(dbRecord.SessionID),
 	}, nil
 }
 
@@ -84,6 +139,10 @@ func (r *RecordRepositoryImpl) ActivateRecord(ctx context.Context, recordID uint
 	return r.recordDao.ActivateRecord(ctx, recordID)
 }
 
+func (r *RecordRepositoryImpl) DeactivateRecord(ctx context.Context, recordID uint) error {
+	return r.recordDao.DeactivateRecord(ctx, recordID)
+}
+
 func (r *RecordRepositoryImpl) DeleteRecordByID(ctx context.Context, id uint) error {
 	return r.recordDao.DeleteRecordByID(ctx, id)
 }
@@ -105,8 +164,137 @@ func (r *RecordRepositoryImpl) GetAllPendingRecordList(ctx context.Context) ([]e
 			StartTime:    rec.StartTime,
 			EndTime:      rec.EndTime,
 			Active:       rec.Active,
+			Hours:        rec.Hours,
+			Remark:       rec.Remark,
+			ScheduleID:   nullableID(rec.ScheduleID),
+			Attendance:   rec.Attendance,
+			MakeupForID:  nullableID(rec.MakeupForID),
+			SessionID:    nullableID(rec.SessionID),
+		})
+	}
+	return result, nil
+}
+
+func (r *RecordRepositoryImpl) GetRecordsOfDate(ctx context.Context, date time.Time, studentID uint, teacherID uint) ([]entity.Record, error) {
+	dbRecords, err := r.recordDao.GetRecordsOfDate(ctx, date, studentID, teacherID)
+	if err != nil {
+		return nil, err
+	}
+	var result []entity.Record
+	for _, rec := range dbRecords {
+		result = append(result, entity.Record{
+			ID:           rec.ID,
+			CreatedAt:    rec.CreatedAt,
+			UpdatedAt:    rec.UpdatedAt,
+			Student:      entity.Student{ID: rec.StudentID, Name: rec.Student.Name, DeletedAt: rec.Student.DeletedAt.Time},
+			Teacher:      entity.Teacher{ID: rec.TeacherID, Name: rec.Teacher.Name, DeletedAt: rec.Teacher.DeletedAt.Time},
+			TeachingDate: rec.TeachingDate,
+			StartTime:    rec.StartTime,
+			EndTime:      rec.EndTime,
+			Active:       rec.Active,
+			Hours:        rec.Hours,
+			Remark:       rec.Remark,
+			ScheduleID:   nullableID(rec.ScheduleID),
+			Attendance:   rec.Attendance,
+			MakeupForID:  nullableID(rec.MakeupForID),
+			SessionID:    nullableID(rec.SessionID),
+		})
+	}
+	return result, nil
+}
+
+func (r *RecordRepositoryImpl) GetRecordsOfSchedule(ctx context.Context, scheduleID uint, from time.Time) ([]entity.Record, error) {
+	dbRecords, err := r.recordDao.GetRecordsOfSchedule(ctx, scheduleID, from)
+	if err != nil {
+		return nil, err
+	}
+	var result []entity.Record
+	for _, rec := range dbRecords {
+		result = append(result, entity.Record{
+			ID:           rec.ID,
+			CreatedAt:    rec.CreatedAt,
+			UpdatedAt:    rec.UpdatedAt,
+			Student:      entity.Student{ID: rec.StudentID},
+			Teacher:      entity.Teacher{ID: rec.TeacherID},
+			TeachingDate: rec.TeachingDate,
+			StartTime:    rec.StartTime,
+			EndTime:      rec.EndTime,
+			Active:       rec.Active,
+			Hours:        rec.Hours,
+			Remark:       rec.Remark,
+			ScheduleID:   nullableID(rec.ScheduleID),
+			Attendance:   rec.Attendance,
+			MakeupForID:  nullableID(rec.MakeupForID),
+			SessionID:    nullableID(rec.SessionID),
+		})
+	}
+	return result, nil
+}
+
+func (r *RecordRepositoryImpl) GetRecordsOfSession(ctx context.Context, sessionID uint) ([]entity.Record, error) {
+	dbRecords, err := r.recordDao.GetRecordsOfSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	var result []entity.Record
+	for _, rec := range dbRecords {
+		result = append(result, entity.Record{
+			ID:           rec.ID,
+			CreatedAt:    rec.CreatedAt,
+			UpdatedAt:    rec.UpdatedAt,
+			Student:      entity.Student{ID: rec.StudentID, Name: rec.Student.Name, DeletedAt: rec.Student.DeletedAt.Time},
+			Teacher:      entity.Teacher{ID: rec.TeacherID, Name: rec.Teacher.Name, DeletedAt: rec.Teacher.DeletedAt.Time},
+			TeachingDate: rec.TeachingDate,
+			StartTime:    rec.StartTime,
+			EndTime:      rec.EndTime,
+			Active:       rec.Active,
+			Hours:        rec.Hours,
 			Remark:       rec.Remark,
+			ScheduleID:   nullableID(rec.ScheduleID),
+			Attendance:   rec.Attendance,
+			MakeupForID:  nullableID(rec.MakeupForID),
+			SessionID:    nullableID(rec.SessionID),
 		})
 	}
 	return result, nil
 }
+
+func (r *RecordRepositoryImpl) CountMakeupRecords(ctx context.Context, originalID uint, excludeID uint) (int64, error) {
+	return r.recordDao.CountMakeupRecords(ctx, originalID, excludeID)
+}
+
+func (r *RecordRepositoryImpl) GetDeletedRecordsOfSchedule(ctx context.Context, scheduleID uint, from time.Time) ([]entity.Record, error) {
+	dbRecords, err := r.recordDao.GetDeletedRecordsOfSchedule(ctx, scheduleID, from)
+	if err != nil {
+		return nil, err
+	}
+	var result []entity.Record
+	for _, rec := range dbRecords {
+		result = append(result, entity.Record{
+			ID:           rec.ID,
+			Student:      entity.Student{ID: rec.StudentID},
+			Teacher:      entity.Teacher{ID: rec.TeacherID},
+			TeachingDate: rec.TeachingDate,
+			StartTime:    rec.StartTime,
+			EndTime:      rec.EndTime,
+			ScheduleID:   nullableID(rec.ScheduleID),
+		})
+	}
+	return result, nil
+}
+
+func (r *RecordRepositoryImpl) CountMakeupReferences(ctx context.Context, originalID uint) (int64, error) {
+	return r.recordDao.CountMakeupReferences(ctx, originalID)
+}
+
+func (r *RecordRepositoryImpl) PurgeRecordByID(ctx context.Context, id uint) error {
+	return r.recordDao.PurgeRecordByID(ctx, id)
+}
+
+// nullableID 将可为空的关联主键转换为 uint，空值为 0
+func nullableID(id *uint) uint {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
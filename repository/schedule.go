@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"teaching_manage/dao"
+	"teaching_manage/entity"
+	"time"
+)
+
+type ScheduleRepository interface {
+	CreateSchedule(ctx context.Context, schedule *entity.Schedule) error
+	UpdateSchedule(ctx context.Context, schedule entity.Schedule) error
+	SetGeneratedUntil(ctx context.Context, id uint, date time.Time) error
+	DeleteSchedule(ctx context.Context, id uint) error
+	GetScheduleByID(ctx context.Context, id uint) (*entity.Schedule, error)
+	GetScheduleList(ctx context.Context, studentID uint, offset int, limit int) ([]entity.Schedule, int64, error)
+
+	CreateHoliday(ctx context.Context, holiday *entity.Holiday) error
+	DeleteHoliday(ctx context.Context, id uint) error
+	GetHolidayByID(ctx context.Context, id uint) (*entity.Holiday, error)
+	GetHolidayList(ctx context.Context, from time.Time, to time.Time) ([]entity.Holiday, error)
+}
+
+type ScheduleRepositoryImpl struct {
+	dao dao.ScheduleDao
+}
+
+func NewScheduleRepository(dao dao.ScheduleDao) ScheduleRepository {
+	return &ScheduleRepositoryImpl{dao: dao}
+}
+
+func toScheduleModel(s *entity.Schedule) dao.Schedule {
+	m := dao.Schedule{
+		StudentID:    s.Student.ID,
+		TeacherID:    s.Teacher.ID,
+		Weekday:      int(s.Weekday),
+		StartTime:    s.StartTime,
+		EndTime:      s.EndTime,
+		StartDate:    s.StartDate,
+		SkipHolidays: s.SkipHolidays,
+		AllowOverlap: s.AllowOverlap,
+		Hours:        s.Hours,
+		Remark:       s.Remark,
+	}
+	m.ID = s.ID
+	if !s.EndDate.IsZero() {
+		m.EndDate = &s.EndDate
+	}
+	return m
+}
+
+func toScheduleEntity(m *dao.Schedule) entity.Schedule {
+	s := entity.Schedule{
+		ID:           m.ID,
+		CreatedAt:    m.CreatedAt,
+		UpdatedAt:    m.UpdatedAt,
+		Student:      entity.Student{ID: m.StudentID, Name: m.Student.Name, DeletedAt: m.Student.DeletedAt.Time},
+		Teacher:      entity.Teacher{ID: m.TeacherID, Name: m.Teacher.Name, DeletedAt: m.Teacher.DeletedAt.Time},
+		Weekday:      time.Weekday(m.Weekday),
+		StartTime:    m.StartTime,
+		EndTime:      m.EndTime,
+		StartDate:    m.StartDate,
+		SkipHolidays: m.SkipHolidays,
+		AllowOverlap: m.AllowOverlap,
+		Hours:        m.Hours,
+		Remark:       m.Remark,
+	}
+	if m.EndDate != nil {
+		s.EndDate = *m.EndDate
+	}
+	if m.GeneratedUntil != nil {
+		s.GeneratedUntil = *m.GeneratedUntil
+	}
+	return s
+}
+
+func (sr ScheduleRepositoryImpl) CreateSchedule(ctx context.Context, schedule *entity.Schedule) error {
+	m := toScheduleModel(schedule)
+	if err := sr.dao.CreateSchedule(ctx, &m); err != nil {
+		return err
+	}
+	schedule.ID = m.ID
+	schedule.CreatedAt = m.CreatedAt
+	schedule.UpdatedAt = m.UpdatedAt
+	return nil
+}
+
+func (sr ScheduleRepositoryImpl) UpdateSchedule(ctx context.Context, schedule entity.Schedule) error {
+	m := toScheduleModel(&schedule)
+	return sr.dao.UpdateSchedule(ctx, &m)
+}
+
+// SetGeneratedUntil 记录已生成记录的最后日期，零值表示重新从头生成
+func (sr ScheduleRepositoryImpl) SetGeneratedUntil(ctx context.Context, id uint, date time.Time) error {
+	if date.IsZero() {
+		return sr.dao.SetGeneratedUntil(ctx, id, nil)
+	}
+	return sr.dao.SetGeneratedUntil(ctx, id, &date)
+}
+
+func (sr ScheduleRepositoryImpl) DeleteSchedule(ctx context.Context, id uint) error {
+	return sr.dao.DeleteSchedule(ctx, id)
+}
+
+func (sr ScheduleRepositoryImpl) GetScheduleByID(ctx context.Context, id uint) (*entity.Schedule, error) {
+	m, err := sr.dao.GetScheduleByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s := toScheduleEntity(m)
+	return &s, nil
+}
+
+func (sr ScheduleRepositoryImpl) GetScheduleList(ctx context.Context, studentID uint, offset int, limit int) ([]entity.Schedule, int64, error) {
+	schedules, total, err := sr.dao.GetScheduleList(ctx, studentID, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := make([]entity.Schedule, 0, len(schedules))
+	for i := range schedules {
+		result = append(result, toScheduleEntity(&schedules[i]))
+	}
+	return result, total, nil
+}
+
+func (sr ScheduleRepositoryImpl) CreateHoliday(ctx context.Context, holiday *entity.Holiday) error {
+	m := dao.Holiday{Date: holiday.Date, Name: holiday.Name}
+	if err := sr.dao.CreateHoliday(ctx, &m); err != nil {
+		return err
+	}
+	holiday.ID = m.ID
+	holiday.CreatedAt = m.CreatedAt
+	return nil
+}
+
+func (sr ScheduleRepositoryImpl) DeleteHoliday(ctx context.Context, id uint) error {
+	return sr.dao.DeleteHoliday(ctx, id)
+}
+
+func (sr ScheduleRepositoryImpl) GetHolidayByID(ctx context.Context, id uint) (*entity.Holiday, error) {
+	h, err := sr.dao.GetHolidayByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.Holiday{ID: h.ID, CreatedAt: h.CreatedAt, Date: h.Date, Name: h.Name}, nil
+}
+
+func (sr ScheduleRepositoryImpl) GetHolidayList(ctx context.Context, from time.Time, to time.Time) ([]entity.Holiday, error) {
+	holidays, err := sr.dao.GetHolidayList(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]entity.Holiday, 0, len(holidays))
+	for _, h := range holidays {
+		result = append(result, entity.Holiday{ID: h.ID, CreatedAt: h.CreatedAt, Date: h.Date, Name: h.Name})
+	}
+	return result, nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"teaching_manage/dao"
+	"teaching_manage/entity"
+)
+
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *entity.ClassSession) error
+	UpdateSession(ctx context.Context, session entity.ClassSession) error
+	DeleteSession(ctx context.Context, id uint) error
+	GetSessionByID(ctx context.Context, id uint) (*entity.ClassSession, error)
+	GetSessionList(ctx context.Context, teacherKey string, startDate string, endDate string, offset int, limit int) ([]entity.ClassSession, int64, error)
+}
+
+type SessionRepositoryImpl struct {
+	dao dao.SessionDao
+}
+
+func NewSessionRepository(dao dao.SessionDao) SessionRepository {
+	return &SessionRepositoryImpl{dao: dao}
+}
+
+func toSessionModel(s *entity.ClassSession) dao.ClassSession {
+	m := dao.ClassSession{
+		TeacherID:    s.Teacher.ID,
+		TeachingDate: s.TeachingDate,
+		StartTime:    s.StartTime,
+		EndTime:      s.EndTime,
+		Title:        s.Title,
+		Remark:       s.Remark,
+	}
+	m.ID = s.ID
+	return m
+}
+
+func toSessionEntity(m *dao.ClassSession) entity.ClassSession {
+	return entity.ClassSession{
+		ID:           m.ID,
+		CreatedAt:    m.CreatedAt,
+		UpdatedAt:    m.UpdatedAt,
+		Teacher:      entity.Teacher{ID: m.TeacherID, Name: m.Teacher.Name, DeletedAt: m.Teacher.DeletedAt.Time},
+		TeachingDate: m.TeachingDate,
+		StartTime:    m.StartTime,
+		EndTime:      m.EndTime,
+		Title:        m.Title,
+		Remark:       m.Remark,
+	}
+}
+
+func (sr SessionRepositoryImpl) CreateSession(ctx context.Context, session *entity.ClassSession) error {
+	m := toSessionModel(session)
+	if err := sr.dao.CreateSession(ctx, &m); err != nil {
+		return err
+	}
+	session.ID = m.ID
+	session.CreatedAt = m.CreatedAt
+	session.UpdatedAt = m.UpdatedAt
+	return nil
+}
+
+func (sr SessionRepositoryImpl) UpdateSession(ctx context.Context, session entity.ClassSession) error {
+	m := toSessionModel(&session)
+	return sr.dao.UpdateSession(ctx, &m)
+}
+
+func (sr SessionRepositoryImpl) DeleteSession(ctx context.Context, id uint) error {
+	return sr.dao.DeleteSession(ctx, id)
+}
+
+func (sr SessionRepositoryImpl) GetSessionByID(ctx context.Context, id uint) (*entity.ClassSession, error) {
+	m, err := sr.dao.GetSessionByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s := toSessionEntity(m)
+	return &s, nil
+}
+
+// GetSessionList 返回课次列表，并填充每节课的学生人数与已激活人数
+func (sr SessionRepositoryImpl) GetSessionList(ctx context.Context, teacherKey string, startDate string, endDate string, offset int, limit int) ([]entity.ClassSession, int64, error) {
+	sessions, total, err := sr.dao.GetSessionList(ctx, teacherKey, startDate, endDate, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	ids := make([]uint, 0, len(sessions))
+	for _, s := range sessions {
+		ids = append(ids, s.ID)
+	}
+	counts, err := sr.dao.GetAttendeeCounts(ctx, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[uint]dao.SessionAttendeeCount, len(counts))
+	for _, c := range counts {
+		byID[c.SessionID] = c
+	}
+
+	result := make([]entity.ClassSession, 0, len(sessions))
+	for i := range sessions {
+		s := toSessionEntity(&sessions[i])
+		s.Attendees = byID[s.ID].Total
+		s.ActiveAttendees = byID[s.ID].Active
+		result = append(result, s)
+	}
+	return result, total, nil
+}
@@ -111,6 +111,7 @@ func (sr StudentRepositoryImpl) GetStudentByIdWithDeleted(ctx context.Context, i
 		Remark:    student.Remark,
 		CreatedAt: student.CreatedAt,
 		UpdatedAt: student.UpdatedAt,
+		DeletedAt: student.DeletedAt.Time,
 		Teacher: entity.Teacher{
 			ID:        student.Teacher.ID,
 			Name:      student.Teacher.Name,
@@ -140,7 +141,7 @@ func (sr StudentRepositoryImpl) UpdateStudentHoursByID(ctx context.Context, id u
 }
 
 func (sr StudentRepositoryImpl) CreateStudent(ctx context.Context, stu *entity.Student) error {
-	return sr.dao.CreateStudent(ctx, &dao.Student{
+	s := dao.Student{
 		Model:     gorm.Model{},
 		Name:      stu.Name,
 		Gender:    stu.Gender,
@@ -148,7 +149,14 @@ func (sr StudentRepositoryImpl) CreateStudent(ctx context.Context, stu *entity.S
 		Phone:     stu.Phone,
 		TeacherID: stu.TeacherID,
 		Remark:    stu.Remark,
-	})
+	}
+	if err := sr.dao.CreateStudent(ctx, &s); err != nil {
+		return err
+	}
+	stu.ID = s.ID
+	stu.CreatedAt = s.CreatedAt
+	stu.UpdatedAt = s.UpdatedAt
+	return nil
 }
 
 func (sr StudentRepositoryImpl) DeleteStudentByID(ctx context.Context, id uint) error {
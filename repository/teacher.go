@@ -4,11 +4,13 @@ import (
 	"context"
 	"teaching_manage/dao"
 	"teaching_manage/entity"
+	"teaching_manage/pkg"
 )
 
 type TeacherRepository interface {
 	GetTeacherList(ctx context.Context, key string, offset int, limit int) ([]dao.Teacher, int64, error)
-	CreateTeacher(ctx context.Context, teacher entity.Teacher) error
+	CreateTeacher(ctx context.Context, teacher *entity.Teacher) error
+	GetTeacherByID(ctx context.Context, id uint) (*entity.Teacher, error)
 	DeleteTeacher(ctx context.Context, id uint) error
 	UpdateTeacher(ctx context.Context, teacher entity.Teacher) error
 }
@@ -29,14 +31,37 @@ func (tr TeacherRepositoryImpl) GetTeacherList(ctx context.Context, key string,
 	return teachers, total, nil
 }
 
-func (tr TeacherRepositoryImpl) CreateTeacher(ctx context.Context, teacher entity.Teacher) error {
-	err := tr.dao.CreateTeacher(ctx, &dao.Teacher{
+func (tr TeacherRepositoryImpl) CreateTeacher(ctx context.Context, teacher *entity.Teacher) error {
+	t := dao.Teacher{
 		Name:   teacher.Name,
 		Phone:  teacher.Phone,
 		Gender: string(teacher.Gender),
 		Remark: teacher.Remark,
-	})
-	return err
+	}
+	if err := tr.dao.CreateTeacher(ctx, &t); err != nil {
+		return err
+	}
+	teacher.ID = t.ID
+	teacher.CreatedAt = t.CreatedAt
+	teacher.UpdatedAt = t.UpdatedAt
+	return nil
+}
+
+func (tr TeacherRepositoryImpl) GetTeacherByID(ctx context.Context, id uint) (*entity.Teacher, error) {
+	t, err := tr.dao.GetTeacherByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.Teacher{
+		ID:        t.ID,
+		Name:      t.Name,
+		Gender:    pkg.Gender(t.Gender),
+		Phone:     t.Phone,
+		Remark:    t.Remark,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+		DeletedAt: t.DeletedAt.Time,
+	}, nil
 }
 
 func (tr TeacherRepositoryImpl) DeleteTeacher(ctx context.Context, id uint) error {
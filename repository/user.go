@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"teaching_manage/dao"
+	"teaching_manage/entity"
+)
+
+type UserRepository interface {
+	CreateUser(ctx context.Context, user *entity.User) error
+	UpdateUser(ctx context.Context, user entity.User) error
+	UpdatePassword(ctx context.Context, id uint, hash string) error
+	GetUserByID(ctx context.Context, id uint) (*entity.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*entity.User, error)
+	GetUserList(ctx context.Context, offset int, limit int) ([]entity.User, int64, error)
+	CountActiveUsers(ctx context.Context, role string) (int64, error)
+}
+
+type UserRepositoryImpl struct {
+	dao dao.UserDao
+}
+
+func NewUserRepository(dao dao.UserDao) UserRepository {
+	return &UserRepositoryImpl{dao: dao}
+}
+
+func toUserEntity(u *dao.User) *entity.User {
+	return &entity.User{
+		ID:           u.ID,
+		Username:     u.Username,
+		PasswordHash: u.PasswordHash,
+		DisplayName:  u.DisplayName,
+		Role:         u.Role,
+		Disabled:     u.Disabled,
+		CreatedAt:    u.CreatedAt,
+		UpdatedAt:    u.UpdatedAt,
+	}
+}
+
+func (ur UserRepositoryImpl) CreateUser(ctx context.Context, user *entity.User) error {
+	u := dao.User{
+		Username:     user.Username,
+		PasswordHash: user.PasswordHash,
+		DisplayName:  user.DisplayName,
+		Role:         user.Role,
+		Disabled:     user.Disabled,
+	}
+	if err := ur.dao.CreateUser(ctx, &u); err != nil {
+		return err
+	}
+	user.ID = u.ID
+	user.CreatedAt = u.CreatedAt
+	user.UpdatedAt = u.UpdatedAt
+	return nil
+}
+
+func (ur UserRepositoryImpl) UpdateUser(ctx context.Context, user entity.User) error {
+	u := dao.User{
+		DisplayName: user.DisplayName,
+		Role:        user.Role,
+		Disabled:    user.Disabled,
+	}
+	u.ID = user.ID
+	return ur.dao.UpdateUser(ctx, &u)
+}
+
+func (ur UserRepositoryImpl) UpdatePassword(ctx context.Context, id uint, hash string) error {
+	return ur.dao.UpdatePassword(ctx, id, hash)
+}
+
+func (ur UserRepositoryImpl) GetUserByID(ctx context.Context, id uint) (*entity.User, error) {
+	u, err := ur.dao.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toUserEntity(u), nil
+}
+
+func (ur UserRepositoryImpl) GetUserByUsername(ctx context.Context, username string) (*entity.User, error) {
+	u, err := ur.dao.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return toUserEntity(u), nil
+}
+
+func (ur UserRepositoryImpl) GetUserList(ctx context.Context, offset int, limit int) ([]entity.User, int64, error) {
+	users, total, err := ur.dao.GetUserList(ctx, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := make([]entity.User, 0, len(users))
+	for i := range users {
+		result = append(result, *toUserEntity(&users[i]))
+	}
+	return result, total, nil
+}
+
+func (ur UserRepositoryImpl) CountActiveUsers(ctx context.Context, role string) (int64, error) {
+	return ur.dao.CountActiveUsers(ctx, role)
+}
@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"teaching_manage/dao"
+	"teaching_manage/entity"
+	"teaching_manage/pkg"
+	"teaching_manage/pkg/auth"
+	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/logger"
+	"teaching_manage/repository"
+	requestx "teaching_manage/service/request"
+	responsex "teaching_manage/service/response"
+	"time"
+
+	wails "github.com/wailsapp/wails/v2/pkg/runtime"
+	"gorm.io/gorm"
+)
+
+// 审计日志中的实体类型
+const (
+	auditEntityStudent  = "student"
+	auditEntityTeacher  = "teacher"
+	auditEntityOrder    = "order"
+	auditEntityRecord   = "record"
+	auditEntityUser     = "user"
+	auditEntitySchedule = "schedule"
+	auditEntityHoliday  = "holiday"
+	auditEntitySession  = "session"
+)
+
+// 审计日志中的操作
+const (
+	auditStudentCreate      = "student:create"
+	auditStudentUpdate      = "student:update"
+	auditStudentDelete      = "student:delete"
+	auditStudentAdjustHours = "student:adjust_hours"
+	auditTeacherCreate      = "teacher:create"
+	auditTeacherUpdate      = "teacher:update"
+	auditTeacherDelete      = "teacher:delete"
+	auditOrderCreate        = "order:create"
+	auditRecordCreate       = "record:create"
+	auditRecordImport       = "record:import"
+	auditRecordUpdate       = "record:update"
+	auditRecordActivate     = "record:activate"
+	auditRecordDeactivate   = "record:deactivate"
+	auditRecordDelete       = "record:delete"
+	auditRecordGenerate     = "record:generate"
+	auditScheduleCreate     = "schedule:create"
+	auditScheduleUpdate     = "schedule:update"
+	auditScheduleCancel     = "schedule:cancel"
+	auditHolidayCreate      = "holiday:create"
+	auditHolidayDelete      = "holiday:delete"
+	auditSessionCreate      = "session:create"
+	auditSessionUpdate      = "session:update"
+	auditSessionCancel      = "session:cancel"
+	auditUserCreate         = "user:create"
+	auditUserUpdate         = "user:update"
+	auditUserResetPassword  = "user:reset_password"
+	auditUserChangePassword = "user:change_password"
+)
+
+// writeAudit 在 tx 中追加一条审计日志，必须与对应的修改处于同一事务。
+// before 为 nil 表示新建，after 为 nil 表示删除。
+func writeAudit(ctx context.Context, tx *gorm.DB, operation string, entityType string, entityID uint, before any, after any) error {
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return err
+	}
+
+	repo := repository.NewAuditRepository(dao.NewAuditDao(tx))
+	err = repo.CreateAuditLog(ctx, entity.AuditLog{
+		Operation: operation,
+		Entity:    entityType,
+		EntityID:  entityID,
+		Before:    beforeJSON,
+		After:     afterJSON,
+		RequestID: logger.RequestIDFrom(ctx),
+		Route:     dispatcher.RouteName(ctx),
+		Actor:     auditActor(ctx),
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to write audit log", logger.String("operation", operation),
+			logger.UInt("entity_id", entityID), logger.ErrorType(err))
+		return fmt.Errorf("write audit log: %w", err)
+	}
+	return nil
+}
+
+// auditActor 返回发起修改的登录名，未经登录的内部调用为空
+func auditActor(ctx context.Context) string {
+	p, _ := auth.PrincipalFrom(ctx)
+	return p.Username
+}
+
+func auditJSON(v any) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("marshal audit snapshot: %w", err)
+	}
+	return string(b), nil
+}
+
+// auditHours 是学生课时变动的审计快照
+type auditHours struct {
+	Hours int `json:"hours"`
+}
+
+// writeHoursAudit 记录一次学生课时余额变动
+func writeHoursAudit(ctx context.Context, tx *gorm.DB, studentID uint, before int, diff int) error {
+	return writeAudit(ctx, tx, auditStudentAdjustHours, auditEntityStudent, studentID,
+		auditHours{Hours: before}, auditHours{Hours: before + diff})
+}
+
+// auditOrder 在 OrderDTO 的基础上补充所属学生
+type auditOrder struct {
+	responsex.OrderDTO
+	StudentID uint `json:"student_id"`
+}
+
+func orderSnapshot(o *entity.Order) auditOrder {
+	return auditOrder{
+		OrderDTO: responsex.OrderDTO{
+			Id:        o.Id,
+			CreatedAt: o.CreatedAt.UnixMilli(),
+			UpdatedAt: o.UpdatedAt.UnixMilli(),
+			Hours:     o.Hours,
+			Comment:   o.Comment,
+			Active:    o.Active,
+			Type:      responsex.OrderDTOTypeToString(o.Hours),
+		},
+		StudentID: o.Student.ID,
+	}
+}
+
+func studentSnapshot(s *entity.Student) responsex.StudentDTO {
+	return responsex.StudentDTO{
+		ID:          s.ID,
+		Name:        s.Name,
+		Gender:      s.Gender,
+		Hours:       s.Hours,
+		Phone:       s.Phone,
+		TeacherID:   s.TeacherID,
+		Remark:      s.Remark,
+		TeacherName: s.Teacher.Name,
+		CreatedAt:   s.CreatedAt.UnixMilli(),
+		UpdatedAt:   s.UpdatedAt.UnixMilli(),
+	}
+}
+
+func teacherSnapshot(t *entity.Teacher) responsex.TeacherDTO {
+	return responsex.TeacherDTO{
+		ID:        t.ID,
+		Name:      t.Name,
+		Gender:    t.Gender.String(),
+		Phone:     t.Phone,
+		Remark:    t.Remark,
+		CreatedAt: t.CreatedAt.UnixMilli(),
+		UpdatedAt: t.UpdatedAt.UnixMilli(),
+	}
+}
+
+// auditDeactivation 在记录快照上附带撤销激活的原因
+type auditDeactivation struct {
+	responsex.RecordDTO
+	Reason string `json:"reason"`
+}
+
+// userSnapshot 不包含密码哈希
+func userSnapshot(u *entity.User) responsex.UserDTO {
+	return responsex.UserDTO{
+		ID:          u.ID,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		Role:        u.Role,
+		Disabled:    u.Disabled,
+		CreatedAt:   u.CreatedAt.UnixMilli(),
+		UpdatedAt:   u.UpdatedAt.UnixMilli(),
+	}
+}
+
+func scheduleSnapshot(s *entity.Schedule) responsex.ScheduleDTO {
+	dto := responsex.ScheduleDTO{
+		ID:           s.ID,
+		StudentID:    s.Student.ID,
+		StudentName:  s.Student.Name,
+		TeacherID:    s.Teacher.ID,
+		TeacherName:  s.Teacher.Name,
+		Weekday:      int(s.Weekday),
+		StartTime:    s.StartTime,
+		EndTime:      s.EndTime,
+		StartDate:    s.StartDate.Format("2006-01-02"),
+		SkipHolidays: s.SkipHolidays,
+		AllowOverlap: s.AllowOverlap,
+		Hours:        s.Hours,
+		Remark:       s.Remark,
+		CreatedAt:    s.CreatedAt.UnixMilli(),
+		UpdatedAt:    s.UpdatedAt.UnixMilli(),
+	}
+	if !s.EndDate.IsZero() {
+		dto.EndDate = s.EndDate.Format("2006-01-02")
+	}
+	if !s.GeneratedUntil.IsZero() {
+		dto.GeneratedUntil = s.GeneratedUntil.Format("2006-01-02")
+	}
+	return dto
+}
+
+func sessionSnapshot(s *entity.ClassSession) responsex.SessionDTO {
+	return responsex.SessionDTO{
+		ID:              s.ID,
+		TeacherID:       s.Teacher.ID,
+		TeacherName:     s.Teacher.Name,
+		TeachingDate:    s.TeachingDate.Format("2006-01-02"),
+		StartTime:       s.StartTime,
+		EndTime:         s.EndTime,
+		Title:           s.Title,
+		Remark:          s.Remark,
+		Attendees:       s.Attendees,
+		ActiveAttendees: s.ActiveAttendees,
+		CreatedAt:       s.CreatedAt.UnixMilli(),
+		UpdatedAt:       s.UpdatedAt.UnixMilli(),
+	}
+}
+
+func holidaySnapshot(h *entity.Holiday) responsex.HolidayDTO {
+	return responsex.HolidayDTO{ID: h.ID, Date: h.Date.Format("2006-01-02"), Name: h.Name}
+}
+
+func recordSnapshot(r *entity.Record) responsex.RecordDTO {
+	return responsex.RecordDTO{
+		ID:           r.ID,
+		StudentID:    r.Student.ID,
+		TeacherID:    r.Teacher.ID,
+		StudentName:  r.Student.Name,
+		TeacherName:  r.Teacher.Name,
+		TeachingDate: r.TeachingDate.Format("2006-01-02"),
+		StartTime:    r.StartTime,
+		EndTime:      r.EndTime,
+		Active:       r.Active,
+		Hours:        r.Hours,
+		Remark:       r.Remark,
+		ScheduleID:   r.ScheduleID,
+		Attendance:   r.Attendance,
+		MakeupForID:  r.MakeupForID,
+		SessionID:    r.SessionID,
+		CreatedAt:    r.CreatedAt.UnixMilli(),
+		UpdatedAt:    r.UpdatedAt.UnixMilli(),
+	}
+}
+
+// AuditManager 提供审计日志的查询与导出，审计日志本身只能由各业务修改写入
+type AuditManager struct {
+	Ctx  context.Context
+	repo repository.AuditRepository
+}
+
+func NewAuditManager(repo repository.AuditRepository) *AuditManager {
+	return &AuditManager{repo: repo}
+}
+
+func (am *AuditManager) Query(ctx context.Context, req *requestx.QueryAuditLogRequest) (responsex.QueryAuditLogResponse, error) {
+	filter := dao.AuditFilter{
+		Operation: req.Operation,
+		Entity:    req.Entity,
+		EntityID:  req.EntityID,
+		Actor:     req.Actor,
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+	}
+	logs, total, err := am.repo.QueryAuditLogs(ctx, filter, req.Offset, req.Limit)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to query audit logs", logger.ErrorType(err))
+		return responsex.QueryAuditLogResponse{}, err
+	}
+
+	result := make([]responsex.AuditLogDTO, 0, len(logs))
+	for _, l := range logs {
+		result = append(result, responsex.AuditLogDTO{
+			ID:        l.ID,
+			CreatedAt: l.CreatedAt.UnixMilli(),
+			Operation: l.Operation,
+			Entity:    l.Entity,
+			EntityID:  l.EntityID,
+			Before:    rawAuditJSON(l.Before),
+			After:     rawAuditJSON(l.After),
+			RequestID: l.RequestID,
+			Route:     l.Route,
+			Actor:     l.Actor,
+		})
+	}
+	return responsex.QueryAuditLogResponse{Logs: result, Total: total}, nil
+}
+
+func rawAuditJSON(s string) json.RawMessage {
+	if s == "" {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(s)
+}
+
+func (am *AuditManager) Export2Excel(ctx context.Context, req *requestx.ExportAuditLogRequest) (string, error) {
+	filepath, err := wails.SaveFileDialog(am.Ctx, wails.SaveDialogOptions{
+		Title:           "选择导出文件位置",
+		DefaultFilename: fmt.Sprintf("audit_log_%s.xlsx", time.Now().Format("20060102_150405")),
+		Filters:         []wails.FileFilter{{DisplayName: "Excel 文件", Pattern: "*.xlsx"}},
+	})
+	if err != nil {
+		return "", err
+	}
+	if filepath == "" {
+		return "cancel", nil
+	}
+
+	filter := dao.AuditFilter{
+		Operation: req.Operation,
+		Entity:    req.Entity,
+		EntityID:  req.EntityID,
+		Actor:     req.Actor,
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+	}
+	logs, _, err := am.repo.QueryAuditLogs(ctx, filter, 0, -1)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to query audit logs for export", logger.ErrorType(err))
+		return "", err
+	}
+
+	headers := []string{"时间", "操作", "实体", "实体ID", "修改前", "修改后", "请求ID", "路由", "操作人"}
+	rows := make([][]string, 0, len(logs))
+	for _, l := range logs {
+		rows = append(rows, []string{
+			l.CreatedAt.Format("2006-01-02 15:04:05"),
+			l.Operation,
+			l.Entity,
+			strconv.FormatUint(uint64(l.EntityID), 10),
+			l.Before,
+			l.After,
+			l.RequestID,
+			l.Route,
+			l.Actor,
+		})
+	}
+	if err := pkg.ExportToExcel(filepath, headers, rows); err != nil {
+		logger.ErrorContext(ctx, "failed to export audit logs", logger.ErrorType(err))
+		return "", fmt.Errorf("导出失败:请检查文件是否被占用或有读写权限")
+	}
+	return filepath, nil
+}
+
+func (am *AuditManager) RegisterRoute(d *dispatcher.Dispatcher) {
+	dispatcher.RegisterTyped(d, "audit:query", am.Query)
+	dispatcher.RegisterTyped(d, "audit:export", am.Export2Excel, dispatcher.DesktopOnly())
+}
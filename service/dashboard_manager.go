@@ -12,6 +12,9 @@ import (
 	"time"
 )
 
+// dashboardQueryTimeout 为单个看板统计请求的超时时间
+const dashboardQueryTimeout = 15 * time.Second
+
 type DashboardManager struct {
 	Ctx context.Context
 }
@@ -22,56 +25,56 @@ func NewDashboardManager() *DashboardManager {
 
 // GetSummaryData 获取顶部核心指标卡数据
 func (m *DashboardManager) GetSummaryData(ctx context.Context) (responsex.DashboardSummaryResponse, error) {
-	db := dao.GetDB()
+	db := dao.GetDBFromContext(ctx)
 	var summary responsex.DashboardSummaryResponse
 
 	// 1. 在读学员总数
 	if err := db.Model(&dao.Student{}).Count(&summary.TotalStudents).Error; err != nil {
-		logger.Error("Failed to count students", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "Failed to count students", logger.ErrorType(err))
 	}
 
 	// 2. 本月新增学员
 	now := time.Now()
 	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
 	if err := db.Model(&dao.Student{}).Where("created_at >= ?", startOfMonth).Count(&summary.NewStudentsThisMonth).Error; err != nil {
-		logger.Error("Failed to count new students", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "Failed to count new students", logger.ErrorType(err))
 	}
 
 	// 3. 剩余总课时
 	if err := db.Model(&dao.Student{}).Select("COALESCE(SUM(hours), 0)").Scan(&summary.TotalRemainingHours).Error; err != nil {
-		logger.Error("Failed to sum remaining hours", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "Failed to sum remaining hours", logger.ErrorType(err))
 	}
 
 	// 4. 欠费与预警人数
 	// 欠费: hours < 0
 	if err := db.Model(&dao.Student{}).Where("hours < 0").Count(&summary.TotalArrears).Error; err != nil {
-		logger.Error("Failed to count arrears", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "Failed to count arrears", logger.ErrorType(err))
 	}
 	// 预警: 0 <= hours < 5
 	if err := db.Model(&dao.Student{}).Where("hours >= 0 AND hours < 5").Count(&summary.TotalWarning).Error; err != nil {
-		logger.Error("Failed to count warning", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "Failed to count warning", logger.ErrorType(err))
 	}
 
 	// 5. 本月消课 (Records active=true)
 	// 计算本月第一天和下个月第一天
 	nextMonth := startOfMonth.AddDate(0, 1, 0)
 
-	// 本月消课数
+	// 本月消耗课时
 	var currentMonthCount int64
-	if err := db.Model(&dao.Record{}).
+	if err := db.Model(&dao.Record{}).Select("COALESCE(SUM("+dao.ChargedHoursSQL("records")+"), 0)").
 		Where("active = 1 AND teaching_date >= ? AND teaching_date < ?", startOfMonth, nextMonth).
-		Count(&currentMonthCount).Error; err != nil {
-		logger.Error("Failed to count monthly records", logger.ErrorType(err))
+		Scan(&currentMonthCount).Error; err != nil {
+		logger.ErrorContext(ctx, "Failed to sum monthly record hours", logger.ErrorType(err))
 	}
 	summary.MonthlyHours = currentMonthCount
 
-	// 上月消课数 (用于计算环比)
+	// 上月消耗课时 (用于计算环比)
 	startOfLastMonth := startOfMonth.AddDate(0, -1, 0)
 	var lastMonthCount int64
-	if err := db.Model(&dao.Record{}).
+	if err := db.Model(&dao.Record{}).Select("COALESCE(SUM("+dao.ChargedHoursSQL("records")+"), 0)").
 		Where("active = 1 AND teaching_date >= ? AND teaching_date < ?", startOfLastMonth, startOfMonth).
-		Count(&lastMonthCount).Error; err != nil {
-		logger.Error("Failed to count last month records", logger.ErrorType(err))
+		Scan(&lastMonthCount).Error; err != nil {
+		logger.ErrorContext(ctx, "Failed to sum last month record hours", logger.ErrorType(err))
 	}
 
 	// 计算环比
@@ -92,7 +95,7 @@ func (m *DashboardManager) GetSummaryData(ctx context.Context) (responsex.Dashbo
 
 // GetFinanceChartData 获取资金/课时流转数据
 func (m *DashboardManager) GetFinanceChartData(ctx context.Context, rangeType *requestx.GetFinanceDataRequest) (responsex.FinanceChartDTO, error) {
-	db := dao.GetDB()
+	db := dao.GetDBFromContext(ctx)
 	var result responsex.FinanceChartDTO
 
 	// 根据 rangeType 动态生成 SQL 时间范围
@@ -203,10 +206,10 @@ func (m *DashboardManager) GetFinanceChartData(ctx context.Context, rangeType *r
 		return result, err
 	}
 
-	// 2. 消课数据 (Records)
+	// 2. 消课数据 (Records)，按实际扣除的课时统计
 	var consumeStats []ChartStat
 	err = db.Model(&dao.Record{}).
-		Select(fmt.Sprintf("strftime('%s', teaching_date) as label, COUNT(id) as total", sqlFormat)). // 假设每条记录1课时
+		Select(fmt.Sprintf("strftime('%s', teaching_date) as label, SUM(%s) as total", sqlFormat, dao.ChargedHoursSQL("records"))).
 		Where("active = 1 AND teaching_date >= ?", queryStartDate).
 		Group("label").
 		Order("label").
@@ -237,14 +240,16 @@ func (m *DashboardManager) GetFinanceChartData(ctx context.Context, rangeType *r
 	return result, nil
 }
 
-// GetTeacherRankData 获取教师排行
+// GetTeacherRankData 获取教师排行，按本月实际授课（出勤与补课）的学生课时排序；
+// 小组课每名学生的课时分别计入，课次数则一节小组课只算一次
 func (m *DashboardManager) GetTeacherRankData(ctx context.Context) (responsex.TeacherRankDTO, error) {
-	db := dao.GetDB()
+	db := dao.GetDBFromContext(ctx)
 	var result responsex.TeacherRankDTO
 
 	type RankStat struct {
-		Name  string
-		Total int64
+		Name     string
+		Total    int64
+		Sessions int64
 	}
 	var stats []RankStat
 
@@ -253,9 +258,10 @@ func (m *DashboardManager) GetTeacherRankData(ctx context.Context) (responsex.Te
 	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
 
 	err := db.Table("records").
-		Select("teachers.name, COUNT(records.id) as total").
+		Select("teachers.name, SUM(records.hours) as total, COUNT(DISTINCT "+dao.LessonKeySQL("records")+") as sessions").
 		Joins("JOIN teachers ON records.teacher_id = teachers.id").
 		Where("records.active = 1 AND records.deleted_at IS NULL AND records.teaching_date >= ?", startOfMonth).
+		Where(dao.TaughtSQL("records")).
 		Group("teachers.id").
 		Order("total DESC").
 		Limit(5).
@@ -270,6 +276,7 @@ func (m *DashboardManager) GetTeacherRankData(ctx context.Context) (responsex.Te
 	for i := len(stats) - 1; i >= 0; i-- {
 		result.Names = append(result.Names, stats[i].Name)
 		result.Values = append(result.Values, stats[i].Total)
+		result.Sessions = append(result.Sessions, stats[i].Sessions)
 	}
 
 	return result, nil
@@ -277,7 +284,7 @@ func (m *DashboardManager) GetTeacherRankData(ctx context.Context) (responsex.Te
 
 // GetHeatmapData 获取热力图数据 (适配 ChartHeatmap.vue 组件)
 func (m *DashboardManager) GetHeatmapData(ctx context.Context) ([][]int, error) {
-	db := dao.GetDB()
+	db := dao.GetDBFromContext(ctx)
 
 	// 结构体接收数据库聚合结果
 	type HeatStat struct {
@@ -290,18 +297,19 @@ func (m *DashboardManager) GetHeatmapData(ctx context.Context) ([][]int, error)
 	// 分析最近 6 个月的数据
 	startDate := time.Now().AddDate(0, -6, 0)
 
-	// SQLite 查询: 聚合 星期几 和 小时
+	// SQLite 查询: 聚合 星期几 和 小时，一节小组课只算一次
 	// 注意: start_time 在数据库中可能是 "08:00:00" 或 "08:00"，substr(start_time, 1, 2) 取前两位
 	err := db.Model(&dao.Record{}).
 		Select("CAST(strftime('%w', teaching_date) AS INTEGER) as day_of_week, "+
 			"substr(start_time, 1, 2) as hour, "+
-			"COUNT(id) as count").
+			"COUNT(DISTINCT "+dao.LessonKeySQL("records")+") as count").
 		Where("active = 1 AND deleted_at IS NULL AND teaching_date >= ?", startDate).
+		Where(dao.TaughtSQL("records")). // 请假和缺课的课没有实际上课
 		Group("day_of_week, hour").
 		Scan(&stats).Error
 
 	if err != nil {
-		logger.Error("Failed to get heatmap data", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "Failed to get heatmap data", logger.ErrorType(err))
 		return nil, err
 	}
 
@@ -330,7 +338,7 @@ func (m *DashboardManager) GetHeatmapData(ctx context.Context) ([][]int, error)
 // GetStudentEngagementData 获取学员活跃度分布 (基于过去30天课次)
 // Dormant: 0, Lazy: 1-3, Regular: 4-8, High: >8
 func (m *DashboardManager) GetStudentEngagementData(ctx context.Context) (responsex.GetStudentEngagementDataResponse, error) {
-	db := dao.GetDB()
+	db := dao.GetDBFromContext(ctx)
 
 	type EngagementStat struct {
 		FrequencyLevel string
@@ -360,6 +368,7 @@ func (m *DashboardManager) GetStudentEngagementData(ctx context.Context) (respon
 				AND r.active = 1 
 				AND r.deleted_at IS NULL
 				AND r.teaching_date >= ?
+				AND ` + dao.TaughtSQL("r") + `
 			WHERE s.deleted_at IS NULL
 			GROUP BY s.id
 		)
@@ -367,7 +376,7 @@ func (m *DashboardManager) GetStudentEngagementData(ctx context.Context) (respon
 	`
 
 	if err := db.Raw(query, startDate).Scan(&stats).Error; err != nil {
-		logger.Error("Failed to get student engagement data", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "Failed to get student engagement data", logger.ErrorType(err))
 		return responsex.GetStudentEngagementDataResponse{}, err
 	}
 
@@ -396,7 +405,7 @@ func (m *DashboardManager) GetStudentEngagementData(ctx context.Context) (respon
 
 // GetStudentGrowthData 获取学员增长趋势数据 (最近 6 个月)
 func (m *DashboardManager) GetStudentGrowthData(ctx context.Context) (responsex.ChartDataDTO, error) {
-	db := dao.GetDB()
+	db := dao.GetDBFromContext(ctx)
 	var result responsex.ChartDataDTO
 
 	// 生成最近 6 个月的月份标签
@@ -430,7 +439,7 @@ func (m *DashboardManager) GetStudentGrowthData(ctx context.Context) (responsex.
 		Scan(&stats).Error
 
 	if err != nil {
-		logger.Error("Failed to get student growth data", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "Failed to get student growth data", logger.ErrorType(err))
 		return result, err
 	}
 
@@ -450,7 +459,7 @@ func (m *DashboardManager) GetStudentGrowthData(ctx context.Context) (responsex.
 // GetStudentBalanceData 获取学员账户健康度分布 (基于剩余课时)
 // Arrears: <0, Warning: 0-5, Sufficient: >=5
 func (m *DashboardManager) GetStudentBalanceData(ctx context.Context) (responsex.GetStudentBalanceDataResponse, error) {
-	db := dao.GetDB()
+	db := dao.GetDBFromContext(ctx)
 
 	type BalanceStat struct {
 		BalanceLevel string
@@ -473,7 +482,7 @@ func (m *DashboardManager) GetStudentBalanceData(ctx context.Context) (responsex
 	`
 
 	if err := db.Raw(query).Scan(&stats).Error; err != nil {
-		logger.Error("Failed to get student balance data", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "Failed to get student balance data", logger.ErrorType(err))
 		return responsex.GetStudentBalanceDataResponse{}, err
 	}
 
@@ -499,11 +508,13 @@ func (m *DashboardManager) GetStudentBalanceData(ctx context.Context) (responsex
 }
 
 func (m *DashboardManager) RegisterRoute(d *dispatcher.Dispatcher) {
-	dispatcher.RegisterNoReq(d, "dashboard_manager:get_summary", m.GetSummaryData)
-	dispatcher.RegisterTyped(d, "dashboard_manager:get_finance_chart", m.GetFinanceChartData)
-	dispatcher.RegisterNoReq(d, "dashboard_manager:get_teacher_rank", m.GetTeacherRankData)
-	dispatcher.RegisterNoReq(d, "dashboard_manager:get_heatmap", m.GetHeatmapData)
-	dispatcher.RegisterNoReq(d, "dashboard_manager:get_student_engagement", m.GetStudentEngagementData)
-	dispatcher.RegisterNoReq(d, "dashboard_manager:get_student_growth", m.GetStudentGrowthData)
-	dispatcher.RegisterNoReq(d, "dashboard_manager:get_student_balance", m.GetStudentBalanceData)
+	// 统计查询较重，限制单次请求耗时，避免阻塞唯一的数据库连接
+	timeout := dispatcher.Timeout(dashboardQueryTimeout)
+	dispatcher.RegisterNoReq(d, "dashboard_manager:get_summary", m.GetSummaryData, timeout)
+	dispatcher.RegisterTyped(d, "dashboard_manager:get_finance_chart", m.GetFinanceChartData, timeout)
+	dispatcher.RegisterNoReq(d, "dashboard_manager:get_teacher_rank", m.GetTeacherRankData, timeout)
+	dispatcher.RegisterNoReq(d, "dashboard_manager:get_heatmap", m.GetHeatmapData, timeout)
+	dispatcher.RegisterNoReq(d, "dashboard_manager:get_student_engagement", m.GetStudentEngagementData, timeout)
+	dispatcher.RegisterNoReq(d, "dashboard_manager:get_student_growth", m.GetStudentGrowthData, timeout)
+	dispatcher.RegisterNoReq(d, "dashboard_manager:get_student_balance", m.GetStudentBalanceData, timeout)
 }
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"teaching_manage/pkg/auth"
+	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/jobs"
+	"teaching_manage/pkg/logger"
+	requestx "teaching_manage/service/request"
+)
+
+type JobManager struct {
+	Ctx  context.Context
+	jobs *jobs.Manager
+}
+
+func NewJobManager(jobs *jobs.Manager) *JobManager {
+	return &JobManager{jobs: jobs}
+}
+
+// GetStatus 返回后台任务的状态：只有任务的所有者或管理员可以查看，任务结果可能包含导出文件路径等信息
+func (jm *JobManager) GetStatus(ctx context.Context, req *requestx.GetJobStatusRequest) (jobs.Snapshot, error) {
+	snap, err := jm.jobs.Get(req.JobID)
+	if err != nil {
+		return jobs.Snapshot{}, err
+	}
+	if err := checkJobOwner(ctx, snap); err != nil {
+		return jobs.Snapshot{}, err
+	}
+	return snap, nil
+}
+
+// JobOwner 返回 ctx 中登录用户的用户名，作为其启动的后台任务的所有者，实现 jobs.OwnerFunc
+func JobOwner(ctx context.Context) string {
+	p, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return ""
+	}
+	return p.Username
+}
+
+// Cancel 取消后台任务：只有任务的所有者或管理员可以取消
+func (jm *JobManager) Cancel(ctx context.Context, req *requestx.CancelJobRequest) (string, error) {
+	logger.InfoContext(ctx, "Cancelling job", logger.String("job_id", req.JobID))
+	snap, err := jm.jobs.Get(req.JobID)
+	if err != nil {
+		return "", err
+	}
+	if err := checkJobOwner(ctx, snap); err != nil {
+		return "", err
+	}
+	if err := jm.jobs.Cancel(req.JobID); err != nil {
+		return "", err
+	}
+	return "job cancelling", nil
+}
+
+// checkJobOwner 检查 ctx 中的登录用户是任务的所有者或管理员；未登录的内部调用不检查
+func checkJobOwner(ctx context.Context, snap jobs.Snapshot) error {
+	p, ok := auth.PrincipalFrom(ctx)
+	if !ok || (snap.Owner != "" && snap.Owner == p.Username) {
+		return nil
+	}
+	if err := auth.Require(ctx, PermSystemAdmin); err != nil {
+		return errorx.Wrap(errorx.KindPermissionDenied, err, fmt.Sprintf("job [%s] was started by another user", snap.ID))
+	}
+	return nil
+}
+
+func (jm *JobManager) RegisterRoute(d *dispatcher.Dispatcher) {
+	dispatcher.RegisterTyped(d, "jobs:get_status", jm.GetStatus)
+	dispatcher.RegisterTyped(d, "jobs:cancel", jm.Cancel)
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"teaching_manage/pkg/auth"
+	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/jobs"
+	"teaching_manage/pkg/wraper"
+)
+
+// tokenAuthenticator logs in callers by their session token.
+type tokenAuthenticator map[string]auth.Principal
+
+func (a tokenAuthenticator) Authenticate(_ context.Context, caller dispatcher.Caller) (auth.Principal, error) {
+	p, ok := a[caller.Token]
+	if !ok {
+		return auth.Principal{}, auth.ErrUnauthenticated
+	}
+	return p, nil
+}
+
+// startJobRoute is a route of the policy that starts a background job; the
+// job body checks PermRecordCharge like the record routes do.
+const startJobRoute = "record_manager:export_record_to_excel_async"
+
+// newTestJobDispatcher returns a dispatcher enforcing the service policy with
+// the job routes and startJobRoute registered, and its job runner wired like
+// main does.
+func newTestJobDispatcher(t *testing.T) (*dispatcher.Dispatcher, *jobs.Manager) {
+	t.Helper()
+	runner := jobs.NewManager(auth.CopyPrincipal, JobOwner)
+	d := dispatcher.New()
+	d.Use(auth.Enforce(NewPolicy(), tokenAuthenticator{
+		"admin":  {UserID: 1, Username: "admin", Role: RoleAdmin},
+		"clerk":  {UserID: 2, Username: "clerk", Role: RoleFrontDesk},
+		"clerk2": {UserID: 3, Username: "clerk2", Role: RoleFrontDesk},
+	}))
+	NewJobManager(runner).RegisterRoute(d)
+	dispatcher.RegisterNoReq(d, startJobRoute, func(ctx context.Context) (string, error) {
+		return runner.Start(ctx, "charge", func(ctx context.Context, _ jobs.ProgressFunc) (any, error) {
+			return "charged", auth.Require(ctx, PermRecordCharge)
+		}), nil
+	})
+	return d, runner
+}
+
+// startTestJob starts a job as the user of token and waits for it to finish.
+func startTestJob(t *testing.T, d *dispatcher.Dispatcher, runner *jobs.Manager, token string) jobs.Snapshot {
+	t.Helper()
+	resp, err := d.Dispatch(httpContext("10.0.0.1:5000", token), startJobRoute, nil)
+	if err != nil {
+		t.Fatalf("start job as %s: %v", token, err)
+	}
+	var started wraper.Response[string]
+	if err := json.Unmarshal([]byte(resp), &started); err != nil {
+		t.Fatalf("decode response %q: %v", resp, err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		snap, err := runner.Get(started.Data)
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", started.Data, err)
+		}
+		if snap.Status != jobs.StatusRunning {
+			return snap
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s still running", snap.ID)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestJobKeepsPermissions(t *testing.T) {
+	tests := []struct {
+		token string
+		want  jobs.Status
+	}{
+		{token: "clerk", want: jobs.StatusFailed},
+		{token: "admin", want: jobs.StatusSucceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			d, runner := newTestJobDispatcher(t)
+			if snap := startTestJob(t, d, runner, tt.token); snap.Status != tt.want {
+				t.Errorf("job status = %s (%s), want %s", snap.Status, snap.Error, tt.want)
+			}
+		})
+	}
+
+	// a principal without a policy cannot pass any check
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{Username: "admin", Role: RoleAdmin})
+	if err := auth.Require(ctx, PermRecordCharge); errorx.KindOf(err) != errorx.KindPermissionDenied {
+		t.Errorf("Require() without policy error = %v, want permission denied", err)
+	}
+}
+
+func TestJobOwnerOnly(t *testing.T) {
+	d, runner := newTestJobDispatcher(t)
+	job := startTestJob(t, d, runner, "clerk")
+	payload := json.RawMessage(`{"job_id":"` + job.ID + `"}`)
+
+	tests := []struct {
+		route    string
+		token    string
+		wantCode int
+	}{
+		{route: "jobs:get_status", token: "clerk", wantCode: wraper.CodeSuccess},
+		{route: "jobs:get_status", token: "admin", wantCode: wraper.CodeSuccess},
+		{route: "jobs:get_status", token: "clerk2", wantCode: wraper.CodeForbidden},
+		{route: "jobs:cancel", token: "clerk2", wantCode: wraper.CodeForbidden},
+		// the owner gets past the check, but the job has already finished
+		{route: "jobs:cancel", token: "clerk", wantCode: wraper.CodeConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.route+" as "+tt.token, func(t *testing.T) {
+			resp, err := d.Dispatch(httpContext("10.0.0.1:5000", tt.token), tt.route, payload)
+			if got := dispatcher.CodeOf(err); got != tt.wantCode {
+				t.Fatalf("Dispatch() error = %v (code %d), want code %d", err, got, tt.wantCode)
+			}
+			var snap wraper.Response[jobs.Snapshot]
+			if err := json.Unmarshal([]byte(resp), &snap); err != nil {
+				t.Fatalf("decode response %q: %v", resp, err)
+			}
+			if tt.wantCode != wraper.CodeSuccess && snap.Data.Result != nil {
+				t.Errorf("denied call returned the job result %v", snap.Data.Result)
+			}
+		})
+	}
+}
+
+// runTestJob starts a job with start and waits for it to finish. It returns
+// the final snapshot and the Done count of every progress event; the job is
+// cancelled on the progress event reporting cancelAt, if not 0.
+func runTestJob(t *testing.T, runner *jobs.Manager, cancelAt int, start func()) (jobs.Snapshot, []int) {
+	t.Helper()
+	var done []int
+	finished := make(chan jobs.Snapshot, 1)
+	runner.SetEmitter(func(event string, data any) {
+		snap := data.(jobs.Snapshot)
+		switch event {
+		case jobs.EventProgress:
+			done = append(done, snap.Done)
+			if cancelAt != 0 && snap.Done == cancelAt {
+				runner.Cancel(snap.ID)
+			}
+		case jobs.EventDone:
+			finished <- snap
+		}
+	})
+	start()
+	select {
+	case snap := <-finished:
+		return snap, done
+	case <-time.After(10 * time.Second):
+		t.Fatal("job did not finish")
+		return jobs.Snapshot{}, nil
+	}
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"teaching_manage/dao"
+	"teaching_manage/entity"
+	"teaching_manage/pkg"
+	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/logger"
+	"teaching_manage/repository"
+	requestx "teaching_manage/service/request"
+	responsex "teaching_manage/service/response"
+	"time"
+
+	wails "github.com/wailsapp/wails/v2/pkg/runtime"
+	"gorm.io/gorm"
+)
+
+// ledgerCorrectionComment 为补偿订单的备注前缀
+const ledgerCorrectionComment = "对账调整"
+
+// ledgerQueryTimeout 为单次对账请求的超时时间
+const ledgerQueryTimeout = 15 * time.Second
+
+// LedgerManager 核对学生课时余额与订单、上课记录流水是否一致。
+// 流水余额 = 生效订单课时之和 - 已激活上课记录消耗的课时之和；已删除的订单与记录不计入。
+type LedgerManager struct {
+	Ctx context.Context
+}
+
+func NewLedgerManager() *LedgerManager {
+	return &LedgerManager{}
+}
+
+// ledgerBalance 为单个学生的余额与流水汇总
+type ledgerBalance struct {
+	ID            uint
+	Name          string
+	Deleted       bool
+	Hours         int
+	OrderHours    int
+	ConsumedHours int
+}
+
+func (b ledgerBalance) expected() int {
+	return b.OrderHours - b.ConsumedHours
+}
+
+// loadLedgerBalances 汇总学生的余额与流水，studentIDs 为空时包含全部学生，withDeleted 为 false 时不含已删除学生
+func loadLedgerBalances(ctx context.Context, db *gorm.DB, studentIDs []uint, withDeleted bool) ([]ledgerBalance, error) {
+	query := `
+		SELECT
+			s.id, s.name, s.hours,
+			s.deleted_at IS NOT NULL AS deleted,
+			COALESCE((SELECT SUM(o.hours) FROM orders o
+				WHERE o.student_id = s.id AND o.active = 1 AND o.deleted_at IS NULL), 0) AS order_hours,
+			COALESCE((SELECT SUM(` + dao.ChargedHoursSQL("r") + `) FROM records r
+				WHERE r.student_id = s.id AND r.active = 1 AND r.deleted_at IS NULL), 0) AS consumed_hours
+		FROM students s
+	`
+	conds := []string{}
+	args := []any{}
+	if len(studentIDs) > 0 {
+		conds = append(conds, "s.id IN ?")
+		args = append(args, studentIDs)
+	}
+	if !withDeleted {
+		conds = append(conds, "s.deleted_at IS NULL")
+	}
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY s.id"
+
+	var balances []ledgerBalance
+	if err := db.WithContext(ctx).Raw(query, args...).Scan(&balances).Error; err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// Reconcile 重新计算学生的流水余额，返回余额不一致的学生及其参与计算的订单与记录
+func (lm *LedgerManager) Reconcile(ctx context.Context, req *requestx.ReconcileLedgerRequest) (responsex.LedgerReportResponse, error) {
+	logger.InfoContext(ctx, "Reconciling student hour ledger", logger.Int("requested_students", len(req.StudentIDs)))
+	db := dao.GetDBFromContext(ctx)
+
+	balances, err := loadLedgerBalances(ctx, db, req.StudentIDs, true)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to load ledger balances", logger.ErrorType(err))
+		return responsex.LedgerReportResponse{}, err
+	}
+
+	report := responsex.LedgerReportResponse{
+		CheckedStudents: len(balances),
+		Drifted:         []responsex.LedgerDriftDTO{},
+		GeneratedAt:     time.Now().UnixMilli(),
+	}
+	for _, b := range balances {
+		if b.Hours == b.expected() {
+			continue
+		}
+		drift, err := lm.driftDetail(ctx, db, b)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to load ledger rows", logger.UInt("student_id", b.ID), logger.ErrorType(err))
+			return responsex.LedgerReportResponse{}, err
+		}
+		report.Drifted = append(report.Drifted, drift)
+	}
+
+	logger.InfoContext(ctx, "Ledger reconciled",
+		logger.Int("checked_students", report.CheckedStudents),
+		logger.Int("drifted_students", len(report.Drifted)))
+	return report, nil
+}
+
+func (lm *LedgerManager) driftDetail(ctx context.Context, db *gorm.DB, b ledgerBalance) (responsex.LedgerDriftDTO, error) {
+	var orders []dao.Order
+	if err := db.Where("student_id = ? AND active = 1", b.ID).Order("id").Find(&orders).Error; err != nil {
+		return responsex.LedgerDriftDTO{}, err
+	}
+	var records []dao.Record
+	if err := db.Where("student_id = ? AND active = 1", b.ID).Order("teaching_date_ms, id").Find(&records).Error; err != nil {
+		return responsex.LedgerDriftDTO{}, err
+	}
+
+	drift := responsex.LedgerDriftDTO{
+		StudentID:     b.ID,
+		StudentName:   b.Name,
+		Deleted:       b.Deleted,
+		Hours:         b.Hours,
+		ExpectedHours: b.expected(),
+		Diff:          b.Hours - b.expected(),
+		OrderHours:    b.OrderHours,
+		ConsumedHours: b.ConsumedHours,
+		Orders:        make([]responsex.LedgerOrderDTO, 0, len(orders)),
+		Records:       make([]responsex.LedgerRecordDTO, 0, len(records)),
+	}
+	for _, o := range orders {
+		drift.Orders = append(drift.Orders, responsex.LedgerOrderDTO{
+			ID:        o.ID,
+			CreatedAt: o.CreatedAt.UnixMilli(),
+			Hours:     o.Hours,
+			Comment:   o.Comment,
+		})
+	}
+	for _, r := range records {
+		drift.Records = append(drift.Records, responsex.LedgerRecordDTO{
+			ID:           r.ID,
+			TeachingDate: r.TeachingDate.Format("2006-01-02"),
+			StartTime:    r.StartTime,
+			EndTime:      r.EndTime,
+			Hours:        chargedHours(entity.Record{Hours: r.Hours, Attendance: r.Attendance}),
+			Attendance:   r.Attendance,
+		})
+	}
+	return drift, nil
+}
+
+// ApplyCorrections 为余额与流水不一致的学生补一条补偿订单，使流水与当前余额一致。
+// 当前余额是已对外展示的数字，因此以余额为准修正流水，而不是改写余额。
+// 需要列出学生或设置 All；All 只修正未删除的学生，已删除的学生需单独列出。
+func (lm *LedgerManager) ApplyCorrections(ctx context.Context, req *requestx.ApplyLedgerCorrectionsRequest) (responsex.ApplyLedgerCorrectionsResponse, error) {
+	logger.InfoContext(ctx, "Applying ledger corrections", logger.Any("student_ids", req.StudentIDs), logger.Bool("all", req.All))
+	if req.All == (len(req.StudentIDs) > 0) {
+		return responsex.ApplyLedgerCorrectionsResponse{}, errorx.Validation("set either student_ids or all")
+	}
+	resp := responsex.ApplyLedgerCorrectionsResponse{Corrections: []responsex.LedgerCorrectionDTO{}}
+
+	db := dao.GetDBFromContext(ctx)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		txOrderRepo := repository.NewOrderRepository(dao.NewOrderDao(tx))
+
+		// 在事务内重新计算，避免按过期的报告修正
+		balances, err := loadLedgerBalances(ctx, tx, req.StudentIDs, !req.All)
+		if err != nil {
+			return err
+		}
+		found := make(map[uint]bool, len(balances))
+		for _, b := range balances {
+			found[b.ID] = true
+		}
+		for _, id := range req.StudentIDs {
+			if !found[id] {
+				return errorx.NotFound(fmt.Sprintf("student [%d] not found", id))
+			}
+		}
+
+		for _, b := range balances {
+			diff := b.Hours - b.expected()
+			if diff == 0 {
+				continue
+			}
+			order := entity.Order{
+				Student: entity.Student{ID: b.ID},
+				Hours:   diff,
+				Comment: fmt.Sprintf("%s: 余额%d, 流水%d", ledgerCorrectionComment, b.Hours, b.expected()),
+				Active:  true,
+			}
+			if err := txOrderRepo.CreateOrder(ctx, &order); err != nil {
+				logger.ErrorContext(ctx, "failed to create compensating order", logger.UInt("student_id", b.ID), logger.ErrorType(err))
+				return err
+			}
+			// 补偿订单只补齐流水，不改变余额
+			if err := writeAudit(ctx, tx, auditOrderCreate, auditEntityOrder, order.Id, nil, orderSnapshot(&order)); err != nil {
+				return err
+			}
+			logger.InfoContext(ctx, "compensating order created",
+				logger.UInt("student_id", b.ID), logger.Int("hours", diff))
+			resp.Corrections = append(resp.Corrections, responsex.LedgerCorrectionDTO{
+				StudentID:   b.ID,
+				StudentName: b.Name,
+				Hours:       diff,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to apply ledger corrections", logger.ErrorType(err))
+		return responsex.ApplyLedgerCorrectionsResponse{}, err
+	}
+	return resp, nil
+}
+
+// ExportReport 将对账报告导出为 Excel，每个不一致的学生后列出参与计算的订单与记录
+func (lm *LedgerManager) ExportReport(ctx context.Context, req *requestx.ReconcileLedgerRequest) (string, error) {
+	filepath, err := wails.SaveFileDialog(lm.Ctx, wails.SaveDialogOptions{
+		Title:           "选择导出文件位置",
+		DefaultFilename: fmt.Sprintf("ledger_report_%s.xlsx", time.Now().Format("20060102_150405")),
+		Filters:         []wails.FileFilter{{DisplayName: "Excel 文件", Pattern: "*.xlsx"}},
+	})
+	if err != nil {
+		return "", err
+	}
+	if filepath == "" {
+		return "cancel", nil
+	}
+
+	report, err := lm.Reconcile(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	headers := []string{"学生姓名", "当前余额", "流水余额", "差额", "明细类型", "明细ID", "日期", "课时变动", "备注"}
+	rows := make([][]string, 0)
+	for _, d := range report.Drifted {
+		name := d.StudentName
+		if d.Deleted {
+			name = fmt.Sprintf("%s (已删除)", name)
+		}
+		summary := []string{name, strconv.Itoa(d.Hours), strconv.Itoa(d.ExpectedHours), strconv.Itoa(d.Diff)}
+		rows = append(rows, summary)
+		for _, o := range d.Orders {
+			rows = append(rows, []string{name, "", "", "",
+				responsex.OrderDTOTypeToZhString(o.Hours),
+				strconv.FormatUint(uint64(o.ID), 10),
+				time.UnixMilli(o.CreatedAt).Format("2006-01-02 15:04:05"),
+				strconv.Itoa(o.Hours),
+				o.Comment,
+			})
+		}
+		for _, r := range d.Records {
+			rows = append(rows, []string{name, "", "", "",
+				"上课",
+				strconv.FormatUint(uint64(r.ID), 10),
+				fmt.Sprintf("%s %s - %s", r.TeachingDate, r.StartTime, r.EndTime),
+				strconv.Itoa(-r.Hours),
+				"",
+			})
+		}
+	}
+
+	if err := pkg.ExportToExcel(filepath, headers, rows); err != nil {
+		logger.ErrorContext(ctx, "failed to export ledger report", logger.ErrorType(err))
+		return "", fmt.Errorf("导出失败:请检查文件是否被占用或有读写权限")
+	}
+	return filepath, nil
+}
+
+func (lm *LedgerManager) RegisterRoute(d *dispatcher.Dispatcher) {
+	timeout := dispatcher.Timeout(ledgerQueryTimeout)
+	dispatcher.RegisterTyped(d, "ledger_manager:reconcile", lm.Reconcile, timeout)
+	dispatcher.RegisterTyped(d, "ledger_manager:apply_corrections", lm.ApplyCorrections)
+	dispatcher.RegisterTyped(d, "ledger_manager:export_report", lm.ExportReport, dispatcher.DesktopOnly())
+}
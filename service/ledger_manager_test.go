@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"teaching_manage/dao"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/repository"
+	requestx "teaching_manage/service/request"
+)
+
+func TestReconcileOpeningHours(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	teacher := dao.Teacher{Name: "李老师"}
+	if err := db.Create(&teacher).Error; err != nil {
+		t.Fatalf("create teacher: %v", err)
+	}
+	sm := NewStudentManager(repository.NewStudentRepository(dao.NewStudentDao(db)), repository.NewTeacherRepository(dao.NewTeacherDao(db)))
+	if _, err := sm.CreateStudent(ctx, &requestx.CreateStudentRequest{Name: "张三", Gender: "male", Hours: 10, TeacherID: teacher.ID}); err != nil {
+		t.Fatalf("CreateStudent() error = %v", err)
+	}
+	var student dao.Student
+	if err := db.Where("name = ?", "张三").First(&student).Error; err != nil {
+		t.Fatalf("find student: %v", err)
+	}
+
+	om := NewOrderManager(repository.NewOrderRepository(dao.NewOrderDao(db)), repository.NewStudentRepository(dao.NewStudentDao(db)))
+	if _, err := om.CreateOrder(ctx, &requestx.CreateOrderRequest{StudentID: student.ID, Hours: 5}); err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+
+	rm := newTestRecordManager(t, db)
+	record := func(date string, attendance string) uint {
+		return createTestRecord(t, db, rm, requestx.CreateRecordRequest{
+			StudentID: student.ID, TeachingDate: date, StartTime: "10:00", EndTime: "11:00", Attendance: attendance,
+		})
+	}
+	activate := func(id uint) {
+		if _, err := rm.ActivateRecord(ctx, &requestx.ActivateRecordRequest{RecordID: id}); err != nil {
+			t.Fatalf("ActivateRecord(%d) error = %v", id, err)
+		}
+	}
+	kept := record("2026-03-02", "")
+	activate(kept)
+	leave := record("2026-03-03", dao.AttendanceLeave)
+	activate(leave)
+	undone := record("2026-03-04", "")
+	activate(undone)
+	if _, err := rm.DeactivateRecord(ctx, &requestx.DeactivateRecordRequest{RecordID: undone, Reason: "记错了"}); err != nil {
+		t.Fatalf("DeactivateRecord() error = %v", err)
+	}
+	deleted := record("2026-03-05", "")
+	activate(deleted)
+	if _, err := rm.DeleteRecordByID(ctx, &requestx.DeleteRecordRequest{RecordID: deleted}); err != nil {
+		t.Fatalf("DeleteRecordByID() error = %v", err)
+	}
+	record("2026-03-06", "")
+
+	if got := studentHours(t, db, student.ID); got != 13 {
+		t.Fatalf("student hours = %d, want 13", got)
+	}
+	report, err := NewLedgerManager().Reconcile(ctx, &requestx.ReconcileLedgerRequest{})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if report.CheckedStudents != 1 || len(report.Drifted) != 0 {
+		t.Errorf("Reconcile() = %+v, want one student checked and no drift", report)
+	}
+}
+
+func TestApplyCorrections(t *testing.T) {
+	tests := []struct {
+		name string
+		// drift is set as the first student's balance behind the ledger's back
+		drift   int
+		req     func(ids []uint) requestx.ApplyLedgerCorrectionsRequest
+		want    map[int]int // student index -> correction hours
+		wantErr errorx.Kind
+	}{
+		{
+			name:  "drifted student listed",
+			drift: 3,
+			req: func(ids []uint) requestx.ApplyLedgerCorrectionsRequest {
+				return requestx.ApplyLedgerCorrectionsRequest{StudentIDs: ids[:1]}
+			},
+			want: map[int]int{0: 3},
+		},
+		{
+			name: "clean students listed",
+			req: func(ids []uint) requestx.ApplyLedgerCorrectionsRequest {
+				return requestx.ApplyLedgerCorrectionsRequest{StudentIDs: ids[:2]}
+			},
+			want: map[int]int{},
+		},
+		{
+			name:  "all skips deleted students",
+			drift: -2,
+			req: func([]uint) requestx.ApplyLedgerCorrectionsRequest {
+				return requestx.ApplyLedgerCorrectionsRequest{All: true}
+			},
+			want: map[int]int{0: -2},
+		},
+		{
+			name:    "no students",
+			req:     func([]uint) requestx.ApplyLedgerCorrectionsRequest { return requestx.ApplyLedgerCorrectionsRequest{} },
+			wantErr: errorx.KindValidation,
+		},
+		{
+			name: "students and all",
+			req: func(ids []uint) requestx.ApplyLedgerCorrectionsRequest {
+				return requestx.ApplyLedgerCorrectionsRequest{StudentIDs: ids, All: true}
+			},
+			wantErr: errorx.KindValidation,
+		},
+		{
+			name: "unknown student",
+			req: func([]uint) requestx.ApplyLedgerCorrectionsRequest {
+				return requestx.ApplyLedgerCorrectionsRequest{StudentIDs: []uint{999}}
+			},
+			wantErr: errorx.KindNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			ctx := context.Background()
+			students := []dao.Student{
+				createTestStudent(t, db, "张三", "李老师"),
+				createTestStudent(t, db, "李四", "王老师"),
+				createTestStudent(t, db, "王五", "赵老师"),
+			}
+			ids := []uint{students[0].ID, students[1].ID, students[2].ID}
+			setStudentHours(t, db, students[0].ID, tt.drift)
+			// a deleted student with drift is only corrected when listed
+			setStudentHours(t, db, students[2].ID, 7)
+			if err := db.Delete(&dao.Student{}, students[2].ID).Error; err != nil {
+				t.Fatalf("delete student: %v", err)
+			}
+
+			lm := NewLedgerManager()
+			req := tt.req(ids)
+			resp, err := lm.ApplyCorrections(ctx, &req)
+			if tt.wantErr != "" {
+				if errorx.KindOf(err) != tt.wantErr {
+					t.Fatalf("ApplyCorrections() error = %v, want kind %s", err, tt.wantErr)
+				}
+				var orders int64
+				db.Model(&dao.Order{}).Count(&orders)
+				if orders != 0 {
+					t.Errorf("failed call created %d orders", orders)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyCorrections() error = %v", err)
+			}
+
+			got := make(map[int]int, len(resp.Corrections))
+			for _, c := range resp.Corrections {
+				for i, s := range students {
+					if s.ID == c.StudentID {
+						got[i] = c.Hours
+					}
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Corrections = %+v, want %v", resp.Corrections, tt.want)
+			}
+			for i, hours := range tt.want {
+				if got[i] != hours {
+					t.Errorf("correction for student %d = %d, want %d", i, got[i], hours)
+				}
+			}
+			if got := studentHours(t, db, students[0].ID); got != tt.drift {
+				t.Errorf("balance = %d, want %d unchanged", got, tt.drift)
+			}
+
+			// corrections only fill in the ledger, so the corrected students no longer drift
+			report, err := lm.Reconcile(ctx, &requestx.ReconcileLedgerRequest{StudentIDs: ids[:2]})
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if len(report.Drifted) != 0 {
+				t.Errorf("Reconcile() after corrections = %+v, want no drift", report.Drifted)
+			}
+			for _, c := range resp.Corrections {
+				var order dao.Order
+				if err := db.Where("student_id = ?", c.StudentID).Last(&order).Error; err != nil {
+					t.Fatalf("find correction order: %v", err)
+				}
+				if len(auditLogs(t, db, auditOrderCreate, order.ID)) != 1 {
+					t.Errorf("correction order [%d] has no audit row", order.ID)
+				}
+			}
+		})
+	}
+}
@@ -2,11 +2,14 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"teaching_manage/dao"
 	"teaching_manage/entity"
 	"teaching_manage/pkg"
+	"teaching_manage/pkg/auth"
 	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/errorx"
 	"teaching_manage/pkg/logger"
 	"teaching_manage/repository"
 	requestx "teaching_manage/service/request"
@@ -28,8 +31,16 @@ func NewOrderManager(repo repository.OrderRepository, stuRepo repository.Student
 }
 
 func (om OrderManager) CreateOrder(ctx context.Context, order *requestx.CreateOrderRequest) (string, error) {
-	logger.Info("Creating order", logger.UInt("student_id", order.StudentID), logger.Int("hours", order.Hours), logger.String("comment", order.Comment))
-	db := dao.GetDB()
+	log := logger.FromContext(ctx).With(logger.UInt("student_id", order.StudentID), logger.Int("hours", order.Hours))
+	log.Info("Creating order", logger.String("comment", order.Comment))
+	// 扣课与退费会减少学生余额，需要单独的权限
+	if order.Hours < 0 {
+		if err := auth.Require(ctx, PermOrderDeduct); err != nil {
+			log.Warn("negative-hour order denied", logger.ErrorType(err))
+			return "", err
+		}
+	}
+	db := dao.GetDBFromContext(ctx)
 
 	err := db.Transaction(func(tx *gorm.DB) error {
 		txO := dao.NewOrderDao(tx)
@@ -40,10 +51,13 @@ func (om OrderManager) CreateOrder(ctx context.Context, order *requestx.CreateOr
 		// update student hours
 		student, err := strRepo.GetStudentByID(ctx, order.StudentID)
 		if err != nil {
+			if errors.Is(err, dao.ErrRecordNotFound) {
+				return errorx.Wrap(errorx.KindNotFound, err, fmt.Sprintf("student [%d] not found", order.StudentID))
+			}
 			return err
 		}
 
-		logger.Debug("student info:", logger.String("name", student.Name), logger.UInt("id", student.ID), logger.Int("current_hours", student.Hours))
+		log.Debug("student info:", logger.String("name", student.Name), logger.Int("current_hours", student.Hours))
 		err = strRepo.UpdateStudentHoursByID(ctx, student.ID, order.Hours)
 		if err != nil {
 			return err
@@ -54,7 +68,7 @@ func (om OrderManager) CreateOrder(ctx context.Context, order *requestx.CreateOr
 			return err
 		}
 
-		logger.Debug("updated student hours:", logger.String("name", updatedStudent.Name), logger.UInt("id", updatedStudent.ID), logger.Int("new_hours", updatedStudent.Hours))
+		log.Debug("updated student hours:", logger.String("name", updatedStudent.Name), logger.Int("new_hours", updatedStudent.Hours))
 		eOrder := entity.Order{
 			Student: entity.Student{ID: order.StudentID},
 			Hours:   order.Hours,
@@ -63,18 +77,29 @@ func (om OrderManager) CreateOrder(ctx context.Context, order *requestx.CreateOr
 		}
 
 		// create order record
-		err = oRepo.CreateOrder(ctx, eOrder)
+		err = oRepo.CreateOrder(ctx, &eOrder)
 		if err != nil {
-			logger.Error("failed to create order", logger.ErrorType(err))
+			log.Error("failed to create order", logger.ErrorType(err))
+			return err
+		}
+
+		if err := writeAudit(ctx, tx, auditOrderCreate, auditEntityOrder, eOrder.Id, nil, orderSnapshot(&eOrder)); err != nil {
+			return err
+		}
+		if err := writeHoursAudit(ctx, tx, student.ID, student.Hours, order.Hours); err != nil {
 			return err
 		}
 
-		logger.Info("order created successfully", logger.UInt("student_id", order.StudentID), logger.Int("hours", order.Hours))
+		log.Info("order created successfully")
 		return nil
 	})
 
 	if err != nil {
-		return "create order failed", fmt.Errorf("create order failed")
+		log.Error("failed to create order", logger.ErrorType(err))
+		if errorx.KindOf(err) == errorx.KindNotFound {
+			return "create order failed", err
+		}
+		return "create order failed", errorx.Internal(err, "create order failed")
 	}
 	return "order created", nil
 }
@@ -154,5 +179,5 @@ func (om OrderManager) exportToExcel(path string, stuName string, orders []entit
 func (om OrderManager) RegisterRoute(d *dispatcher.Dispatcher) {
 	dispatcher.RegisterTyped(d, "order_manager:create_order", om.CreateOrder)
 	dispatcher.RegisterTyped(d, "order_manager:get_orders_by_student_id", om.GetOrdersByStudentID)
-	dispatcher.RegisterTyped(d, "order_manager:export_orders_by_student_id", om.Export2ExcelByID)
+	dispatcher.RegisterTyped(d, "order_manager:export_orders_by_student_id", om.Export2ExcelByID, dispatcher.DesktopOnly())
 }
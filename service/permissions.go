@@ -0,0 +1,132 @@
+package service
+
+import "teaching_manage/pkg/auth"
+
+// 角色
+const (
+	RoleAdmin     = "admin"
+	RoleFrontDesk = "front_desk"
+	RoleTeacher   = "teacher"
+)
+
+// 权限，路由与权限的对应关系见 routePermissions
+const (
+	PermRead          auth.Permission = "read"
+	PermSelf          auth.Permission = "self"
+	PermStudentWrite  auth.Permission = "student:write"
+	PermStudentDelete auth.Permission = "student:delete"
+	PermTeacherWrite  auth.Permission = "teacher:write"
+	PermOrderCreate   auth.Permission = "order:create"
+	// PermOrderDeduct 允许创建课时为负数的订单（扣课、退费）
+	PermOrderDeduct auth.Permission = "order:deduct"
+	PermRecordWrite auth.Permission = "record:write"
+	// PermRecordCharge 允许激活记录、增加已激活记录扣除的课时，以及指定超过课时规则的课时
+	PermRecordCharge auth.Permission = "record:charge"
+	PermRecordDelete auth.Permission = "record:delete"
+	PermLedgerFix    auth.Permission = "ledger:correct"
+	PermAuditRead    auth.Permission = "audit:read"
+	PermSystemAdmin  auth.Permission = "system:admin"
+	PermUserAdmin    auth.Permission = "user:admin"
+)
+
+// rolePermissions 为各角色拥有的权限：前台可以登记学生、充值与记课，但不能扣课（包括激活记录）或删除学生；教师只读
+var rolePermissions = map[string][]auth.Permission{
+	RoleAdmin:     {auth.All},
+	RoleFrontDesk: {PermRead, PermSelf, PermStudentWrite, PermOrderCreate, PermRecordWrite},
+	RoleTeacher:   {PermRead, PermSelf},
+}
+
+// routePermissions 为每个路由需要的权限，未列出的路由一律拒绝
+var routePermissions = map[string]auth.Permission{
+	"auth:login":           auth.Public,
+	"auth:logout":          PermSelf,
+	"auth:current_user":    PermSelf,
+	"auth:change_password": PermSelf,
+
+	"user_manager:create_user":    PermUserAdmin,
+	"user_manager:get_user_list":  PermUserAdmin,
+	"user_manager:update_user":    PermUserAdmin,
+	"user_manager:reset_password": PermUserAdmin,
+
+	"student_manager:get_student_list": PermRead,
+	"student_manager:export_students":  PermRead,
+	"student_manager:create_student":   PermStudentWrite,
+	"student_manager:update_student":   PermStudentWrite,
+	"student_manager:delete_student":   PermStudentDelete,
+
+	"teacher_manager:get_teacher_list":        PermRead,
+	"teacher_manager:export_teacher_to_excel": PermRead,
+	"teacher_manager:create_teacher":          PermTeacherWrite,
+	"teacher_manager:update_teacher":          PermTeacherWrite,
+	"teacher_manager:delete_teacher":          PermTeacherWrite,
+
+	"order_manager:get_orders_by_student_id":    PermRead,
+	"order_manager:export_orders_by_student_id": PermRead,
+	// 负数课时的订单在 CreateOrder 中另外检查 PermOrderDeduct
+	"order_manager:create_order": PermOrderCreate,
+
+	"record_manager:get_record_list":                    PermRead,
+	"record_manager:export_record_to_excel":             PermRead,
+	"record_manager:export_record_to_excel_async":       PermRead,
+	"record_manager:download_import_template":           PermRead,
+	"record_manager:create_record":                      PermRecordWrite,
+	"record_manager:update_record":                      PermRecordWrite,
+	"record_manager:activate_record":                    PermRecordCharge,
+	"record_manager:batch_activate_records":             PermRecordCharge,
+	"record_manager:deactivate_record":                  PermRecordWrite,
+	"record_manager:batch_deactivate_records":           PermRecordWrite,
+	"record_manager:activate_all_pending_records":       PermRecordCharge,
+	"record_manager:activate_all_pending_records_async": PermRecordCharge,
+	"record_manager:select_import_file":                 PermRecordWrite,
+	"record_manager:import_from_excel":                  PermRecordWrite,
+	"record_manager:get_import_sheets":                  PermRecordWrite,
+	"record_manager:import_from_excel_async":            PermRecordWrite,
+	"record_manager:delete_record_by_id":                PermRecordDelete,
+
+	"schedule_manager:get_schedule_list": PermRead,
+	"schedule_manager:get_holiday_list":  PermRead,
+	"schedule_manager:create_schedule":   PermRecordWrite,
+	"schedule_manager:update_schedule":   PermRecordWrite,
+	"schedule_manager:cancel_schedule":   PermRecordWrite,
+	"schedule_manager:generate_records":  PermRecordWrite,
+	"schedule_manager:create_holiday":    PermRecordWrite,
+	"schedule_manager:delete_holiday":    PermRecordWrite,
+
+	"session_manager:get_session":      PermRead,
+	"session_manager:get_session_list": PermRead,
+	"session_manager:create_session":   PermRecordWrite,
+	"session_manager:add_attendees":    PermRecordWrite,
+	"session_manager:update_session":   PermRecordWrite,
+	"session_manager:cancel_session":   PermRecordWrite,
+	"session_manager:activate_session": PermRecordCharge,
+
+	"dashboard_manager:get_summary":            PermRead,
+	"dashboard_manager:get_finance_chart":      PermRead,
+	"dashboard_manager:get_teacher_rank":       PermRead,
+	"dashboard_manager:get_heatmap":            PermRead,
+	"dashboard_manager:get_student_engagement": PermRead,
+	"dashboard_manager:get_student_growth":     PermRead,
+	"dashboard_manager:get_student_balance":    PermRead,
+
+	"jobs:get_status": PermRead,
+	// 取消他人启动的任务在 JobManager.Cancel 中另外检查 PermSystemAdmin
+	"jobs:cancel": PermRecordWrite,
+
+	"ledger_manager:reconcile":         PermLedgerFix,
+	"ledger_manager:export_report":     PermLedgerFix,
+	"ledger_manager:apply_corrections": PermLedgerFix,
+
+	"audit:query":  PermAuditRead,
+	"audit:export": PermAuditRead,
+
+	"system:backup_now":     PermSystemAdmin,
+	"system:list_backups":   PermSystemAdmin,
+	"system:restore_backup": PermSystemAdmin,
+
+	"dispatcher:list_routes": PermRead,
+}
+
+// NewPolicy 返回按角色检查路由权限的策略
+func NewPolicy() *auth.Policy {
+	return auth.NewPolicy(rolePermissions, routePermissions)
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"teaching_manage/dao"
+	"teaching_manage/entity"
+	"teaching_manage/pkg/auth"
+	"teaching_manage/pkg/config"
+	"teaching_manage/pkg/errorx"
+	"time"
+)
+
+// HourRule 决定一条上课记录在激活时消耗的课时：固定课时，或按上课时长折算。
+// 记录创建时按规则算出课时并保存，之后修改配置不会影响已有记录。
+type HourRule struct {
+	cfg config.HoursConfig
+}
+
+func NewHourRule(cfg config.HoursConfig) (HourRule, error) {
+	if err := cfg.Validate(); err != nil {
+		return HourRule{}, err
+	}
+	return HourRule{cfg: cfg}, nil
+}
+
+// Hours 返回 start 至 end（HH:MM）的一节课消耗的课时；explicit 不为空时直接使用该课时
+func (r HourRule) Hours(start string, end string, explicit *int) (int, error) {
+	if explicit != nil {
+		if *explicit < 0 {
+			return 0, errorx.Validation("hours must not be negative")
+		}
+		return *explicit, nil
+	}
+	if r.cfg.Mode != config.HoursModeDuration {
+		return r.cfg.PerRecord, nil
+	}
+
+	startTime, err := time.Parse("15:04", start)
+	if err != nil {
+		return 0, errorx.Wrap(errorx.KindValidation, err, "invalid start time")
+	}
+	endTime, err := time.Parse("15:04", end)
+	if err != nil {
+		return 0, errorx.Wrap(errorx.KindValidation, err, "invalid end time")
+	}
+	minutes := int(endTime.Sub(startTime).Minutes())
+	if minutes <= 0 {
+		return 0, errorx.Validation("start time must be before end time")
+	}
+
+	unit := r.cfg.UnitMinutes
+	switch r.cfg.Rounding {
+	case config.RoundingUp:
+		return (minutes + unit - 1) / unit, nil
+	case config.RoundingDown:
+		return minutes / unit, nil
+	case config.RoundingNearest:
+		return (minutes + unit/2) / unit, nil
+	default:
+		return 0, fmt.Errorf("invalid hours rounding %q", r.cfg.Rounding)
+	}
+}
+
+// checkExplicit 检查请求中指定的课时：超过按规则计算的课时需要 PermRecordCharge，
+// 以免没有扣课权限的用户通过记课多扣学生余额。explicit 为空时不检查
+func (r HourRule) checkExplicit(ctx context.Context, start string, end string, explicit *int) error {
+	if explicit == nil {
+		return nil
+	}
+	ruled, err := r.Hours(start, end, nil)
+	if err != nil {
+		return err
+	}
+	if *explicit <= ruled {
+		return nil
+	}
+	if err := auth.Require(ctx, PermRecordCharge); err != nil {
+		return errorx.Wrap(errorx.KindPermissionDenied, err,
+			fmt.Sprintf("hours %d exceed the %d given by the hours rule; permission [%s] required", *explicit, ruled, PermRecordCharge))
+	}
+	return nil
+}
+
+// requireChargeIncrease 在已激活记录修改后多扣学生课时时检查 PermRecordCharge：
+// 同一学生比较前后扣除的课时，换了学生时新学生扣除的课时全部算作增加
+func requireChargeIncrease(ctx context.Context, before entity.Record, after entity.Record) error {
+	if !before.Active {
+		return nil
+	}
+	increase := chargedHours(after)
+	if after.Student.ID == before.Student.ID {
+		increase -= chargedHours(before)
+	}
+	if increase <= 0 {
+		return nil
+	}
+	return auth.Require(ctx, PermRecordCharge)
+}
+
+// chargedHours 返回记录激活时实际从学生余额扣除的课时：请假不扣，正常上课、缺课与补课扣除记录的课时。
+// 统计查询使用的 dao.ChargedHoursSQL 与此规则一致
+func chargedHours(r entity.Record) int {
+	if r.Attendance == dao.AttendanceLeave {
+		return 0
+	}
+	return r.Hours
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"testing"
+
+	"teaching_manage/pkg/config"
+	"teaching_manage/pkg/errorx"
+)
+
+func TestHourRuleHours(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+	duration := func(unit int, rounding string) config.HoursConfig {
+		return config.HoursConfig{Mode: config.HoursModeDuration, UnitMinutes: unit, Rounding: rounding}
+	}
+	fixed := config.HoursConfig{Mode: config.HoursModeFixed, PerRecord: 2}
+
+	tests := []struct {
+		name     string
+		cfg      config.HoursConfig
+		start    string
+		end      string
+		explicit *int
+		want     int
+		wantErr  errorx.Kind
+	}{
+		{name: "fixed", cfg: fixed, start: "09:00", end: "09:30", want: 2},
+		{name: "fixed ignores times", cfg: fixed, start: "10:00", end: "09:00", want: 2},
+		{name: "exact hours up", cfg: duration(60, config.RoundingUp), start: "09:00", end: "11:00", want: 2},
+		{name: "exact hours down", cfg: duration(60, config.RoundingDown), start: "09:00", end: "11:00", want: 2},
+		{name: "exact hours nearest", cfg: duration(60, config.RoundingNearest), start: "09:00", end: "11:00", want: 2},
+		{name: "x:30 up", cfg: duration(60, config.RoundingUp), start: "09:00", end: "10:30", want: 2},
+		{name: "x:30 down", cfg: duration(60, config.RoundingDown), start: "09:00", end: "10:30", want: 1},
+		{name: "x:30 nearest rounds half up", cfg: duration(60, config.RoundingNearest), start: "09:00", end: "10:30", want: 2},
+		{name: "x:29 nearest", cfg: duration(60, config.RoundingNearest), start: "09:00", end: "10:29", want: 1},
+		{name: "45 minute unit", cfg: duration(45, config.RoundingUp), start: "09:00", end: "10:30", want: 2},
+		{name: "shorter than a unit down", cfg: duration(60, config.RoundingDown), start: "09:00", end: "09:30", want: 0},
+		{name: "end equals start", cfg: duration(60, config.RoundingUp), start: "09:00", end: "09:00", wantErr: errorx.KindValidation},
+		{name: "end before start", cfg: duration(60, config.RoundingUp), start: "10:00", end: "09:00", wantErr: errorx.KindValidation},
+		{name: "invalid start", cfg: duration(60, config.RoundingUp), start: "9.5", end: "10:00", wantErr: errorx.KindValidation},
+		{name: "invalid end", cfg: duration(60, config.RoundingUp), start: "09:00", end: "", wantErr: errorx.KindValidation},
+		{name: "explicit overrides duration", cfg: duration(60, config.RoundingUp), start: "09:00", end: "10:30", explicit: intPtr(5), want: 5},
+		{name: "explicit overrides fixed", cfg: fixed, start: "09:00", end: "10:00", explicit: intPtr(0), want: 0},
+		{name: "explicit skips time checks", cfg: duration(60, config.RoundingUp), start: "10:00", end: "09:00", explicit: intPtr(1), want: 1},
+		{name: "explicit must not be negative", cfg: fixed, start: "09:00", end: "10:00", explicit: intPtr(-1), wantErr: errorx.KindValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := NewHourRule(tt.cfg)
+			if err != nil {
+				t.Fatalf("NewHourRule() error = %v", err)
+			}
+			got, err := rule.Hours(tt.start, tt.end, tt.explicit)
+			if errorx.KindOf(err) != tt.wantErr {
+				t.Fatalf("Hours() error = %v, want kind %q", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("Hours() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHourRuleInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.HoursConfig
+	}{
+		{name: "unknown mode", cfg: config.HoursConfig{Mode: "weekly"}},
+		{name: "negative per record", cfg: config.HoursConfig{Mode: config.HoursModeFixed, PerRecord: -1}},
+		{name: "zero unit", cfg: config.HoursConfig{Mode: config.HoursModeDuration, Rounding: config.RoundingUp}},
+		{name: "unknown rounding", cfg: config.HoursConfig{Mode: config.HoursModeDuration, UnitMinutes: 60, Rounding: "half"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHourRule(tt.cfg); err == nil {
+				t.Fatalf("NewHourRule(%+v) returned no error", tt.cfg)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// import_header_aliases 为导入文件各列可以使用的表头，比较前去除表头中的空白；
+// 前四列必须存在，备注列可以省略，列的顺序不限
+var import_header_aliases = [][]string{
+	{"学生姓名", "学生", "姓名"},
+	{"上课日期", "日期"},
+	{"开始时间", "开始"},
+	{"结束时间", "结束"},
+	{"备注", "说明"},
+}
+
+// 导入文件各列在 import_header_aliases 中的位置
+const (
+	importColumnStudent = iota
+	importColumnDate
+	importColumnStart
+	importColumnEnd
+	importColumnRemark
+)
+
+// mapImportColumns 按表头名称找出每一列所在的位置，缺少的备注列为 -1
+func mapImportColumns(header []string) ([]int, error) {
+	columns := make([]int, len(import_header_aliases))
+	for i := range columns {
+		columns[i] = -1
+	}
+	for col, cell := range header {
+		name := strings.Join(strings.Fields(cell), "")
+		for i, aliases := range import_header_aliases {
+			if columns[i] != -1 {
+				continue
+			}
+			for _, alias := range aliases {
+				if name == alias {
+					columns[i] = col
+				}
+			}
+		}
+	}
+
+	var missing []string
+	for i := importColumnStudent; i <= importColumnEnd; i++ {
+		if columns[i] == -1 {
+			missing = append(missing, import_header_aliases[i][0])
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("无效的表头，缺少 %s 列", strings.Join(missing, "、"))
+	}
+	return columns, nil
+}
+
+// selectImportSheet 返回要导入的工作表：sheet 不为空时必须存在，为空时选择第一个表头符合要求的工作表
+func selectImportSheet(f *excelize.File, sheet string) (string, error) {
+	sheets := f.GetSheetList()
+	if sheet != "" {
+		for _, s := range sheets {
+			if s == sheet {
+				return sheet, nil
+			}
+		}
+		return "", fmt.Errorf("工作表 '%s' 不存在，可选的工作表: %s", sheet, strings.Join(sheets, "、"))
+	}
+
+	for _, s := range sheets {
+		header, err := firstRow(f, s)
+		if err != nil {
+			return "", err
+		}
+		if _, err := mapImportColumns(header); err == nil {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("没有找到包含 学生姓名、上课日期、开始时间、结束时间 表头的工作表")
+}
+
+// firstRow 只读取工作表的第一行，避免为检查表头读入整个工作表
+func firstRow(f *excelize.File, sheet string) ([]string, error) {
+	rows, err := f.Rows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Error()
+	}
+	return rows.Columns()
+}
+
+// importCell 返回行中第 col 列的内容，列不存在时返回空字符串
+func importCell(row []string, col int) string {
+	if col < 0 || col >= len(row) {
+		return ""
+	}
+	return row[col]
+}
+
+// importCellNumeric 判断第 row 行（从 1 开始）第 col 列的单元格是否以数字保存，包括日期与时间单元格；
+// 只有这类单元格才按 Excel 序列号解析，文本单元格中的 "9.5"、"45000" 等不会被当作日期或时间
+func importCellNumeric(f *excelize.File, sheet string, row, col int) bool {
+	if col < 0 {
+		return false
+	}
+	cell, err := excelize.CoordinatesToCellName(col+1, row)
+	if err != nil {
+		return false
+	}
+	cellType, err := f.GetCellType(sheet, cell)
+	if err != nil {
+		return false
+	}
+	// 没有类型属性的单元格按数字保存
+	switch cellType {
+	case excelize.CellTypeUnset, excelize.CellTypeNumber, excelize.CellTypeDate:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseImportDate 解析导入文件中的上课日期，支持 YYYY-MM-DD、MM-DD-YYYY（分隔符可为 - / . 及全角符号）、
+// YYYY年MM月DD日，numeric 为 true 时还支持日期单元格或以数字保存的 Excel 日期序列号
+func parseImportDate(value string, numeric bool) (time.Time, error) {
+	value = strings.Join(strings.Fields(value), "")
+	if serial, err := strconv.ParseFloat(value, 64); numeric && err == nil {
+		// 9999-12-31 的序列号为 2958465，写成取反的形式以拒绝 NaN
+		if !(serial >= 1 && serial < 2958466) {
+			return time.Time{}, fmt.Errorf("invalid excel date serial %s", value)
+		}
+		t, err := excelize.ExcelDateToTime(serial, false)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+	}
+
+	value = strings.NewReplacer("－", "-", "／", "-", "/", "-", ".", "-", "年", "-", "月", "-", "日", "").Replace(value)
+	t, err := time.Parse("2006-1-2", value)
+	if err != nil {
+		// 尝试解析 MM-DD-YYYY 格式 (例如 12-01-2025)
+		t, err = time.Parse("1-2-2006", value)
+	}
+	return t, err
+}
+
+// parseImportTime 解析导入文件中的时间，支持 HH:MM、HH:MM:SS（冒号可为全角），numeric 为 true 时还支持
+// 时间单元格的 Excel 小数，返回 HH:MM 格式的时间
+func parseImportTime(value string, numeric bool) (string, error) {
+	value = strings.ReplaceAll(strings.Join(strings.Fields(value), ""), "：", ":")
+	if fraction, err := strconv.ParseFloat(value, 64); numeric && err == nil {
+		// 带日期的时间只取一天中的部分；没有小数部分的数字不是时间单元格（如只写了小时或 0）
+		_, part := math.Modf(fraction)
+		if math.IsInf(fraction, 0) || !(part > 0) {
+			return "", fmt.Errorf("invalid excel time %s", value)
+		}
+		minutes := int(math.Round(part*24*60)) % (24 * 60)
+		return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60), nil
+	}
+
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("15:04"), nil
+		}
+	}
+	return "", fmt.Errorf("invalid time %s", value)
+}
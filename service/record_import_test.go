@@ -0,0 +1,576 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"teaching_manage/dao"
+	"teaching_manage/entity"
+	"teaching_manage/pkg/config"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/jobs"
+	"teaching_manage/repository"
+	requestx "teaching_manage/service/request"
+
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// openTestDB initialises the global database on a temporary SQLite file.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dir := t.TempDir()
+	if err := dao.InitDB(filepath.Join(dir, "test.db"), filepath.Join(dir, "backups")); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	db := dao.GetDB()
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// createTestStudent creates a student taught by a new teacher.
+func createTestStudent(t *testing.T, db *gorm.DB, name string, teacherName string) dao.Student {
+	t.Helper()
+	teacher := dao.Teacher{Name: teacherName}
+	if err := db.Create(&teacher).Error; err != nil {
+		t.Fatalf("create teacher: %v", err)
+	}
+	student := dao.Student{Name: name, TeacherID: teacher.ID}
+	if err := db.Create(&student).Error; err != nil {
+		t.Fatalf("create student: %v", err)
+	}
+	return student
+}
+
+// writeTestWorkbook saves rows to an xlsx file in a temporary directory; a
+// nil rows value leaves the sheet empty. Cells are written with their Go type,
+// so time.Time and float64 values become numeric cells.
+func writeTestWorkbook(t *testing.T, sheets map[string][][]any) string {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	first := true
+	for name, rows := range sheets {
+		if first {
+			if err := f.SetSheetName("Sheet1", name); err != nil {
+				t.Fatalf("rename sheet: %v", err)
+			}
+			first = false
+		} else if _, err := f.NewSheet(name); err != nil {
+			t.Fatalf("new sheet: %v", err)
+		}
+		for i, row := range rows {
+			cell, _ := excelize.CoordinatesToCellName(1, i+1)
+			if err := f.SetSheetRow(name, cell, &row); err != nil {
+				t.Fatalf("set row: %v", err)
+			}
+		}
+	}
+	path := filepath.Join(t.TempDir(), "import.xlsx")
+	if err := f.SaveAs(path); err != nil {
+		t.Fatalf("save workbook: %v", err)
+	}
+	return path
+}
+
+func testDate(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestMapImportColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  []string
+		want    []int
+		wantErr bool
+	}{
+		{
+			name:   "template order",
+			header: []string{"学生姓名", "上课日期", "开始时间", "结束时间", "备注"},
+			want:   []int{0, 1, 2, 3, 4},
+		},
+		{
+			name:   "aliases in any order with blanks",
+			header: []string{"说明", " 结束 ", "开始", "日 期", "姓名"},
+			want:   []int{4, 3, 2, 1, 0},
+		},
+		{
+			name:   "remark column is optional",
+			header: []string{"学生姓名", "上课日期", "开始时间", "结束时间"},
+			want:   []int{0, 1, 2, 3, -1},
+		},
+		{
+			name:   "first matching column wins",
+			header: []string{"学生", "姓名", "上课日期", "开始时间", "结束时间"},
+			want:   []int{0, 2, 3, 4, -1},
+		},
+		{
+			name:    "missing end time",
+			header:  []string{"学生姓名", "上课日期", "开始时间", "备注"},
+			wantErr: true,
+		},
+		{
+			name:    "empty header",
+			header:  nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mapImportColumns(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mapImportColumns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("mapImportColumns() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("mapImportColumns() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectImportSheet(t *testing.T) {
+	header := []any{"学生姓名", "上课日期", "开始时间", "结束时间"}
+	path := writeTestWorkbook(t, map[string][][]any{
+		"说明": {{"本文件为课时记录"}},
+		"课时": {header},
+		"空白": nil,
+	})
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatalf("open workbook: %v", err)
+	}
+	defer f.Close()
+
+	noMatch := writeTestWorkbook(t, map[string][][]any{"说明": {{"本文件为课时记录"}}})
+	g, err := excelize.OpenFile(noMatch)
+	if err != nil {
+		t.Fatalf("open workbook: %v", err)
+	}
+	defer g.Close()
+
+	tests := []struct {
+		name    string
+		file    *excelize.File
+		sheet   string
+		want    string
+		wantErr bool
+	}{
+		{name: "detects sheet by header", file: f, sheet: "", want: "课时"},
+		{name: "named sheet is used as is", file: f, sheet: "说明", want: "说明"},
+		{name: "named sheet must exist", file: f, sheet: "不存在", wantErr: true},
+		{name: "no sheet with a valid header", file: g, sheet: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectImportSheet(tt.file, tt.sheet)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectImportSheet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("selectImportSheet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImportDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		numeric bool
+		want    string
+		wantErr bool
+	}{
+		{name: "iso", value: "2025-03-01", want: "2025-03-01"},
+		{name: "single digits", value: "2025-3-1", want: "2025-03-01"},
+		{name: "slashes", value: "2025/03/01", want: "2025-03-01"},
+		{name: "dots", value: "2025.3.1", want: "2025-03-01"},
+		{name: "full width", value: "2025－03－01", want: "2025-03-01"},
+		{name: "month first", value: "03/01/2025", want: "2025-03-01"},
+		{name: "chinese", value: "2025年3月1日", want: "2025-03-01"},
+		{name: "blanks", value: " 2025 - 03 - 01 ", want: "2025-03-01"},
+		{name: "serial in numeric cell", value: "45717", numeric: true, want: "2025-03-01"},
+		{name: "serial with time part", value: "45717.75", numeric: true, want: "2025-03-01"},
+		{name: "largest serial", value: "2958465", numeric: true, want: "9999-12-31"},
+		{name: "serial in text cell", value: "45717", wantErr: true},
+		{name: "zero serial", value: "0", numeric: true, wantErr: true},
+		{name: "negative serial", value: "-1", numeric: true, wantErr: true},
+		{name: "serial out of range", value: "2958466", numeric: true, wantErr: true},
+		{name: "nan", value: "NaN", numeric: true, wantErr: true},
+		{name: "inf", value: "Inf", numeric: true, wantErr: true},
+		{name: "bare year", value: "2025", wantErr: true},
+		{name: "invalid day", value: "2025-02-30", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseImportDate(tt.value, tt.numeric)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseImportDate(%q, %v) error = %v, wantErr %v", tt.value, tt.numeric, err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Format("2006-01-02") != tt.want {
+				t.Fatalf("parseImportDate(%q, %v) = %s, want %s", tt.value, tt.numeric, got.Format("2006-01-02"), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImportTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		numeric bool
+		want    string
+		wantErr bool
+	}{
+		{name: "hh:mm", value: "09:30", want: "09:30"},
+		{name: "single digit hour", value: "9:30", want: "09:30"},
+		{name: "with seconds", value: "09:30:00", want: "09:30"},
+		{name: "full width colon", value: "09：30", want: "09:30"},
+		{name: "blanks", value: " 9 : 30 ", want: "09:30"},
+		{name: "fraction in numeric cell", value: "0.395833333333333", numeric: true, want: "09:30"},
+		{name: "date and time in numeric cell", value: "45717.5", numeric: true, want: "12:00"},
+		{name: "fraction in text cell", value: "0.5", wantErr: true},
+		{name: "decimal hours in text cell", value: "9.5", wantErr: true},
+		{name: "whole number in numeric cell", value: "9", numeric: true, wantErr: true},
+		{name: "zero in numeric cell", value: "0", numeric: true, wantErr: true},
+		{name: "negative in numeric cell", value: "-0.5", numeric: true, wantErr: true},
+		{name: "nan", value: "NaN", numeric: true, wantErr: true},
+		{name: "inf", value: "Inf", numeric: true, wantErr: true},
+		{name: "out of range", value: "25:00", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseImportTime(tt.value, tt.numeric)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseImportTime(%q, %v) error = %v, wantErr %v", tt.value, tt.numeric, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("parseImportTime(%q, %v) = %q, want %q", tt.value, tt.numeric, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTeachingRecordsCellTypes(t *testing.T) {
+	path := writeTestWorkbook(t, map[string][][]any{"课时": {
+		{"学生姓名", "上课日期", "开始时间", "结束时间"},
+		{"张三", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), 0.375, 0.4375},
+		{"李四", "2025-03-01", "9.5", "10:30"},
+		{"王五", "45717", "09:00", "10:00"},
+	}})
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatalf("open workbook: %v", err)
+	}
+	defer f.Close()
+
+	records, errInfo, rows, err := validateTeachingRecords(context.Background(), f, "", false)
+	if err != nil {
+		t.Fatalf("validateTeachingRecords() error = %v", err)
+	}
+	if len(records) != 3 || len(errInfo) != 3 || len(rows) != 4 {
+		t.Fatalf("got %d records, %d errInfo, %d rows; want 3, 3, 4", len(records), len(errInfo), len(rows))
+	}
+	if len(errInfo[0]) != 0 {
+		t.Fatalf("row 2 errors = %v, want none", errInfo[0])
+	}
+	got := records[0]
+	if got.TeachingDate.Format("2006-01-02") != "2025-03-01" || got.StartTime != "09:00" || got.EndTime != "10:30" {
+		t.Fatalf("row 2 = %s %s-%s, want 2025-03-01 09:00-10:30",
+			got.TeachingDate.Format("2006-01-02"), got.StartTime, got.EndTime)
+	}
+	if len(errInfo[1]) == 0 {
+		t.Fatalf("row 3 with text time 9.5 was accepted")
+	}
+	if len(errInfo[2]) == 0 {
+		t.Fatalf("row 4 with text date 45717 was accepted")
+	}
+}
+
+func TestCheckImportRowConflicts(t *testing.T) {
+	day := testDate("2025-03-01")
+	rec := func(name string, date time.Time, start, end string) entity.Record {
+		return entity.Record{Student: entity.Student{Name: name}, TeachingDate: date, StartTime: start, EndTime: end}
+	}
+	tests := []struct {
+		name         string
+		records      []entity.Record
+		preErrors    map[int]bool
+		allowOverlap bool
+		// wantErrors maps each row index that gets an error to the row number the error must mention
+		wantErrors map[int]string
+	}{
+		{
+			name: "no conflicts",
+			records: []entity.Record{
+				rec("张三", day, "09:00", "10:00"),
+				rec("李四", day, "09:00", "10:00"),
+				rec("张三", day.AddDate(0, 0, 1), "09:00", "10:00"),
+			},
+		},
+		{
+			name: "touching lessons do not overlap",
+			records: []entity.Record{
+				rec("张三", day, "09:00", "10:00"),
+				rec("张三", day, "10:00", "11:00"),
+			},
+		},
+		{
+			name: "duplicate goes on the later row",
+			records: []entity.Record{
+				rec("张三", day, "09:00", "10:00"),
+				rec("李四", day, "09:00", "10:00"),
+				rec("张三", day, "09:00", "10:00"),
+			},
+			wantErrors: map[int]string{2: "第 2 行"},
+		},
+		{
+			name: "overlap goes on the later row",
+			records: []entity.Record{
+				rec("张三", day, "09:00", "10:00"),
+				rec("张三", day, "09:30", "10:30"),
+			},
+			wantErrors: map[int]string{1: "第 2 行"},
+		},
+		{
+			name: "overlap allowed",
+			records: []entity.Record{
+				rec("张三", day, "09:00", "10:00"),
+				rec("张三", day, "09:30", "10:30"),
+			},
+			allowOverlap: true,
+		},
+		{
+			name: "duplicate reported even when overlap is allowed",
+			records: []entity.Record{
+				rec("张三", day, "09:00", "10:00"),
+				rec("张三", day, "09:00", "10:00"),
+			},
+			allowOverlap: true,
+			wantErrors:   map[int]string{1: "第 2 行"},
+		},
+		{
+			name: "rows with format errors are ignored",
+			records: []entity.Record{
+				rec("张三", day, "09:00", "10:00"),
+				rec("张三", day, "09:00", "10:00"),
+				rec("张三", day, "09:30", "10:30"),
+			},
+			preErrors:  map[int]bool{0: true},
+			wantErrors: map[int]string{2: "第 3 行"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errInfo := make([][]string, len(tt.records))
+			for i := range tt.preErrors {
+				errInfo[i] = []string{"format error"}
+			}
+			checkImportRowConflicts(tt.records, errInfo, tt.allowOverlap)
+			for i, rowErr := range errInfo {
+				if tt.preErrors[i] {
+					if len(rowErr) != 1 {
+						t.Fatalf("row %d errors = %v, want only the format error", i+2, rowErr)
+					}
+					continue
+				}
+				ref, want := tt.wantErrors[i]
+				if !want {
+					if len(rowErr) != 0 {
+						t.Fatalf("row %d errors = %v, want none", i+2, rowErr)
+					}
+					continue
+				}
+				if len(rowErr) != 1 || !strings.Contains(rowErr[0], ref) {
+					t.Fatalf("row %d errors = %v, want one error mentioning %s", i+2, rowErr, ref)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckImportExistingRecords(t *testing.T) {
+	db := openTestDB(t)
+	student := createTestStudent(t, db, "张三", "王老师")
+	day := testDate("2025-03-01")
+	existing := dao.Record{StudentID: student.ID, TeacherID: student.TeacherID, TeachingDate: day,
+		StartTime: "09:00", EndTime: "10:00", Attendance: dao.AttendanceAttended}
+	if err := dao.NewRecordDao(db).CreateRecord(context.Background(), &existing); err != nil {
+		t.Fatalf("create record: %v", err)
+	}
+
+	rec := func(name string, start, end string) entity.Record {
+		return entity.Record{Student: entity.Student{Name: name}, TeachingDate: day, StartTime: start, EndTime: end}
+	}
+	tests := []struct {
+		name         string
+		record       entity.Record
+		allowOverlap bool
+		wantErr      string
+	}{
+		{name: "free slot", record: rec("张三", "10:00", "11:00")},
+		{name: "duplicate", record: rec("张三", "09:00", "10:00"), wantErr: "重复导入"},
+		{name: "duplicate with overlap allowed", record: rec("张三", "09:00", "10:00"), allowOverlap: true, wantErr: "重复导入"},
+		{name: "overlap", record: rec("张三", "09:30", "10:30"), wantErr: "重叠"},
+		{name: "overlap allowed", record: rec("张三", "09:30", "10:30"), allowOverlap: true},
+		{name: "unknown student is left to the import", record: rec("李四", "09:00", "10:00")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errInfo := make([][]string, 1)
+			if err := checkImportExistingRecords(context.Background(), []entity.Record{tt.record}, errInfo, tt.allowOverlap); err != nil {
+				t.Fatalf("checkImportExistingRecords() error = %v", err)
+			}
+			if tt.wantErr == "" {
+				if len(errInfo[0]) != 0 {
+					t.Fatalf("errors = %v, want none", errInfo[0])
+				}
+				return
+			}
+			if len(errInfo[0]) != 1 || !strings.Contains(errInfo[0][0], tt.wantErr) {
+				t.Fatalf("errors = %v, want one error containing %q", errInfo[0], tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestImportFromExcelModes(t *testing.T) {
+	db := openTestDB(t)
+	createTestStudent(t, db, "张三", "王老师")
+	createTestStudent(t, db, "李四", "赵老师")
+	rule, err := NewHourRule(config.HoursConfig{Mode: config.HoursModeFixed, PerRecord: 1})
+	if err != nil {
+		t.Fatalf("NewHourRule: %v", err)
+	}
+	rm := NewRecordManager(repository.NewRecordRepository(dao.NewRecordDao(db)),
+		repository.NewStudentRepository(dao.NewStudentDao(db)), nil, rule)
+
+	// two valid rows, a format error, an unknown student and an in-file duplicate
+	path := writeTestWorkbook(t, map[string][][]any{"课时": {
+		{"学生姓名", "上课日期", "开始时间", "结束时间", "备注"},
+		{"张三", "2025-03-01", "09:00", "10:00", ""},
+		{"李四", "2025-03-01", "09:00", "10:00", ""},
+		{"张三", "2025-03-02", "9.5", "10:00", ""},
+		{"王五", "2025-03-01", "09:00", "10:00", ""},
+		{"张三", "2025-03-01", "09:00", "10:00", "重复"},
+	}})
+
+	tests := []struct {
+		mode        string
+		wantErr     errorx.Kind
+		wantPassed  int
+		wantFailed  int
+		wantCreated int64
+	}{
+		{mode: requestx.ImportModeAll, wantErr: errorx.KindValidation},
+		{mode: requestx.ImportModeDryRun, wantPassed: 2, wantFailed: 3},
+		{mode: requestx.ImportModeSkipErrors, wantPassed: 2, wantFailed: 3, wantCreated: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			t.Cleanup(func() {
+				db.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(&dao.Record{})
+			})
+			resp, err := rm.ImportFromExcel(context.Background(), &requestx.ImportRecordsRequest{Filepath: path, Mode: tt.mode})
+			if errorx.KindOf(err) != tt.wantErr {
+				t.Fatalf("ImportFromExcel() error = %v, want kind %q", err, tt.wantErr)
+			}
+			var created int64
+			if err := db.Model(&dao.Record{}).Count(&created).Error; err != nil {
+				t.Fatalf("count records: %v", err)
+			}
+			if created != tt.wantCreated {
+				t.Fatalf("created %d records, want %d", created, tt.wantCreated)
+			}
+			if err != nil {
+				return
+			}
+			if resp.Passed != tt.wantPassed || resp.Failed != tt.wantFailed || len(resp.Rows) != 5 {
+				t.Fatalf("passed %d, failed %d, %d rows; want %d, %d, 5",
+					resp.Passed, resp.Failed, len(resp.Rows), tt.wantPassed, tt.wantFailed)
+			}
+			if tt.mode != requestx.ImportModeSkipErrors {
+				if resp.ErrorFilepath != "" {
+					t.Fatalf("ErrorFilepath = %q, want none", resp.ErrorFilepath)
+				}
+				return
+			}
+			if _, err := os.Stat(resp.ErrorFilepath); err != nil {
+				t.Fatalf("error workbook: %v", err)
+			}
+		})
+	}
+}
+
+func TestImportFromExcelJob(t *testing.T) {
+	rows := [][]any{{"学生姓名", "上课日期", "开始时间", "结束时间", "备注"}}
+	for _, start := range []string{"08:00", "09:00", "10:00", "11:00", "12:00"} {
+		rows = append(rows, []any{"张三", "2025-03-01", start, start[:2] + ":45", ""})
+	}
+	path := writeTestWorkbook(t, map[string][][]any{"课时": rows})
+
+	tests := []struct {
+		name string
+		// cancelAt cancels the job when it reports that many rows done
+		cancelAt    int
+		wantStatus  jobs.Status
+		wantDone    []int
+		wantCreated int64
+	}{
+		{name: "finishes", wantStatus: jobs.StatusSucceeded, wantDone: []int{0, 1, 2, 3, 4, 5}, wantCreated: 5},
+		{name: "cancelled mid-import", cancelAt: 3, wantStatus: jobs.StatusCancelled, wantDone: []int{0, 1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			createTestStudent(t, db, "张三", "王老师")
+			rule, err := NewHourRule(config.HoursConfig{Mode: config.HoursModeFixed, PerRecord: 1})
+			if err != nil {
+				t.Fatalf("NewHourRule: %v", err)
+			}
+			runner := jobs.NewManager(nil, nil)
+			rm := NewRecordManager(repository.NewRecordRepository(dao.NewRecordDao(db)),
+				repository.NewStudentRepository(dao.NewStudentDao(db)), runner, rule)
+
+			snap, done := runTestJob(t, runner, tt.cancelAt, func() {
+				rm.ImportFromExcelAsync(context.Background(), &requestx.ImportRecordsRequest{Filepath: path})
+			})
+			if snap.Status != tt.wantStatus {
+				t.Fatalf("job status = %s (%s), want %s", snap.Status, snap.Error, tt.wantStatus)
+			}
+			if !slices.Equal(done, tt.wantDone) {
+				t.Errorf("progress = %v, want %v", done, tt.wantDone)
+			}
+			var created int64
+			if err := db.Model(&dao.Record{}).Count(&created).Error; err != nil {
+				t.Fatalf("count records: %v", err)
+			}
+			if created != tt.wantCreated {
+				t.Errorf("created %d records, want %d", created, tt.wantCreated)
+			}
+		})
+	}
+}
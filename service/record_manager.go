@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"teaching_manage/dao"
 	"teaching_manage/entity"
 	"teaching_manage/pkg"
 	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/jobs"
 	"teaching_manage/pkg/logger"
 	"teaching_manage/repository"
 	requestx "teaching_manage/service/request"
@@ -20,84 +23,316 @@ import (
 	"gorm.io/gorm"
 )
 
-var template_excel_headers = []string{"学生姓名", "上课日期", "开始时间", "结束时间", "备注	"}
+var template_excel_headers = []string{"学生姓名", "上课日期", "开始时间", "结束时间", "备注"}
 
 type RecordManager struct {
 	Ctx   context.Context
 	repo  repository.RecordRepository
 	repoS repository.StudentRepository
+	jobs  *jobs.Manager
+	hours HourRule
 }
 
-func NewRecordManager(repo repository.RecordRepository, repoS repository.StudentRepository) *RecordManager {
-	return &RecordManager{repo: repo, repoS: repoS}
+func NewRecordManager(repo repository.RecordRepository, repoS repository.StudentRepository, jobs *jobs.Manager, hours HourRule) *RecordManager {
+	return &RecordManager{repo: repo, repoS: repoS, jobs: jobs, hours: hours}
 }
 
 func (rm RecordManager) CreateRecord(ctx context.Context, req *requestx.CreateRecordRequest) (string, error) {
-	logger.Info("Creating one record",
+	logger.InfoContext(ctx, "Creating one record",
 		logger.UInt("student_id", req.StudentID),
 		logger.String("teaching_date", req.TeachingDate),
 		logger.String("start_time", req.StartTime),
 		logger.String("end_time", req.EndTime),
 	)
 
-	// 解析教学日期
-	teachingDate, err := time.Parse("2006-01-02", req.TeachingDate)
+	teachingDate, err := parseRecordSchedule(req.TeachingDate, req.StartTime, req.EndTime)
 	if err != nil {
 		return "", err
 	}
-
-	// 验证开始时间是否在结束时间之前
-	startTime, err := time.Parse("15:04", req.StartTime)
-	if err != nil {
+	if err := rm.hours.checkExplicit(ctx, req.StartTime, req.EndTime, req.Hours); err != nil {
 		return "", err
 	}
-	endTime, err := time.Parse("15:04", req.EndTime)
+	hours, err := rm.hours.Hours(req.StartTime, req.EndTime, req.Hours)
 	if err != nil {
 		return "", err
 	}
-	if !startTime.Before(endTime) {
-		return "", fmt.Errorf("start time must be before end time")
-	}
 
 	// 检查教师是否存在（假设教师ID通过请求传入，这里暂时使用学生ID作为教师ID示例）
 	student, err := rm.repoS.GetStudentByID(ctx, req.StudentID)
 	if err != nil {
-		logger.Error("failed to get student by ID", logger.UInt("student_id", req.StudentID), logger.ErrorType(err))
-		return "cant find student", fmt.Errorf("student not found: %v", err)
+		logger.ErrorContext(ctx, "failed to get student by ID", logger.UInt("student_id", req.StudentID), logger.ErrorType(err))
+		return "cant find student", errorx.Wrap(errorx.KindNotFound, err, fmt.Sprintf("student not found: %v", err))
 	}
 
 	if student.Teacher.ID == 0 || !student.Teacher.DeletedAt.IsZero() {
-		logger.Error("associated teacher not found for student", logger.UInt("student_id", req.StudentID),
+		logger.ErrorContext(ctx, "associated teacher not found for student", logger.UInt("student_id", req.StudentID),
 			logger.UInt("teacher_id", student.Teacher.ID), logger.String("teacher_deleted_at", student.Teacher.DeletedAt.Local().String()))
 		return fmt.Sprintf("associated teacher not found for student Name %s, ", student.Name),
-			fmt.Errorf("associated teacher not found for student ID %d", req.StudentID)
+			errorx.NotFound(fmt.Sprintf("associated teacher not found for student ID %d", req.StudentID))
 	}
 	teacherID := student.Teacher.ID
 
+	attendance := req.Attendance
+	if attendance == "" {
+		attendance = dao.AttendanceAttended
+	}
 	record := &entity.Record{
 		Student:      entity.Student{ID: req.StudentID},
 		Teacher:      entity.Teacher{ID: teacherID},
 		TeachingDate: teachingDate,
 		StartTime:    req.StartTime,
 		EndTime:      req.EndTime,
+		Hours:        hours,
 		Remark:       req.Remark,
+		Attendance:   attendance,
+		MakeupForID:  req.MakeupForID,
 	}
 
-	err = rm.repo.CreateRecord(ctx, record)
+	err = dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txRecordRepo := repository.NewRecordRepository(dao.NewRecordDao(tx))
+		if err := checkMakeup(ctx, txRecordRepo, *record); err != nil {
+			return err
+		}
+		if !req.AllowOverlap {
+			clash, err := findRecordOverlap(ctx, txRecordRepo, *record)
+			if err != nil {
+				return err
+			}
+			if clash != nil {
+				return recordOverlapError(*record, clash)
+			}
+		}
+		if err := txRecordRepo.CreateRecord(ctx, record); err != nil {
+			return err
+		}
+		record.Student.Name = student.Name
+		record.Teacher.Name = student.Teacher.Name
+		return writeAudit(ctx, tx, auditRecordCreate, auditEntityRecord, record.ID, nil, recordSnapshot(record))
+	})
+	if errors.Is(err, dao.ErrDuplicatedKey) {
+		return "", errorx.Wrap(errorx.KindConflict, err, "duplicate: record already exists")
+	}
 	if err != nil {
 		return "", err
 	}
 	return "Record created successfully", nil
 }
 
+// parseRecordSchedule 校验上课日期与时间，返回解析后的上课日期
+func parseRecordSchedule(date string, start string, end string) (time.Time, error) {
+	// 解析教学日期
+	teachingDate, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return time.Time{}, errorx.Wrap(errorx.KindValidation, err, "invalid teaching date")
+	}
+
+	// 验证开始时间是否在结束时间之前
+	startTime, err := time.Parse("15:04", start)
+	if err != nil {
+		return time.Time{}, errorx.Wrap(errorx.KindValidation, err, "invalid start time")
+	}
+	endTime, err := time.Parse("15:04", end)
+	if err != nil {
+		return time.Time{}, errorx.Wrap(errorx.KindValidation, err, "invalid end time")
+	}
+	if !startTime.Before(endTime) {
+		return time.Time{}, errorx.Validation("start time must be before end time")
+	}
+	return teachingDate, nil
+}
+
+// checkMakeup 校验补课关联：只有补课记录可以关联，且必须补同一学生的请假或缺课记录，一节课只能补一次
+func checkMakeup(ctx context.Context, repo repository.RecordRepository, record entity.Record) error {
+	if record.Attendance != dao.AttendanceMakeup {
+		if record.MakeupForID != 0 {
+			return errorx.Validation("makeup_for_id is only allowed for makeup records")
+		}
+		return nil
+	}
+	if record.MakeupForID == 0 {
+		return errorx.Validation("makeup record must set makeup_for_id")
+	}
+	if record.MakeupForID == record.ID {
+		return errorx.Validation("record cannot be a makeup for itself")
+	}
+	original, err := repo.GetRecordByID(ctx, record.MakeupForID)
+	if err != nil {
+		return errorx.Wrap(errorx.KindNotFound, err, fmt.Sprintf("record [%d] to make up not found", record.MakeupForID))
+	}
+	if original.Student.ID != record.Student.ID {
+		return errorx.Validation(fmt.Sprintf("record [%d] belongs to another student", original.ID))
+	}
+	if original.Attendance != dao.AttendanceLeave && original.Attendance != dao.AttendanceAbsent {
+		return errorx.Validation(fmt.Sprintf("record [%d] is not a leave or absence", original.ID))
+	}
+	n, err := repo.CountMakeupRecords(ctx, original.ID, record.ID)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return errorx.Conflict(fmt.Sprintf("record [%d] already has a makeup record", original.ID))
+	}
+	return nil
+}
+
+// UpdateRecord 修改记录的学生、教师、时间、课时、出勤状态与备注。
+// 已激活的记录更换学生时，课时退回原学生并从新学生扣除；扣除的课时变化时按差额调整，均在同一事务中完成。
+func (rm *RecordManager) UpdateRecord(ctx context.Context, req *requestx.UpdateRecordRequest) (string, error) {
+	log := logger.FromContext(ctx).With(logger.UInt("record_id", req.RecordID))
+	log.Info("Updating record",
+		logger.UInt("student_id", req.StudentID),
+		logger.UInt("teacher_id", req.TeacherID),
+		logger.String("teaching_date", req.TeachingDate),
+		logger.String("start_time", req.StartTime),
+		logger.String("end_time", req.EndTime),
+	)
+
+	teachingDate, err := parseRecordSchedule(req.TeachingDate, req.StartTime, req.EndTime)
+	if err != nil {
+		return "", err
+	}
+
+	err = dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txStudentRepo := repository.NewStudentRepository(dao.NewStudentDao(tx))
+		txRecordRepo := repository.NewRecordRepository(dao.NewRecordDao(tx))
+		txTeacherRepo := repository.NewTeacherRepository(dao.NewTeacherDao(tx))
+
+		before, err := txRecordRepo.GetRecordByID(ctx, req.RecordID)
+		if err != nil {
+			return err
+		}
+		oldStudent, err := txStudentRepo.GetStudentByIdWithDeleted(ctx, before.Student.ID)
+		if err != nil {
+			return err
+		}
+		before.Student.Name = oldStudent.Name
+
+		after := before
+		after.TeachingDate = teachingDate
+		after.StartTime = req.StartTime
+		after.EndTime = req.EndTime
+		after.Remark = req.Remark
+		if req.Attendance != "" {
+			after.Attendance = req.Attendance
+		}
+		if req.MakeupForID != 0 {
+			after.MakeupForID = req.MakeupForID
+		}
+		if after.Attendance != dao.AttendanceMakeup {
+			after.MakeupForID = 0
+		}
+
+		newStudent := oldStudent
+		if req.StudentID != before.Student.ID {
+			newStudent, err = txStudentRepo.GetStudentByID(ctx, req.StudentID)
+			if err != nil {
+				return errorx.Wrap(errorx.KindNotFound, err, fmt.Sprintf("student [%d] not found", req.StudentID))
+			}
+			after.Student = entity.Student{ID: newStudent.ID, Name: newStudent.Name}
+			after.Teacher = entity.Teacher{ID: newStudent.Teacher.ID}
+		}
+		if req.TeacherID != 0 {
+			after.Teacher = entity.Teacher{ID: req.TeacherID}
+		}
+		if after.Teacher.ID != before.Teacher.ID {
+			teacher, err := txTeacherRepo.GetTeacherByID(ctx, after.Teacher.ID)
+			if err != nil {
+				return errorx.Wrap(errorx.KindNotFound, err, fmt.Sprintf("teacher [%d] not found", after.Teacher.ID))
+			}
+			after.Teacher.Name = teacher.Name
+		}
+		// 小组课学生的日期、时间与教师由课次决定，只能通过 session_manager:update_session 修改
+		if before.SessionID != 0 && (after.Teacher.ID != before.Teacher.ID || !after.TeachingDate.Equal(before.TeachingDate) ||
+			after.StartTime != before.StartTime || after.EndTime != before.EndTime) {
+			return errorx.Validation(fmt.Sprintf("record belongs to class session [%d]; change its date, time or teacher on the session", before.SessionID))
+		}
+
+		// 未指定课时时保留原课时，上课时间变化则按规则重新计算
+		if err := rm.hours.checkExplicit(ctx, after.StartTime, after.EndTime, req.Hours); err != nil {
+			return err
+		}
+		explicit := req.Hours
+		if explicit == nil && after.StartTime == before.StartTime && after.EndTime == before.EndTime {
+			explicit = &before.Hours
+		}
+		if after.Hours, err = rm.hours.Hours(after.StartTime, after.EndTime, explicit); err != nil {
+			return err
+		}
+		if err := requireChargeIncrease(ctx, before, after); err != nil {
+			return err
+		}
+
+		if err := checkMakeup(ctx, txRecordRepo, after); err != nil {
+			return err
+		}
+		if !req.AllowOverlap {
+			clash, err := findRecordOverlap(ctx, txRecordRepo, after)
+			if err != nil {
+				return err
+			}
+			if clash != nil {
+				return recordOverlapError(after, clash)
+			}
+		}
+		if err := txRecordRepo.UpdateRecord(ctx, after); err != nil {
+			return err
+		}
+		if before.Active {
+			if err := moveRecordHours(ctx, tx, txStudentRepo, oldStudent, chargedHours(before), newStudent, chargedHours(after)); err != nil {
+				return err
+			}
+		}
+		return writeAudit(ctx, tx, auditRecordUpdate, auditEntityRecord, req.RecordID, recordSnapshot(&before), recordSnapshot(&after))
+	})
+	if errors.Is(err, dao.ErrDuplicatedKey) {
+		return "", errorx.Wrap(errorx.KindConflict, err, "duplicate: record already exists")
+	}
+	if err != nil {
+		log.Error("failed to update record", logger.ErrorType(err))
+		return "", err
+	}
+	return "Record updated successfully", nil
+}
+
+// moveRecordHours 调整已激活记录修改后的课时：退回原学生 oldHours，再从新学生扣除 newHours；同一学生时只调整差额
+func moveRecordHours(ctx context.Context, tx *gorm.DB, repo repository.StudentRepository,
+	oldStudent *entity.Student, oldHours int, newStudent *entity.Student, newHours int) error {
+	if oldStudent.ID == newStudent.ID {
+		diff := oldHours - newHours
+		if diff == 0 {
+			return nil
+		}
+		if err := repo.UpdateStudentHoursByIDWithDeleted(ctx, oldStudent.ID, diff); err != nil {
+			return err
+		}
+		return writeHoursAudit(ctx, tx, oldStudent.ID, oldStudent.Hours, diff)
+	}
+
+	if oldHours != 0 {
+		if err := repo.UpdateStudentHoursByIDWithDeleted(ctx, oldStudent.ID, oldHours); err != nil {
+			return err
+		}
+		if err := writeHoursAudit(ctx, tx, oldStudent.ID, oldStudent.Hours, oldHours); err != nil {
+			return err
+		}
+	}
+	if newHours == 0 {
+		return nil
+	}
+	if err := repo.UpdateStudentHoursByID(ctx, newStudent.ID, -newHours); err != nil {
+		return err
+	}
+	return writeHoursAudit(ctx, tx, newStudent.ID, newStudent.Hours, -newHours)
+}
+
 func (rm RecordManager) GetRecordList(ctx context.Context, req *requestx.GetRecordListRequest) (responsex.GetRecordListResponse, error) {
 	records, total, pendingTotal, err := rm.repo.GetRecordList(ctx, req.StudentKey, req.TeacherKey,
-		req.StartDate, req.EndDate, req.Offset, req.Limit)
+		req.StartDate, req.EndDate, req.Attendance, req.Offset, req.Limit)
 	if err != nil {
-		logger.Error("failed to get record list", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "failed to get record list", logger.ErrorType(err))
 		return responsex.GetRecordListResponse{}, err
 	}
-	logger.Info("Fetched records",
+	logger.InfoContext(ctx, "Fetched records",
 		logger.Int64("total", total),
 		logger.Int("fetched_count", len(records)),
 	)
@@ -116,7 +351,12 @@ func (rm RecordManager) GetRecordList(ctx context.Context, req *requestx.GetReco
 			StartTime:    rec.StartTime,
 			EndTime:      rec.EndTime,
 			Active:       rec.Active,
+			Hours:        rec.Hours,
 			Remark:       rec.Remark,
+			ScheduleID:   rec.ScheduleID,
+			Attendance:   rec.Attendance,
+			MakeupForID:  rec.MakeupForID,
+			SessionID:    rec.SessionID,
 		}
 		if !rec.Student.DeletedAt.IsZero() {
 			result[i].StudentName = fmt.Sprintf("%s (已删除)", rec.Student.Name)
@@ -134,97 +374,310 @@ func (rm RecordManager) GetRecordList(ctx context.Context, req *requestx.GetReco
 }
 
 func (rm *RecordManager) ActivateRecord(ctx context.Context, req *requestx.ActivateRecordRequest) (string, error) {
-	logger.Info("Activating record", logger.UInt("record_id", req.RecordID))
-	db := dao.GetDB()
+	logger.InfoContext(ctx, "Activating record", logger.UInt("record_id", req.RecordID))
+	db := dao.GetDBFromContext(ctx)
 	err := db.Transaction(func(tx *gorm.DB) error {
 		return activateRecord(ctx, req.RecordID, tx)
 	})
 	if err != nil {
-		logger.Error("failed to activate record", logger.UInt("record_id", req.RecordID), logger.ErrorType(err))
-		return "", fmt.Errorf("fail: record activate %s", err.Error())
+		logger.ErrorContext(ctx, "failed to activate record", logger.UInt("record_id", req.RecordID), logger.ErrorType(err))
+		return "", fmt.Errorf("fail: record activate %w", err)
 	}
 	return "Record activated successfully", nil
 }
 
+// errRecordAlreadyActive 表示记录已激活，重复激活会重复扣课时
+var errRecordAlreadyActive = errorx.Conflict("record is already active")
+
+// BatchActivateRecords 在一个事务中激活指定的记录。已激活、不存在或学生已删除的记录跳过并在结果中说明，
+// 其余错误会回滚整个批次。
+func (rm *RecordManager) BatchActivateRecords(ctx context.Context, req *requestx.BatchActivateRecordsRequest) (responsex.BatchActivateRecordsResponse, error) {
+	logger.InfoContext(ctx, "Activating records", logger.Any("record_ids", req.RecordIDs))
+	resp := responsex.BatchActivateRecordsResponse{Results: make([]responsex.RecordBatchResultDTO, 0, len(req.RecordIDs))}
+	db := dao.GetDBFromContext(ctx)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		txStudentRepo := repository.NewStudentRepository(dao.NewStudentDao(tx))
+		txRecordRepo := repository.NewRecordRepository(dao.NewRecordDao(tx))
+		seen := make(map[uint]bool, len(req.RecordIDs))
+		for _, id := range req.RecordIDs {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			result, err := batchActivateOne(ctx, id, txRecordRepo, txStudentRepo, tx)
+			if err != nil {
+				return err
+			}
+			if result == responsex.RecordResultActivated {
+				resp.Activated++
+			}
+			resp.Results = append(resp.Results, responsex.RecordBatchResultDTO{RecordID: id, Result: result})
+		}
+		return nil
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to activate records", logger.ErrorType(err))
+		return responsex.BatchActivateRecordsResponse{}, fmt.Errorf("fail: batch record activate %w", err)
+	}
+	logger.InfoContext(ctx, "Records activated", logger.Int("activated", resp.Activated), logger.Int("requested", len(req.RecordIDs)))
+	return resp, nil
+}
+
+// batchActivateOne 激活一条记录并返回处理结果，只有无法归类的错误才返回 error
+func batchActivateOne(ctx context.Context, id uint, recordRepo repository.RecordRepository, studentRepo repository.StudentRepository, tx *gorm.DB) (string, error) {
+	record, err := recordRepo.GetRecordByID(ctx, id)
+	if errorx.KindOf(err) == errorx.KindNotFound {
+		return responsex.RecordResultNotFound, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if record.Active {
+		return responsex.RecordResultAlreadyActive, nil
+	}
+	// 单条激活允许为已删除学生补记，批量激活时跳过以免误扣
+	student, err := studentRepo.GetStudentByIdWithDeleted(ctx, record.Student.ID)
+	if err != nil {
+		return "", err
+	}
+	if !student.DeletedAt.IsZero() {
+		return responsex.RecordResultStudentDeleted, nil
+	}
+	if err := activateRecord(ctx, id, tx); err != nil {
+		return "", err
+	}
+	return responsex.RecordResultActivated, nil
+}
+
 func activateRecord(ctx context.Context, recordID uint, db *gorm.DB) error {
 	txStudentRepo := repository.NewStudentRepository(dao.NewStudentDao(db))
 	txRecordRepo := repository.NewRecordRepository(dao.NewRecordDao(db))
+	log := logger.FromContext(ctx).With(logger.UInt("record_id", recordID))
 
 	// find record
 	record, err := txRecordRepo.GetRecordByID(ctx, recordID)
 	if err != nil {
-		logger.Error("failed to get record by ID", logger.UInt("record_id", recordID), logger.ErrorType(err))
+		log.Error("failed to get record by ID", logger.ErrorType(err))
 		return err
 	}
+	if record.Active {
+		return errRecordAlreadyActive
+	}
 
 	// update student hours
+	log = log.With(logger.UInt("student_id", record.Student.ID))
 	student, err := txStudentRepo.GetStudentByIdWithDeleted(ctx, record.Student.ID)
 	if err != nil {
-		logger.Error("failed to get student by ID", logger.UInt("student_id", record.Student.ID), logger.ErrorType(err))
+		log.Error("failed to get student by ID", logger.ErrorType(err))
 		return err
 	}
 
-	logger.Debug("student info:", logger.String("name", student.Name), logger.UInt("id", student.ID), logger.Int("current_hours", student.Hours))
-	err = txStudentRepo.UpdateStudentHoursByIDWithDeleted(ctx, student.ID, -1)
+	charged := chargedHours(record)
+	log.Debug("student info:", logger.String("name", student.Name), logger.Int("current_hours", student.Hours),
+		logger.Int("record_hours", record.Hours), logger.String("attendance", record.Attendance), logger.Int("charged_hours", charged))
+	err = txStudentRepo.UpdateStudentHoursByIDWithDeleted(ctx, student.ID, -charged)
 	if err != nil {
-		logger.Error("failed to update student hours", logger.UInt("student_id", student.ID), logger.ErrorType(err))
+		log.Error("failed to update student hours", logger.ErrorType(err))
 		return err
 	}
 
 	// activate record
 	err = txRecordRepo.ActivateRecord(ctx, recordID)
 	if err != nil {
-		logger.Error("failed to activate record", logger.ErrorType(err))
+		log.Error("failed to activate record", logger.ErrorType(err))
 		return err
 	}
-	return nil
+
+	record.Student.Name = student.Name
+	after := record
+	after.Active = true
+	if err := writeAudit(ctx, db, auditRecordActivate, auditEntityRecord, recordID, recordSnapshot(&record), recordSnapshot(&after)); err != nil {
+		return err
+	}
+	if charged == 0 {
+		return nil
+	}
+	return writeHoursAudit(ctx, db, student.ID, student.Hours, -charged)
 }
 
-func (rm *RecordManager) ActivateAllPendingRecords(ctx context.Context) (string, error) {
-	logger.Info("Activating all pending records")
-	db := dao.GetDB()
+// errRecordNotActive 表示撤销激活的记录本来就未激活
+var errRecordNotActive = errorx.Conflict("record is not active")
+
+// DeactivateRecord 撤销误操作的激活：退回课时并将记录恢复为未激活，原因写入审计日志
+func (rm *RecordManager) DeactivateRecord(ctx context.Context, req *requestx.DeactivateRecordRequest) (string, error) {
+	logger.InfoContext(ctx, "Deactivating record", logger.UInt("record_id", req.RecordID), logger.String("reason", req.Reason))
+	db := dao.GetDBFromContext(ctx)
 	err := db.Transaction(func(tx *gorm.DB) error {
-		txRecordRepo := repository.NewRecordRepository(dao.NewRecordDao(tx))
-		// find all pending records
-		pendingRecords, err := txRecordRepo.GetAllPendingRecordList(ctx)
-		if err != nil {
-			logger.Error("failed to get pending records", logger.ErrorType(err))
-			return err
-		}
-		recordIDs := make([]uint, 0, len(pendingRecords))
-		for _, record := range pendingRecords {
-			if !record.Active {
-				recordIDs = append(recordIDs, record.ID)
+		return deactivateRecord(ctx, req.RecordID, req.Reason, tx)
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to deactivate record", logger.UInt("record_id", req.RecordID), logger.ErrorType(err))
+		return "", fmt.Errorf("fail: record deactivate %w", err)
+	}
+	return "Record deactivated successfully", nil
+}
+
+// BatchDeactivateRecords 在一个事务中撤销多条记录的激活，不存在或未激活的记录跳过并在结果中说明
+func (rm *RecordManager) BatchDeactivateRecords(ctx context.Context, req *requestx.BatchDeactivateRecordsRequest) (responsex.BatchDeactivateRecordsResponse, error) {
+	logger.InfoContext(ctx, "Deactivating records", logger.Any("record_ids", req.RecordIDs), logger.String("reason", req.Reason))
+	resp := responsex.BatchDeactivateRecordsResponse{Results: make([]responsex.RecordBatchResultDTO, 0, len(req.RecordIDs))}
+	db := dao.GetDBFromContext(ctx)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		seen := make(map[uint]bool, len(req.RecordIDs))
+		for _, id := range req.RecordIDs {
+			if seen[id] {
+				continue
 			}
+			seen[id] = true
+
+			result := responsex.RecordBatchResultDTO{RecordID: id, Result: responsex.RecordResultDeactivated}
+			err := deactivateRecord(ctx, id, req.Reason, tx)
+			switch {
+			case err == nil:
+				resp.Deactivated++
+			case errors.Is(err, errRecordNotActive):
+				result.Result = responsex.RecordResultAlreadyPending
+			case errorx.KindOf(err) == errorx.KindNotFound:
+				result.Result = responsex.RecordResultNotFound
+			default:
+				return err
+			}
+			resp.Results = append(resp.Results, result)
 		}
+		return nil
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to deactivate records", logger.ErrorType(err))
+		return responsex.BatchDeactivateRecordsResponse{}, fmt.Errorf("fail: batch record deactivate %w", err)
+	}
+	return resp, nil
+}
 
-		logger.Info("Found pending records", logger.Int("count", len(recordIDs)),
-			logger.String("record_ids", fmt.Sprintf("%v", recordIDs)))
+func deactivateRecord(ctx context.Context, recordID uint, reason string, db *gorm.DB) error {
+	txStudentRepo := repository.NewStudentRepository(dao.NewStudentDao(db))
+	txRecordRepo := repository.NewRecordRepository(dao.NewRecordDao(db))
+	log := logger.FromContext(ctx).With(logger.UInt("record_id", recordID))
 
-		for _, record := range pendingRecords {
-			if !record.Active {
-				recordIDs = append(recordIDs, record.ID)
-				err = activateRecord(ctx, record.ID, tx)
-				if err != nil {
-					logger.Error("failed to activate record", logger.UInt("record_id", record.ID), logger.ErrorType(err))
-					return err
-				}
-			}
-		}
+	record, err := txRecordRepo.GetRecordByID(ctx, recordID)
+	if err != nil {
+		log.Warn("failed to get record by ID", logger.ErrorType(err))
+		return err
+	}
+	if !record.Active {
+		return errRecordNotActive
+	}
 
+	// 学生可能已被删除，仍需退回课时以保持流水一致
+	student, err := txStudentRepo.GetStudentByIdWithDeleted(ctx, record.Student.ID)
+	if err != nil {
+		log.Error("failed to get student by ID", logger.UInt("student_id", record.Student.ID), logger.ErrorType(err))
+		return err
+	}
+	charged := chargedHours(record)
+	if err := txStudentRepo.UpdateStudentHoursByIDWithDeleted(ctx, student.ID, charged); err != nil {
+		log.Error("failed to return student hours", logger.ErrorType(err))
+		return err
+	}
+	if err := txRecordRepo.DeactivateRecord(ctx, recordID); err != nil {
+		log.Error("failed to deactivate record", logger.ErrorType(err))
+		return err
+	}
+
+	record.Student.Name = student.Name
+	after := record
+	after.Active = false
+	if err := writeAudit(ctx, db, auditRecordDeactivate, auditEntityRecord, recordID, recordSnapshot(&record),
+		auditDeactivation{RecordDTO: recordSnapshot(&after), Reason: reason}); err != nil {
+		return err
+	}
+	if charged == 0 {
 		return nil
+	}
+	return writeHoursAudit(ctx, db, student.ID, student.Hours, charged)
+}
+
+func (rm *RecordManager) ActivateAllPendingRecords(ctx context.Context) (string, error) {
+	return rm.activateAllPendingRecords(ctx, nil)
+}
+
+// ActivateAllPendingRecordsAsync runs ActivateAllPendingRecords as a background job.
+// Records are committed in chunks of activateAllChunkSize, so other calls are
+// not blocked for the whole run and cancelling keeps the chunks already
+// committed.
+func (rm *RecordManager) ActivateAllPendingRecordsAsync(ctx context.Context) (responsex.StartJobResponse, error) {
+	id := rm.jobs.Start(ctx, "record_manager:activate_all_pending_records", func(ctx context.Context, progress jobs.ProgressFunc) (any, error) {
+		return rm.activateAllPendingRecords(ctx, progress)
 	})
+	return responsex.StartJobResponse{JobID: id}, nil
+}
 
+// activateAllChunkSize 为激活全部待激活记录时每个事务激活的记录数。
+// 数据库只有一个连接，分批提交使界面的其他请求可以在批次之间执行
+const activateAllChunkSize = 100
+
+// activateAllPendingRecords 激活全部待激活记录，每 activateAllChunkSize 条提交一次。
+// 出错或取消时只回滚当前批次；批次之间已被激活或删除的记录跳过
+func (rm *RecordManager) activateAllPendingRecords(ctx context.Context, progress jobs.ProgressFunc) (string, error) {
+	logger.InfoContext(ctx, "Activating all pending records")
+	db := dao.GetDBFromContext(ctx)
+	// find all pending records
+	pendingRecords, err := repository.NewRecordRepository(dao.NewRecordDao(db)).GetAllPendingRecordList(ctx)
 	if err != nil {
-		logger.Error("failed to activate all pending records", logger.ErrorType(err))
-		return "", fmt.Errorf("fail: activate all pending records %s", err.Error())
+		logger.ErrorContext(ctx, "failed to get pending records", logger.ErrorType(err))
+		return "", fmt.Errorf("fail: activate all pending records %w", err)
+	}
+	recordIDs := make([]uint, 0, len(pendingRecords))
+	for _, record := range pendingRecords {
+		if !record.Active {
+			recordIDs = append(recordIDs, record.ID)
+		}
+	}
+
+	logger.InfoContext(ctx, "Found pending records", logger.Int("count", len(recordIDs)),
+		logger.Any("record_ids", recordIDs))
+
+	activated := 0
+	for start := 0; start < len(recordIDs); start += activateAllChunkSize {
+		chunk := recordIDs[start:min(start+activateAllChunkSize, len(recordIDs))]
+		n := 0
+		err := db.Transaction(func(tx *gorm.DB) error {
+			n = 0
+			for i, id := range chunk {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				err := activateRecord(ctx, id, tx)
+				switch {
+				case err == nil:
+					n++
+				case errors.Is(err, errRecordAlreadyActive), errorx.KindOf(err) == errorx.KindNotFound:
+					logger.WarnContext(ctx, "record changed since listed, skipped", logger.UInt("record_id", id), logger.ErrorType(err))
+				default:
+					logger.ErrorContext(ctx, "failed to activate record", logger.UInt("record_id", id), logger.ErrorType(err))
+					return err
+				}
+				progress.Report(start+i+1, len(recordIDs), "activating records")
+			}
+			return nil
+		})
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to activate all pending records", logger.Int("activated", activated), logger.ErrorType(err))
+			return "", fmt.Errorf("fail: activate all pending records, %d activated before the failure: %w", activated, err)
+		}
+		activated += n
 	}
+	logger.InfoContext(ctx, "All pending records activated", logger.Int("activated", activated))
 	return "All pending records activated successfully", nil
 }
 
 func (rm *RecordManager) DeleteRecordByID(ctx context.Context, req *requestx.DeleteRecordRequest) (string, error) {
-	logger.Info("Deleting record", logger.UInt("record_id", req.RecordID))
-	db := dao.GetDB()
+	log := logger.FromContext(ctx).With(logger.UInt("record_id", req.RecordID))
+	log.Info("Deleting record")
+	db := dao.GetDBFromContext(ctx)
 	err := db.Transaction(func(tx *gorm.DB) error {
 		txStuRepo := repository.NewStudentRepository(dao.NewStudentDao(tx))
 		txRecRepo := repository.NewRecordRepository(dao.NewRecordDao(tx))
@@ -233,65 +686,96 @@ func (rm *RecordManager) DeleteRecordByID(ctx context.Context, req *requestx.Del
 		// find record
 		record, err := txRecRepo.GetRecordByID(ctx, req.RecordID)
 		if err != nil {
-			logger.Error("failed to get record by ID", logger.UInt("record_id", req.RecordID), logger.ErrorType(err))
+			log.Error("failed to get record by ID", logger.ErrorType(err))
 			return err
 		}
-		logger.Info("record info:", logger.UInt("id", record.ID), logger.UInt("student_id", record.Student.ID),
-			logger.String("active", fmt.Sprintf("%v", record.Active)))
+		log.Info("record info:", logger.UInt("student_id", record.Student.ID), logger.Bool("active", record.Active))
 		// return hours to student
-		if record.Active {
-			err = txStuRepo.UpdateStudentHoursByIDWithDeleted(ctx, record.Student.ID, 1)
+		if charged := chargedHours(record); record.Active && charged != 0 {
+			student, err := txStuRepo.GetStudentByIdWithDeleted(ctx, record.Student.ID)
+			if err != nil {
+				log.Error("failed to get student by ID", logger.UInt("student_id", record.Student.ID), logger.ErrorType(err))
+				return err
+			}
+			err = txStuRepo.UpdateStudentHoursByIDWithDeleted(ctx, record.Student.ID, charged)
 			if err != nil {
-				logger.Error("failed to return hours to student before deletion", logger.UInt("student_id", record.Student.ID), logger.ErrorType(err))
-				return fmt.Errorf("fail: return hours to student before deletion failed: %s", err.Error())
+				log.Error("failed to return hours to student before deletion", logger.UInt("student_id", record.Student.ID), logger.ErrorType(err))
+				return fmt.Errorf("fail: return hours to student before deletion failed: %w", err)
+			}
+			if err := writeHoursAudit(ctx, tx, student.ID, student.Hours, charged); err != nil {
+				return err
 			}
 		}
 
 		// delete record
 		err = txRecRepo.DeleteRecordByID(ctx, req.RecordID)
 		if err != nil {
-			logger.Error("failed to activate record before deletion", logger.UInt("record_id", req.RecordID), logger.ErrorType(err))
+			log.Error("failed to activate record before deletion", logger.ErrorType(err))
 			return err
 		}
-		return nil
+		return writeAudit(ctx, tx, auditRecordDelete, auditEntityRecord, req.RecordID, recordSnapshot(&record), nil)
 	})
 
 	if err != nil {
-		logger.Error("failed to delete record", logger.UInt("record_id", req.RecordID), logger.ErrorType(err))
-		return "", fmt.Errorf("fail: delete record failed: %s", err.Error())
+		log.Error("failed to delete record", logger.ErrorType(err))
+		return "", fmt.Errorf("fail: delete record failed: %w", err)
 	}
 
 	return "Record deleted successfully", nil
 }
 
 func (rm *RecordManager) ExportRecordToExcel(ctx context.Context, req *requestx.ExportRecordsRequest) (string, error) {
-	logger.Info("start export records to excel", logger.String("student_key", req.StudentKey),
+	logger.InfoContext(ctx, "start export records to excel", logger.String("student_key", req.StudentKey),
 		logger.String("teacher_key", req.TeacherKey),
 		logger.String("start_date", req.StartDate),
 		logger.String("end_date", req.EndDate),
 	)
 
-	filepath, err := wails.SaveFileDialog(rm.Ctx, wails.SaveDialogOptions{
-		Title:           "选择导出文件位置",
-		DefaultFilename: fmt.Sprintf("teaching_records_%s.xlsx", time.Now().Format("20060102_150405")),
-		Filters:         []wails.FileFilter{{DisplayName: "Excel 文件", Pattern: "*.xlsx"}},
-	})
-
+	filepath, err := rm.selectRecordExportFile()
 	if err != nil {
 		return "", err
 	}
 	if filepath == "" {
 		return "cancel", nil
 	}
-	records, _, _, err := rm.repo.GetRecordList(ctx, req.StudentKey, req.TeacherKey, req.StartDate, req.EndDate, 0, -1)
+	return rm.exportRecordToExcel(ctx, req, filepath)
+}
+
+// ExportRecordToExcelAsync asks for the target file, then writes it in a
+// background job. JobID is "cancel" when the dialog was dismissed.
+func (rm *RecordManager) ExportRecordToExcelAsync(ctx context.Context, req *requestx.ExportRecordsRequest) (responsex.StartJobResponse, error) {
+	filepath, err := rm.selectRecordExportFile()
 	if err != nil {
-		logger.Error("failed to get record list for export", logger.ErrorType(err))
+		return responsex.StartJobResponse{}, err
+	}
+	if filepath == "" {
+		return responsex.StartJobResponse{JobID: "cancel"}, nil
+	}
+
+	id := rm.jobs.Start(ctx, "record_manager:export_record_to_excel", func(ctx context.Context, progress jobs.ProgressFunc) (any, error) {
+		return rm.exportRecordToExcel(ctx, req, filepath)
+	})
+	return responsex.StartJobResponse{JobID: id}, nil
+}
+
+func (rm *RecordManager) selectRecordExportFile() (string, error) {
+	return wails.SaveFileDialog(rm.Ctx, wails.SaveDialogOptions{
+		Title:           "选择导出文件位置",
+		DefaultFilename: fmt.Sprintf("teaching_records_%s.xlsx", time.Now().Format("20060102_150405")),
+		Filters:         []wails.FileFilter{{DisplayName: "Excel 文件", Pattern: "*.xlsx"}},
+	})
+}
+
+func (rm *RecordManager) exportRecordToExcel(ctx context.Context, req *requestx.ExportRecordsRequest, filepath string) (string, error) {
+	records, _, _, err := rm.repo.GetRecordList(ctx, req.StudentKey, req.TeacherKey, req.StartDate, req.EndDate, req.Attendance, 0, -1)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get record list for export", logger.ErrorType(err))
 		return "", fmt.Errorf("fail: to get record list: %v", err)
 	}
 
 	err = exportRecordsToExcelFile(ctx, records, filepath)
 	if err != nil {
-		logger.Error("failed to export records to excel", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "failed to export records to excel", logger.ErrorType(err))
 		return "", fmt.Errorf("导出失败:请检查文件是否被占用或有读写权限")
 	}
 	return "Records exported successfully", nil
@@ -299,12 +783,13 @@ func (rm *RecordManager) ExportRecordToExcel(ctx context.Context, req *requestx.
 
 func exportRecordsToExcelFile(ctx context.Context, records []entity.Record, path string) error {
 
-	headers := []string{"学生姓名", "教师姓名", "上课日期", "上课时间", "状态", "备注	"}
-	rows := make([][]string, 0, len(records))
+	headers := []string{"学生姓名", "教师姓名", "上课日期", "上课时间", "状态", "出勤", "课时", "备注"}
+	rows := make([][]string, 0, len(records)+1)
 	statusToString := map[bool]string{
 		true:  "已激活",
 		false: "未激活",
 	}
+	consumed := 0
 	for _, r := range records {
 		rows = append(rows, []string{
 			r.Student.Name,
@@ -312,15 +797,36 @@ func exportRecordsToExcelFile(ctx context.Context, records []entity.Record, path
 			r.TeachingDate.Format("2006-01-02"),
 			fmt.Sprintf("%s - %s", r.StartTime, r.EndTime),
 			statusToString[r.Active],
+			attendanceLabel(r),
+			strconv.Itoa(r.Hours),
 			r.Remark,
 		})
+		if r.Active {
+			consumed += chargedHours(r)
+		}
 	}
+	// 合计只统计已激活记录实际扣除的课时，请假不扣课时
+	rows = append(rows, []string{"合计", "", "", "", "已激活消耗", "", strconv.Itoa(consumed), ""})
 
 	return pkg.ExportToExcel(path, headers, rows)
 }
 
+// attendanceLabel 返回导出时显示的出勤状态，补课注明所补的记录
+func attendanceLabel(r entity.Record) string {
+	switch r.Attendance {
+	case dao.AttendanceAbsent:
+		return "缺课"
+	case dao.AttendanceLeave:
+		return "请假"
+	case dao.AttendanceMakeup:
+		return fmt.Sprintf("补课（记录 %d）", r.MakeupForID)
+	default:
+		return "出勤"
+	}
+}
+
 func (rm *RecordManager) DownloadImportTemplate(ctx context.Context) (string, error) {
-	logger.Info("start download record import template")
+	logger.InfoContext(ctx, "start download record import template")
 	filepath, err := wails.SaveFileDialog(rm.Ctx, wails.SaveDialogOptions{
 		Title:           "选择导出模板文件位置",
 		DefaultFilename: "record_import_template.xlsx",
@@ -334,21 +840,21 @@ func (rm *RecordManager) DownloadImportTemplate(ctx context.Context) (string, er
 		return "cancel", nil
 	}
 
-	logger.Info("exporting record import template to", logger.String("filepath", filepath))
+	logger.InfoContext(ctx, "exporting record import template to", logger.String("filepath", filepath))
 	rows := [][]string{
 		{"张三", "2024-10-01", "10:00", "11:00", "第一次上课"},
 	}
 
 	err = pkg.ExportToExcel(filepath, template_excel_headers, rows)
 	if err != nil {
-		logger.Error("failed to export record import template", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "failed to export record import template", logger.ErrorType(err))
 		return "", fmt.Errorf("导出失败:请检查文件是否被占用或有读写权限")
 	}
 	return filepath, nil
 }
 
 func (rm *RecordManager) ShowFilePicker(ctx context.Context) (responsex.SelectFileResponse, error) {
-	logger.Info("start open file dialog")
+	logger.InfoContext(ctx, "start open file dialog")
 	filepath, err := wails.OpenFileDialog(rm.Ctx, wails.OpenDialogOptions{
 		Title:   "选择导入文件位置",
 		Filters: []wails.FileFilter{{DisplayName: "Excel 文件", Pattern: "*.xlsx"}},
@@ -364,20 +870,50 @@ func (rm *RecordManager) ShowFilePicker(ctx context.Context) (responsex.SelectFi
 }
 
 func (rm *RecordManager) ImportFromExcel(ctx context.Context, req *requestx.ImportRecordsRequest) (responsex.ImportFromExcelResponse, error) {
-	logger.Info("start import records from excel", logger.String("filepath", req.Filepath))
+	return rm.importFromExcel(ctx, req, nil)
+}
+
+// ImportFromExcelAsync runs ImportFromExcel as a background job. Cancelling
+// the job rolls back every row imported so far.
+//
+// The rows are written in one transaction so that an import is all or
+// nothing. The database has a single connection, so other calls wait while
+// the rows are written; only opening and validating the workbook run outside
+// the transaction.
+func (rm *RecordManager) ImportFromExcelAsync(ctx context.Context, req *requestx.ImportRecordsRequest) (responsex.StartJobResponse, error) {
+	id := rm.jobs.Start(ctx, "record_manager:import_from_excel", func(ctx context.Context, progress jobs.ProgressFunc) (any, error) {
+		return rm.importFromExcel(ctx, req, progress)
+	})
+	return responsex.StartJobResponse{JobID: id}, nil
+}
+
+// errImportDryRun 用于在试导入结束后回滚事务
+var errImportDryRun = errors.New("import dry run")
+
+func (rm *RecordManager) importFromExcel(ctx context.Context, req *requestx.ImportRecordsRequest, progress jobs.ProgressFunc) (responsex.ImportFromExcelResponse, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = requestx.ImportModeAll
+	}
+	logger.InfoContext(ctx, "start import records from excel", logger.String("filepath", req.Filepath), logger.String("mode", mode))
 	importFilePath := req.Filepath
 
 	f, err := excelize.OpenFile(importFilePath)
 	if err != nil {
-		logger.Error("failed to open excel file", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "failed to open excel file", logger.ErrorType(err))
 		return responsex.ImportFromExcelResponse{}, fmt.Errorf("fail:open excel file failed: %w", err)
 	}
 	defer f.Close()
 
 	// Validate dates in excel
-	records, errInfo, err := validateTeachingRecords(ctx, f)
+	records, errInfo, rows, err := validateTeachingRecords(ctx, f, req.Sheet, req.AllowOverlap)
 	if err != nil {
-		logger.Error("failed to validate excel data", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "failed to validate excel data", logger.ErrorType(err))
+		return responsex.ImportFromExcelResponse{}, err
+	}
+	// 在开启事务前检查与已有记录的重复和重叠，整体导入时也能一次列出所有冲突的行
+	if err := checkImportExistingRecords(ctx, records, errInfo, req.AllowOverlap); err != nil {
+		logger.ErrorContext(ctx, "failed to check existing records for import", logger.ErrorType(err))
 		return responsex.ImportFromExcelResponse{}, err
 	}
 
@@ -390,111 +926,296 @@ func (rm *RecordManager) ImportFromExcel(ctx context.Context, req *requestx.Impo
 		}
 	}
 
-	if hasErrors {
-		logger.Error("excel data validation failed")
+	if hasErrors && mode == requestx.ImportModeAll {
+		logger.ErrorContext(ctx, "excel data validation failed")
 		return responsex.ImportFromExcelResponse{
 			Filepath:   importFilePath,
+			Mode:       mode,
 			ErrorInfos: errInfo,
 			TotalRows:  0,
-		}, fmt.Errorf("数据验证失败，请检查错误信息")
+		}, errorx.Validation("数据验证失败，请检查错误信息")
 	}
 
-	db := dao.GetDB()
+	resp := responsex.ImportFromExcelResponse{
+		Filepath:   importFilePath,
+		Mode:       mode,
+		TotalRows:  len(records),
+		ErrorInfos: errInfo,
+		Rows:       make([]responsex.ImportRowDTO, 0, len(records)),
+	}
+	// 整个导入在一个事务中写入，期间其他请求需等待唯一的数据库连接；读取与校验工作簿已在事务外完成
+	db := dao.GetDBFromContext(ctx)
 	err = db.Transaction(func(tx *gorm.DB) error {
-		txStudentRepo := repository.NewStudentRepository(dao.NewStudentDao(tx))
-		txRecordRepo := repository.NewRecordRepository(dao.NewRecordDao(tx))
-
 		for i, record := range records {
-			// Find Student
-			student, err := txStudentRepo.GetStudentByName(ctx, record.Student.Name)
-			if err != nil {
-				logger.Error("failed to get student by name", logger.String("student_name", record.Student.Name), logger.ErrorType(err))
-				return fmt.Errorf("第 %d 行: 查询学生 '%s' 失败", i+2, record.Student.Name)
+			if err := ctx.Err(); err != nil {
+				return err
 			}
-
-			// Check Teacher
-			if student.Teacher.ID == 0 || !student.Teacher.DeletedAt.IsZero() {
-				logger.Error("associated teacher not found for student", logger.String("student_name", record.Student.Name),
-					logger.UInt("teacher_id", student.Teacher.ID), logger.String("teacher_deleted_at", student.Teacher.DeletedAt.Local().String()))
-				return fmt.Errorf("第 %d 行: 学生 '%s' 没有关联有效的教师", i+2, record.Student.Name)
+			progress.Report(i, len(records), "importing records")
+
+			row := responsex.ImportRowDTO{Row: i + 2, StudentName: record.Student.Name}
+			if len(errInfo[i]) == 0 {
+				if mode == requestx.ImportModeAll {
+					if err := rm.importRecord(ctx, tx, record, i+2, req.AllowOverlap); err != nil {
+						return err
+					}
+				} else {
+					// 每一行在单独的保存点中导入，出错时只回滚该行
+					err := tx.Transaction(func(rowTx *gorm.DB) error {
+						return rm.importRecord(ctx, rowTx, record, i+2, req.AllowOverlap)
+					})
+					if err != nil {
+						if !isImportRowError(err) {
+							return err
+						}
+						errInfo[i] = append(errInfo[i], err.Error())
+					}
+				}
 			}
 
-			// Complete the record information
-			record.Student.ID = student.ID
-			record.Teacher.ID = student.Teacher.ID
-			record.Active = false // Imported records are pending by default
+			if len(errInfo[i]) > 0 {
+				row.Status = responsex.ImportRowFailed
+				row.Errors = errInfo[i]
+				resp.Failed++
+			} else {
+				row.Status = responsex.ImportRowImported
+				if mode == requestx.ImportModeDryRun {
+					row.Status = responsex.ImportRowOK
+				}
+				resp.Passed++
+			}
+			resp.Rows = append(resp.Rows, row)
+		}
 
-			// Create Record
-			err = txRecordRepo.CreateRecord(ctx, &record)
+		if mode == requestx.ImportModeDryRun {
+			return errImportDryRun
+		}
+		if mode == requestx.ImportModeSkipErrors && resp.Failed > 0 {
+			// 错误工作簿写入失败时整个导入回滚，以免出错的行无从查找
+			path, err := writeImportErrorWorkbook(importFilePath, rows, errInfo)
 			if err != nil {
-				if errors.Is(err, dao.ErrDuplicatedKey) {
-					logger.Warn("duplicate record found during import", logger.String("student_name", record.Student.Name), logger.ErrorType(err))
-					return fmt.Errorf("第 %d 行: 学生 '%s' 的该上课记录已存在，重复导入", i+2, record.Student.Name)
-				}
-				logger.Error("failed to create record", logger.String("student_name", record.Student.Name), logger.ErrorType(err))
-				return fmt.Errorf("第 %d 行: 创建记录失败: %w", i+2, err)
+				logger.ErrorContext(ctx, "failed to write import error workbook", logger.ErrorType(err))
+				return fmt.Errorf("写入错误工作簿失败: %w", err)
 			}
+			resp.ErrorFilepath = path
 		}
 		return nil
 	})
+	if errors.Is(err, errImportDryRun) {
+		err = nil
+	}
 
 	if err != nil {
-		logger.Error("failed to import records", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "failed to import records", logger.ErrorType(err))
 		return responsex.ImportFromExcelResponse{
 			Filepath:  importFilePath,
+			Mode:      mode,
 			TotalRows: len(records),
 		}, err
 	}
 
-	return responsex.ImportFromExcelResponse{
-		Filepath:   importFilePath,
-		TotalRows:  len(records),
-		ErrorInfos: [][]string{},
-	}, nil
+	progress.Report(len(records), len(records), "import finished")
+	logger.InfoContext(ctx, "records import finished", logger.String("mode", mode),
+		logger.Int("passed", resp.Passed), logger.Int("failed", resp.Failed))
+	return resp, nil
+}
+
+// isImportRowError 判断导入某一行的错误是否只与该行数据有关（学生不存在、重复、时间重叠等），
+// 这类错误在跳过出错行与试导入时记入该行，其他错误仍中止整个导入
+func isImportRowError(err error) bool {
+	switch errorx.KindOf(err) {
+	case errorx.KindValidation, errorx.KindNotFound, errorx.KindConflict:
+		return true
+	default:
+		return false
+	}
 }
 
-func validateTeachingRecords(ctx context.Context, f *excelize.File) ([]entity.Record, [][]string, error) {
-	rows, err := f.GetRows("Sheet1")
+// importRecord 补全导入的一行记录的学生、教师与课时后写入，line 为该行在 Excel 中的行号
+func (rm *RecordManager) importRecord(ctx context.Context, tx *gorm.DB, record entity.Record, line int, allowOverlap bool) error {
+	txStudentRepo := repository.NewStudentRepository(dao.NewStudentDao(tx))
+	txRecordRepo := repository.NewRecordRepository(dao.NewRecordDao(tx))
+
+	// Find Student
+	student, err := txStudentRepo.GetStudentByName(ctx, record.Student.Name)
 	if err != nil {
-		logger.Error("failed to read rows from excel file", logger.ErrorType(err))
-		return nil, nil, fmt.Errorf("无法打开Sheet1")
+		logger.ErrorContext(ctx, "failed to get student by name", logger.String("student_name", record.Student.Name), logger.ErrorType(err))
+		return errorx.Wrap(errorx.KindOf(err), err, fmt.Sprintf("第 %d 行: 查询学生 '%s' 失败", line, record.Student.Name))
 	}
 
-	if len(rows) < 2 {
-		logger.Warn("Excel not contain effective data")
-		return nil, nil, fmt.Errorf("Excel 不包含有效数据")
+	// Check Teacher
+	if student.Teacher.ID == 0 || !student.Teacher.DeletedAt.IsZero() {
+		logger.ErrorContext(ctx, "associated teacher not found for student", logger.String("student_name", record.Student.Name),
+			logger.UInt("teacher_id", student.Teacher.ID), logger.String("teacher_deleted_at", student.Teacher.DeletedAt.Local().String()))
+		return errorx.Validation(fmt.Sprintf("第 %d 行: 学生 '%s' 没有关联有效的教师", line, record.Student.Name))
 	}
 
-	errInfo := make([][]string, len(rows)-1)
+	// Complete the record information
+	record.Student.ID = student.ID
+	record.Teacher.ID = student.Teacher.ID
+	record.Active = false // Imported records are pending by default
+	record.Attendance = dao.AttendanceAttended
+	record.Hours, err = rm.hours.Hours(record.StartTime, record.EndTime, nil)
+	if err != nil {
+		return errorx.Wrap(errorx.KindOf(err), err, fmt.Sprintf("第 %d 行: 计算课时失败", line))
+	}
 
-	// validate table header
-	for i, header := range template_excel_headers {
-		if rows[0][i] != header {
-			logger.Error("table header not match template",
-				logger.String("input_header", rows[0][i]), logger.String("template_header", header))
-			return nil, nil, fmt.Errorf("无效的模板格式, 预期表头包含 %s 但是存在 %s", header, rows[0][i])
+	// Check overlap with existing records and rows imported before
+	if !allowOverlap {
+		clash, err := findRecordOverlap(ctx, txRecordRepo, record)
+		if err != nil {
+			return err
+		}
+		if clash != nil {
+			logger.WarnContext(ctx, "overlapping record found during import", logger.String("student_name", record.Student.Name),
+				logger.UInt("clash_record_id", clash.ID))
+			return errorx.Conflict(importOverlapMessage(line, record, clash))
 		}
 	}
 
-	// validate data rows
-	records := make([]entity.Record, 0, len(rows)-1)
-	for i, row := range rows[1:] {
-		if len(row) < 4 {
-			errInfo[i] = append(errInfo[i], fmt.Sprintf("第 %d 行: 列数不足4列，信息不足", i+2))
+	// Create Record
+	err = txRecordRepo.CreateRecord(ctx, &record)
+	if err != nil {
+		if errors.Is(err, dao.ErrDuplicatedKey) {
+			logger.WarnContext(ctx, "duplicate record found during import", logger.String("student_name", record.Student.Name), logger.ErrorType(err))
+			return errorx.Wrap(errorx.KindConflict, err, fmt.Sprintf("第 %d 行: 学生 '%s' 的该上课记录已存在，重复导入", line, record.Student.Name))
+		}
+		logger.ErrorContext(ctx, "failed to create record", logger.String("student_name", record.Student.Name), logger.ErrorType(err))
+		return fmt.Errorf("第 %d 行: 创建记录失败: %w", line, err)
+	}
+	record.Teacher.Name = student.Teacher.Name
+	return writeAudit(ctx, tx, auditRecordImport, auditEntityRecord, record.ID, nil, recordSnapshot(&record))
+}
+
+func importOverlapMessage(line int, record entity.Record, clash *entity.Record) string {
+	return fmt.Sprintf("第 %d 行: 学生 '%s' 的上课时间与记录 [%d]（%s %s-%s，学生 %s，教师 %s）重叠",
+		line, record.Student.Name, clash.ID, clash.TeachingDate.Format("2006-01-02"), clash.StartTime, clash.EndTime,
+		clash.Student.Name, clash.Teacher.Name)
+}
+
+// checkImportExistingRecords 将通过格式校验的行与数据库中已有的记录比对，重复或时间重叠的行记入 errInfo。
+// 学生不存在或没有有效教师的行留到导入时报告
+func checkImportExistingRecords(ctx context.Context, records []entity.Record, errInfo [][]string, allowOverlap bool) error {
+	db := dao.GetDBFromContext(ctx)
+	studentRepo := repository.NewStudentRepository(dao.NewStudentDao(db))
+	recordRepo := repository.NewRecordRepository(dao.NewRecordDao(db))
+
+	students := make(map[string]*entity.Student)
+	for i, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(errInfo[i]) > 0 {
 			continue
 		}
+		student, ok := students[record.Student.Name]
+		if !ok {
+			s, err := studentRepo.GetStudentByName(ctx, record.Student.Name)
+			if err != nil && errorx.KindOf(err) != errorx.KindNotFound {
+				return err
+			}
+			student, students[record.Student.Name] = s, s
+		}
+		if student == nil || student.Teacher.ID == 0 || !student.Teacher.DeletedAt.IsZero() {
+			continue
+		}
+		record.Student.ID = student.ID
+		record.Teacher.ID = student.Teacher.ID
 
-		// remove blank spaces
-		stuName := strings.ReplaceAll(row[0], " ", "")
-		teachingDate := strings.ReplaceAll(row[1], " ", "")
-		startTime := strings.ReplaceAll(row[2], " ", "")
-		endTime := strings.ReplaceAll(row[3], " ", "")
-		remark := ""
-		if len(row) > 4 {
-			remark = strings.TrimSpace(row[4])
+		others, err := recordRepo.GetRecordsOfDate(ctx, record.TeachingDate, student.ID, student.Teacher.ID)
+		if err != nil {
+			return err
+		}
+		duplicated := false
+		for _, other := range others {
+			if other.Student.ID == student.ID && other.Teacher.ID == student.Teacher.ID &&
+				other.StartTime == record.StartTime && other.EndTime == record.EndTime {
+				errInfo[i] = append(errInfo[i], fmt.Sprintf("第 %d 行: 学生 '%s' 的该上课记录已存在（记录 [%d]），重复导入",
+					i+2, record.Student.Name, other.ID))
+				duplicated = true
+				break
+			}
+		}
+		if duplicated || allowOverlap {
+			continue
+		}
+		clash, err := findRecordOverlap(ctx, recordRepo, record)
+		if err != nil {
+			return err
+		}
+		if clash != nil {
+			errInfo[i] = append(errInfo[i], importOverlapMessage(i+2, record, clash))
+		}
+	}
+	return nil
+}
+
+// writeImportErrorWorkbook 在导入文件旁写入错误工作簿：原表头与出错行的原始内容，最后一列为错误信息，返回文件路径。
+// rows 的第一行为表头，rows[i+1] 对应 errInfo[i]
+func writeImportErrorWorkbook(importFilePath string, rows [][]string, errInfo [][]string) (string, error) {
+	headers := append(append([]string{}, rows[0]...), "错误信息")
+	errorRows := make([][]string, 0)
+	for i, rowErr := range errInfo {
+		if len(rowErr) == 0 {
+			continue
 		}
+		cells := make([]string, len(rows[0]), len(headers))
+		copy(cells, rows[i+1])
+		errorRows = append(errorRows, append(cells, strings.Join(rowErr, "；")))
+	}
+
+	path := fmt.Sprintf("%s_errors_%s.xlsx", strings.TrimSuffix(importFilePath, ".xlsx"), time.Now().Format("20060102_150405"))
+	if err := pkg.ExportToExcel(path, headers, errorRows); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// validateTeachingRecords 校验导入文件的格式以及文件内各行之间的重复与时间重叠，返回每个数据行对应的记录、错误信息，
+// 以及包含表头的原始内容。sheet 为空时自动选择表头符合要求的工作表，各列按表头名称对应，顺序不限
+func validateTeachingRecords(ctx context.Context, f *excelize.File, sheet string, allowOverlap bool) ([]entity.Record, [][]string, [][]string, error) {
+	sheet, err := selectImportSheet(f, sheet)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to select sheet from excel file", logger.ErrorType(err))
+		return nil, nil, nil, err
+	}
+	logger.InfoContext(ctx, "importing records from sheet", logger.String("sheet", sheet))
 
-		// validate date format
+	// 日期与时间单元格按原始数值读取，统一由 parseImportDate 与 parseImportTime 解析；
+	// 格式化后的内容用于错误工作簿，保持与用户看到的一致
+	rawRows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to read rows from excel file", logger.ErrorType(err))
+		return nil, nil, nil, fmt.Errorf("无法读取工作表 %s", sheet)
+	}
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to read rows from excel file", logger.ErrorType(err))
+		return nil, nil, nil, fmt.Errorf("无法读取工作表 %s", sheet)
+	}
+
+	if len(rawRows) < 2 {
+		logger.WarnContext(ctx, "Excel not contain effective data")
+		return nil, nil, nil, fmt.Errorf("Excel 不包含有效数据")
+	}
+	for len(rows) < len(rawRows) {
+		rows = append(rows, nil)
+	}
+
+	// map table header
+	columns, err := mapImportColumns(rawRows[0])
+	if err != nil {
+		logger.ErrorContext(ctx, "table header not match template", logger.Any("input_header", rawRows[0]), logger.ErrorType(err))
+		return nil, nil, nil, err
+	}
+
+	// validate data rows
+	errInfo := make([][]string, len(rawRows)-1)
+	records := make([]entity.Record, 0, len(rawRows)-1)
+	for i, row := range rawRows[1:] {
+		// remove blank spaces
+		stuName := strings.ReplaceAll(importCell(row, columns[importColumnStudent]), " ", "")
+		teachingDate := importCell(row, columns[importColumnDate])
+		remark := strings.TrimSpace(importCell(row, columns[importColumnRemark]))
 
 		// student name not empty
 		if stuName == "" {
@@ -502,46 +1223,34 @@ func validateTeachingRecords(ctx context.Context, f *excelize.File) ([]entity.Re
 		}
 
 		// teaching date format
-		logger.Debug("the teaching date is ", logger.String("date", teachingDate))
-		teachingDate = strings.ReplaceAll(teachingDate, "－", "-")
-		teachingDate = strings.ReplaceAll(teachingDate, "／", "-")
-		teachingDate = strings.ReplaceAll(teachingDate, "/", "-")
-		teachingDate = strings.ReplaceAll(teachingDate, ".", "-")
-
-		parsedTeachingDate, err := time.Parse("2006-01-02", teachingDate)
-		if err != nil {
-			// 尝试解析 MM-DD-YYYY 格式 (例如 12-01-2025)
-			parsedTeachingDate, err = time.Parse("01-02-2006", teachingDate)
-		}
-
+		logger.DebugContext(ctx, "the teaching date is ", logger.String("date", teachingDate))
+		parsedTeachingDate, err := parseImportDate(teachingDate, importCellNumeric(f, sheet, i+2, columns[importColumnDate]))
 		if err != nil {
-			logger.Error("teaching date parse error", logger.String("teaching_date", teachingDate), logger.ErrorType(err))
-			errInfo[i] = append(errInfo[i], fmt.Sprintf("第 %d 行: 上课日期格式错误，需为 YYYY-MM-DD 或 MM-DD-YYYY 格式", i+2))
+			logger.ErrorContext(ctx, "teaching date parse error", logger.String("teaching_date", teachingDate), logger.ErrorType(err))
+			errInfo[i] = append(errInfo[i], fmt.Sprintf("第 %d 行: 上课日期格式错误，需为日期单元格或 YYYY-MM-DD、MM-DD-YYYY、YYYY年MM月DD日 格式", i+2))
 		}
 
 		// start time format
-		startTime = strings.ReplaceAll(startTime, "：", ":")
-		startTimeParsed, err := time.Parse("15:04", startTime)
+		startTime, err := parseImportTime(importCell(row, columns[importColumnStart]), importCellNumeric(f, sheet, i+2, columns[importColumnStart]))
 		if err != nil {
-			errInfo[i] = append(errInfo[i], fmt.Sprintf("第 %d 行: 开始时间格式错误，需为 HH:MM 格式", i+2))
+			errInfo[i] = append(errInfo[i], fmt.Sprintf("第 %d 行: 开始时间格式错误，需为时间单元格或 HH:MM 格式", i+2))
 		}
 		startTimeValid := err == nil
 
 		// end time format
-		endTime = strings.ReplaceAll(endTime, "：", ":")
-		endTimeParsed, err := time.Parse("15:04", endTime)
+		endTime, err := parseImportTime(importCell(row, columns[importColumnEnd]), importCellNumeric(f, sheet, i+2, columns[importColumnEnd]))
 		if err != nil {
-			errInfo[i] = append(errInfo[i], fmt.Sprintf("第 %d 行: 结束时间格式错误，需为 HH:MM 格式", i+2))
+			errInfo[i] = append(errInfo[i], fmt.Sprintf("第 %d 行: 结束时间格式错误，需为时间单元格或 HH:MM 格式", i+2))
 		}
 		endTimeValid := err == nil
 
-		// validate start time before end time
-		if startTimeValid && endTimeValid && !startTimeParsed.Before(endTimeParsed) {
+		// 开始时间必须早于结束时间，HH:MM 格式可以直接按字符串比较
+		if startTimeValid && endTimeValid && startTime >= endTime {
 			errInfo[i] = append(errInfo[i], fmt.Sprintf("第 %d 行: 开始时间必须早于结束时间", i+2))
 		}
 
 		if len(errInfo[i]) > 0 {
-			records = append(records, entity.Record{})
+			records = append(records, entity.Record{Student: entity.Student{Name: stuName}})
 			continue
 		}
 
@@ -554,18 +1263,78 @@ func validateTeachingRecords(ctx context.Context, f *excelize.File) ([]entity.Re
 		})
 	}
 
-	return records, errInfo, nil
+	checkImportRowConflicts(records, errInfo, allowOverlap)
+	return records, errInfo, rows, nil
+}
+
+// GetImportSheets 返回导入文件中的工作表，以及未指定工作表时会自动选择的工作表（没有符合要求的工作表时为空）
+func (rm *RecordManager) GetImportSheets(ctx context.Context, req *requestx.GetImportSheetsRequest) (responsex.GetImportSheetsResponse, error) {
+	f, err := excelize.OpenFile(req.Filepath)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to open excel file", logger.ErrorType(err))
+		return responsex.GetImportSheetsResponse{}, fmt.Errorf("fail:open excel file failed: %w", err)
+	}
+	defer f.Close()
+
+	resp := responsex.GetImportSheetsResponse{Sheets: f.GetSheetList()}
+	if sheet, err := selectImportSheet(f, ""); err == nil {
+		resp.Detected = sheet
+	}
+	return resp, nil
+}
+
+// checkImportRowConflicts 检查同一学生同一天的行：时间完全相同为重复，allowOverlap 为 false 时时间交叠也算冲突。
+// 错误记在后出现的行上并注明与之冲突的行号，先出现的行仍可导入
+func checkImportRowConflicts(records []entity.Record, errInfo [][]string, allowOverlap bool) {
+	type studentDay struct {
+		name string
+		date time.Time
+	}
+	seen := make(map[studentDay][]int)
+	for i, record := range records {
+		if len(errInfo[i]) > 0 {
+			continue
+		}
+		key := studentDay{name: record.Student.Name, date: record.TeachingDate}
+		start, end, _ := recordMinutes(record.StartTime, record.EndTime)
+		for _, j := range seen[key] {
+			other := records[j]
+			if other.StartTime == record.StartTime && other.EndTime == record.EndTime {
+				errInfo[i] = append(errInfo[i], fmt.Sprintf("第 %d 行: 与第 %d 行重复（学生 '%s' %s %s-%s）",
+					i+2, j+2, record.Student.Name, record.TeachingDate.Format("2006-01-02"), record.StartTime, record.EndTime))
+				break
+			}
+			otherStart, otherEnd, _ := recordMinutes(other.StartTime, other.EndTime)
+			if !allowOverlap && start < otherEnd && otherStart < end {
+				errInfo[i] = append(errInfo[i], fmt.Sprintf("第 %d 行: 学生 '%s' 的上课时间 %s-%s 与第 %d 行（%s-%s）重叠",
+					i+2, record.Student.Name, record.StartTime, record.EndTime, j+2, other.StartTime, other.EndTime))
+				break
+			}
+		}
+		if len(errInfo[i]) == 0 {
+			seen[key] = append(seen[key], i)
+		}
+	}
 }
 
 func (rm *RecordManager) RegisterRoute(d *dispatcher.Dispatcher) {
 	// Register routes related to record management
 	dispatcher.RegisterTyped(d, "record_manager:create_record", rm.CreateRecord)
+	dispatcher.RegisterTyped(d, "record_manager:update_record", rm.UpdateRecord)
 	dispatcher.RegisterTyped(d, "record_manager:get_record_list", rm.GetRecordList)
 	dispatcher.RegisterTyped(d, "record_manager:activate_record", rm.ActivateRecord)
+	dispatcher.RegisterTyped(d, "record_manager:batch_activate_records", rm.BatchActivateRecords)
+	dispatcher.RegisterTyped(d, "record_manager:deactivate_record", rm.DeactivateRecord)
+	dispatcher.RegisterTyped(d, "record_manager:batch_deactivate_records", rm.BatchDeactivateRecords)
 	dispatcher.RegisterTyped(d, "record_manager:delete_record_by_id", rm.DeleteRecordByID)
 	dispatcher.RegisterNoReq(d, "record_manager:activate_all_pending_records", rm.ActivateAllPendingRecords)
-	dispatcher.RegisterTyped(d, "record_manager:export_record_to_excel", rm.ExportRecordToExcel)
-	dispatcher.RegisterNoReq(d, "record_manager:download_import_template", rm.DownloadImportTemplate)
-	dispatcher.RegisterTyped(d, "record_manager:import_from_excel", rm.ImportFromExcel)
-	dispatcher.RegisterNoReq(d, "record_manager:select_import_file", rm.ShowFilePicker)
+	dispatcher.RegisterTyped(d, "record_manager:export_record_to_excel", rm.ExportRecordToExcel, dispatcher.DesktopOnly())
+	dispatcher.RegisterNoReq(d, "record_manager:download_import_template", rm.DownloadImportTemplate, dispatcher.DesktopOnly())
+	// 导入接口读取调用方给出的本机路径，只允许桌面窗口调用，HTTP 调用方不能借此读写服务器上的任意文件
+	dispatcher.RegisterTyped(d, "record_manager:import_from_excel", rm.ImportFromExcel, dispatcher.DesktopOnly())
+	dispatcher.RegisterTyped(d, "record_manager:get_import_sheets", rm.GetImportSheets, dispatcher.DesktopOnly())
+	dispatcher.RegisterNoReq(d, "record_manager:select_import_file", rm.ShowFilePicker, dispatcher.DesktopOnly())
+	dispatcher.RegisterTyped(d, "record_manager:import_from_excel_async", rm.ImportFromExcelAsync, dispatcher.DesktopOnly())
+	dispatcher.RegisterNoReq(d, "record_manager:activate_all_pending_records_async", rm.ActivateAllPendingRecordsAsync)
+	dispatcher.RegisterTyped(d, "record_manager:export_record_to_excel_async", rm.ExportRecordToExcelAsync, dispatcher.DesktopOnly())
 }
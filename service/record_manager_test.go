@@ -0,0 +1,710 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+
+	"teaching_manage/dao"
+	"teaching_manage/entity"
+	"teaching_manage/pkg/config"
+	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/jobs"
+	"teaching_manage/pkg/wraper"
+	"teaching_manage/repository"
+	requestx "teaching_manage/service/request"
+	responsex "teaching_manage/service/response"
+
+	"gorm.io/gorm"
+)
+
+// newTestRecordManager returns a RecordManager on the database opened by
+// openTestDB, charging 2 hours per record.
+func newTestRecordManager(t *testing.T, db *gorm.DB) *RecordManager {
+	t.Helper()
+	rule, err := NewHourRule(config.HoursConfig{Mode: config.HoursModeFixed, PerRecord: 2})
+	if err != nil {
+		t.Fatalf("NewHourRule() error = %v", err)
+	}
+	return NewRecordManager(repository.NewRecordRepository(dao.NewRecordDao(db)),
+		repository.NewStudentRepository(dao.NewStudentDao(db)), nil, rule)
+}
+
+// createTestRecord creates a pending record through RecordManager and returns
+// its ID.
+func createTestRecord(t *testing.T, db *gorm.DB, rm *RecordManager, req requestx.CreateRecordRequest) uint {
+	t.Helper()
+	if _, err := rm.CreateRecord(context.Background(), &req); err != nil {
+		t.Fatalf("CreateRecord(%+v) error = %v", req, err)
+	}
+	var record dao.Record
+	if err := db.Order("id DESC").First(&record).Error; err != nil {
+		t.Fatalf("find created record: %v", err)
+	}
+	return record.ID
+}
+
+// setStudentHours overwrites a student's balance without touching the ledger.
+func setStudentHours(t *testing.T, db *gorm.DB, studentID uint, hours int) {
+	t.Helper()
+	if err := db.Unscoped().Model(&dao.Student{}).Where("id = ?", studentID).Update("hours", hours).Error; err != nil {
+		t.Fatalf("set student hours: %v", err)
+	}
+}
+
+// studentHours returns a student's balance, including deleted students.
+func studentHours(t *testing.T, db *gorm.DB, studentID uint) int {
+	t.Helper()
+	var student dao.Student
+	if err := db.Unscoped().First(&student, studentID).Error; err != nil {
+		t.Fatalf("find student [%d]: %v", studentID, err)
+	}
+	return student.Hours
+}
+
+// recordActive reports whether a record is active, including deleted records.
+func recordActive(t *testing.T, db *gorm.DB, recordID uint) bool {
+	t.Helper()
+	var record dao.Record
+	if err := db.Unscoped().First(&record, recordID).Error; err != nil {
+		t.Fatalf("find record [%d]: %v", recordID, err)
+	}
+	return record.Active
+}
+
+// auditLogs returns the audit rows of one entity with the given operation.
+func auditLogs(t *testing.T, db *gorm.DB, operation string, entityID uint) []dao.AuditLog {
+	t.Helper()
+	var logs []dao.AuditLog
+	if err := db.Where("operation = ? AND entity_id = ?", operation, entityID).Order("id").Find(&logs).Error; err != nil {
+		t.Fatalf("find audit logs: %v", err)
+	}
+	return logs
+}
+
+// hoursAuditAfter returns the balances written by the student's
+// student:adjust_hours audit rows, oldest first.
+func hoursAuditAfter(t *testing.T, db *gorm.DB, studentID uint) []int {
+	t.Helper()
+	var after []int
+	for _, l := range auditLogs(t, db, auditStudentAdjustHours, studentID) {
+		var h auditHours
+		if err := json.Unmarshal([]byte(l.AfterJSON), &h); err != nil {
+			t.Fatalf("decode audit %q: %v", l.AfterJSON, err)
+		}
+		after = append(after, h.Hours)
+	}
+	return after
+}
+
+func TestUpdateRecord(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	tests := []struct {
+		name string
+		// hours is the explicit hours of the created record
+		hours  *int
+		active bool
+		// session puts the record in a class session of student A's teacher
+		session bool
+		// update edits the request that by default keeps every field
+		update func(req *requestx.UpdateRecordRequest, a, b dao.Student)
+		// wantErr is the expected response code of a failed update
+		wantErr int
+		// balances of students A and B, each starting at 10
+		wantA, wantB int
+		// wantHours is the record's hours after the update
+		wantHours int
+		// wantAuditsA and wantAuditsB are the balances of the student:adjust_hours rows the update adds
+		wantAuditsA, wantAuditsB []int
+	}{
+		{
+			name: "same student more hours", active: true,
+			update: func(req *requestx.UpdateRecordRequest, _, _ dao.Student) { req.Hours = intPtr(3) },
+			wantA:  7, wantB: 10, wantHours: 3, wantAuditsA: []int{7},
+		},
+		{
+			name: "same student fewer hours", active: true,
+			update: func(req *requestx.UpdateRecordRequest, _, _ dao.Student) { req.Hours = intPtr(1) },
+			wantA:  9, wantB: 10, wantHours: 1, wantAuditsA: []int{9},
+		},
+		{
+			name:   "pending record leaves balances",
+			update: func(req *requestx.UpdateRecordRequest, _, _ dao.Student) { req.Hours = intPtr(3) },
+			wantA:  10, wantB: 10, wantHours: 3,
+		},
+		{
+			name: "active record moves to another student", active: true,
+			update: func(req *requestx.UpdateRecordRequest, _, b dao.Student) { req.StudentID = b.ID },
+			wantA:  10, wantB: 8, wantHours: 2, wantAuditsA: []int{10}, wantAuditsB: []int{8},
+		},
+		{
+			name: "unchanged times keep explicit hours", hours: intPtr(3), active: true,
+			update: func(req *requestx.UpdateRecordRequest, _, _ dao.Student) { req.Remark = "改备注" },
+			wantA:  7, wantB: 10, wantHours: 3,
+		},
+		{
+			name: "changed times recompute hours", hours: intPtr(3), active: true,
+			update: func(req *requestx.UpdateRecordRequest, _, _ dao.Student) { req.EndTime = "12:00" },
+			wantA:  8, wantB: 10, wantHours: 2, wantAuditsA: []int{8},
+		},
+		{
+			name: "duplicate record", active: true,
+			update: func(req *requestx.UpdateRecordRequest, _, _ dao.Student) {
+				req.StartTime, req.EndTime, req.AllowOverlap = "14:00", "15:00", true
+			},
+			wantErr: wraper.CodeConflict, wantA: 8, wantB: 10, wantHours: 2,
+		},
+		{
+			name: "session record date", session: true,
+			update:  func(req *requestx.UpdateRecordRequest, _, _ dao.Student) { req.TeachingDate = "2026-03-03" },
+			wantErr: wraper.CodeValidation, wantA: 10, wantB: 10, wantHours: 2,
+		},
+		{
+			name: "session record teacher", session: true,
+			update:  func(req *requestx.UpdateRecordRequest, _, b dao.Student) { req.TeacherID = b.TeacherID },
+			wantErr: wraper.CodeValidation, wantA: 10, wantB: 10, wantHours: 2,
+		},
+		{
+			name: "session record remark", session: true,
+			update: func(req *requestx.UpdateRecordRequest, _, _ dao.Student) { req.Remark = "改备注" },
+			wantA:  10, wantB: 10, wantHours: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			ctx := context.Background()
+			a := createTestStudent(t, db, "张三", "李老师")
+			b := createTestStudent(t, db, "李四", "王老师")
+			setStudentHours(t, db, a.ID, 10)
+			setStudentHours(t, db, b.ID, 10)
+			rm := newTestRecordManager(t, db)
+
+			create := requestx.CreateRecordRequest{StudentID: a.ID, TeachingDate: "2026-03-02", StartTime: "10:00", EndTime: "11:00", Hours: tt.hours}
+			var id uint
+			if tt.session {
+				sm := NewSessionManager(repository.NewSessionRepository(dao.NewSessionDao(db)), repository.NewRecordRepository(dao.NewRecordDao(db)), rm.hours)
+				if _, err := sm.CreateSession(ctx, &requestx.CreateSessionRequest{
+					TeacherID: a.TeacherID, TeachingDate: create.TeachingDate, StartTime: create.StartTime, EndTime: create.EndTime,
+					StudentIDs: []uint{a.ID},
+				}); err != nil {
+					t.Fatalf("CreateSession() error = %v", err)
+				}
+				var record dao.Record
+				if err := db.Where("student_id = ?", a.ID).First(&record).Error; err != nil {
+					t.Fatalf("find session record: %v", err)
+				}
+				id = record.ID
+			} else {
+				id = createTestRecord(t, db, rm, create)
+			}
+			createTestRecord(t, db, rm, requestx.CreateRecordRequest{StudentID: a.ID, TeachingDate: "2026-03-02", StartTime: "14:00", EndTime: "15:00"})
+			if tt.active {
+				if _, err := rm.ActivateRecord(ctx, &requestx.ActivateRecordRequest{RecordID: id}); err != nil {
+					t.Fatalf("ActivateRecord() error = %v", err)
+				}
+			}
+			auditsA, auditsB := len(hoursAuditAfter(t, db, a.ID)), len(hoursAuditAfter(t, db, b.ID))
+
+			req := requestx.UpdateRecordRequest{
+				RecordID: id, StudentID: a.ID, TeachingDate: create.TeachingDate, StartTime: create.StartTime, EndTime: create.EndTime,
+			}
+			tt.update(&req, a, b)
+			_, err := rm.UpdateRecord(ctx, &req)
+			if tt.wantErr != 0 {
+				if got := dispatcher.CodeOf(err); got != tt.wantErr {
+					t.Fatalf("UpdateRecord() error = %v (code %d), want code %d", err, got, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("UpdateRecord() error = %v", err)
+			}
+
+			if got := studentHours(t, db, a.ID); got != tt.wantA {
+				t.Errorf("student A hours = %d, want %d", got, tt.wantA)
+			}
+			if got := studentHours(t, db, b.ID); got != tt.wantB {
+				t.Errorf("student B hours = %d, want %d", got, tt.wantB)
+			}
+			var record dao.Record
+			if err := db.First(&record, id).Error; err != nil {
+				t.Fatalf("find record: %v", err)
+			}
+			if record.Hours != tt.wantHours {
+				t.Errorf("record hours = %d, want %d", record.Hours, tt.wantHours)
+			}
+			if got := hoursAuditAfter(t, db, a.ID)[auditsA:]; !slices.Equal(got, tt.wantAuditsA) {
+				t.Errorf("student A hour audits = %v, want %v", got, tt.wantAuditsA)
+			}
+			if got := hoursAuditAfter(t, db, b.ID)[auditsB:]; !slices.Equal(got, tt.wantAuditsB) {
+				t.Errorf("student B hour audits = %v, want %v", got, tt.wantAuditsB)
+			}
+
+			updates := auditLogs(t, db, auditRecordUpdate, id)
+			if tt.wantErr != 0 {
+				if len(updates) != 0 {
+					t.Errorf("failed update wrote %d record:update audit rows", len(updates))
+				}
+				return
+			}
+			if len(updates) != 1 {
+				t.Fatalf("record:update audit rows = %d, want 1", len(updates))
+			}
+			var after responsex.RecordDTO
+			if err := json.Unmarshal([]byte(updates[0].AfterJSON), &after); err != nil {
+				t.Fatalf("decode audit: %v", err)
+			}
+			if after.Hours != tt.wantHours || after.StudentID != req.StudentID {
+				t.Errorf("audited record = %+v, want hours %d of student %d", after, tt.wantHours, req.StudentID)
+			}
+		})
+	}
+}
+
+// deactivationReason returns the reason of the record's last record:deactivate audit row.
+func deactivationReason(t *testing.T, db *gorm.DB, recordID uint) string {
+	t.Helper()
+	logs := auditLogs(t, db, auditRecordDeactivate, recordID)
+	if len(logs) == 0 {
+		return ""
+	}
+	var after auditDeactivation
+	if err := json.Unmarshal([]byte(logs[len(logs)-1].AfterJSON), &after); err != nil {
+		t.Fatalf("decode audit: %v", err)
+	}
+	if after.Active {
+		t.Errorf("audited record [%d] is still active", recordID)
+	}
+	return after.Reason
+}
+
+func TestDeactivateRecord(t *testing.T) {
+	tests := []struct {
+		name       string
+		attendance string
+		// pending leaves the record unactivated
+		pending bool
+		// deleteStudent deletes the student after the record is activated
+		deleteStudent bool
+		wantErr       int
+		wantHours     int
+		// wantRefunds is the number of student:adjust_hours rows written
+		wantRefunds int
+	}{
+		{name: "attended record refunds", wantHours: 10, wantRefunds: 1},
+		{name: "leave record charged nothing", attendance: dao.AttendanceLeave, wantHours: 10},
+		{name: "deleted student is refunded", deleteStudent: true, wantHours: 10, wantRefunds: 1},
+		{name: "pending record", pending: true, wantErr: wraper.CodeConflict, wantHours: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			ctx := context.Background()
+			student := createTestStudent(t, db, "张三", "李老师")
+			setStudentHours(t, db, student.ID, 10)
+			rm := newTestRecordManager(t, db)
+			id := createTestRecord(t, db, rm, requestx.CreateRecordRequest{
+				StudentID: student.ID, TeachingDate: "2026-03-02", StartTime: "10:00", EndTime: "11:00", Attendance: tt.attendance,
+			})
+			if !tt.pending {
+				if _, err := rm.ActivateRecord(ctx, &requestx.ActivateRecordRequest{RecordID: id}); err != nil {
+					t.Fatalf("ActivateRecord() error = %v", err)
+				}
+			}
+			if tt.deleteStudent {
+				if err := db.Delete(&dao.Student{}, student.ID).Error; err != nil {
+					t.Fatalf("delete student: %v", err)
+				}
+			}
+			refunds := len(hoursAuditAfter(t, db, student.ID))
+
+			_, err := rm.DeactivateRecord(ctx, &requestx.DeactivateRecordRequest{RecordID: id, Reason: "误激活"})
+			if tt.wantErr != 0 {
+				if got := dispatcher.CodeOf(err); got != tt.wantErr {
+					t.Fatalf("DeactivateRecord() error = %v (code %d), want code %d", err, got, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("DeactivateRecord() error = %v", err)
+			}
+
+			if got := studentHours(t, db, student.ID); got != tt.wantHours {
+				t.Errorf("student hours = %d, want %d", got, tt.wantHours)
+			}
+			if recordActive(t, db, id) {
+				t.Errorf("record is still active")
+			}
+			if got := len(hoursAuditAfter(t, db, student.ID)) - refunds; got != tt.wantRefunds {
+				t.Errorf("deactivation wrote %d student:adjust_hours rows, want %d", got, tt.wantRefunds)
+			}
+			wantReason := "误激活"
+			if tt.wantErr != 0 {
+				wantReason = ""
+			}
+			if got := deactivationReason(t, db, id); got != wantReason {
+				t.Errorf("audited reason = %q, want %q", got, wantReason)
+			}
+		})
+	}
+}
+
+func TestBatchDeactivateRecords(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	student := createTestStudent(t, db, "张三", "李老师")
+	setStudentHours(t, db, student.ID, 10)
+	rm := newTestRecordManager(t, db)
+	record := func(start, end, attendance string) uint {
+		return createTestRecord(t, db, rm, requestx.CreateRecordRequest{
+			StudentID: student.ID, TeachingDate: "2026-03-02", StartTime: start, EndTime: end, Attendance: attendance,
+		})
+	}
+	attended := record("08:00", "09:00", "")
+	leave := record("10:00", "11:00", dao.AttendanceLeave)
+	pending := record("12:00", "13:00", "")
+	for _, id := range []uint{attended, leave} {
+		if _, err := rm.ActivateRecord(ctx, &requestx.ActivateRecordRequest{RecordID: id}); err != nil {
+			t.Fatalf("ActivateRecord() error = %v", err)
+		}
+	}
+	if got := studentHours(t, db, student.ID); got != 8 {
+		t.Fatalf("student hours after activation = %d, want 8", got)
+	}
+
+	resp, err := rm.BatchDeactivateRecords(ctx, &requestx.BatchDeactivateRecordsRequest{
+		RecordIDs: []uint{attended, pending, 999, attended, leave}, Reason: "批量撤销",
+	})
+	if err != nil {
+		t.Fatalf("BatchDeactivateRecords() error = %v", err)
+	}
+	want := []responsex.RecordBatchResultDTO{
+		{RecordID: attended, Result: responsex.RecordResultDeactivated},
+		{RecordID: pending, Result: responsex.RecordResultAlreadyPending},
+		{RecordID: 999, Result: responsex.RecordResultNotFound},
+		{RecordID: leave, Result: responsex.RecordResultDeactivated},
+	}
+	if !slices.Equal(resp.Results, want) || resp.Deactivated != 2 {
+		t.Errorf("BatchDeactivateRecords() = %+v, want %+v with 2 deactivated", resp, want)
+	}
+	if got := studentHours(t, db, student.ID); got != 10 {
+		t.Errorf("student hours = %d, want 10", got)
+	}
+	for _, id := range []uint{attended, leave, pending} {
+		if recordActive(t, db, id) {
+			t.Errorf("record [%d] is still active", id)
+		}
+	}
+	for id, want := range map[uint]string{attended: "批量撤销", leave: "批量撤销", pending: ""} {
+		if got := deactivationReason(t, db, id); got != want {
+			t.Errorf("record [%d] audited reason = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestBatchActivateRecords(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	rm := newTestRecordManager(t, db)
+	student := createTestStudent(t, db, "张三", "李老师")
+	gone := createTestStudent(t, db, "李四", "王老师")
+	setStudentHours(t, db, student.ID, 10)
+	setStudentHours(t, db, gone.ID, 10)
+	record := func(studentID uint, start, end string) uint {
+		return createTestRecord(t, db, rm, requestx.CreateRecordRequest{
+			StudentID: studentID, TeachingDate: "2026-03-02", StartTime: start, EndTime: end,
+		})
+	}
+	first := record(student.ID, "08:00", "09:00")
+	second := record(student.ID, "10:00", "11:00")
+	active := record(student.ID, "12:00", "13:00")
+	if _, err := rm.ActivateRecord(ctx, &requestx.ActivateRecordRequest{RecordID: active}); err != nil {
+		t.Fatalf("ActivateRecord() error = %v", err)
+	}
+	orphan := record(gone.ID, "08:00", "09:00")
+	if err := db.Delete(&dao.Student{}, gone.ID).Error; err != nil {
+		t.Fatalf("delete student: %v", err)
+	}
+
+	// all entries run in one batch, in this order
+	tests := []struct {
+		name       string
+		id         uint
+		want       string // empty when the entry is de-duplicated away
+		wantActive bool
+	}{
+		{name: "pending record", id: first, want: responsex.RecordResultActivated, wantActive: true},
+		{name: "already active", id: active, want: responsex.RecordResultAlreadyActive, wantActive: true},
+		{name: "not found", id: 999, want: responsex.RecordResultNotFound},
+		{name: "student deleted", id: orphan, want: responsex.RecordResultStudentDeleted},
+		{name: "duplicate id", id: first, wantActive: true},
+		{name: "second pending record", id: second, want: responsex.RecordResultActivated, wantActive: true},
+	}
+	req := requestx.BatchActivateRecordsRequest{}
+	for _, tt := range tests {
+		req.RecordIDs = append(req.RecordIDs, tt.id)
+	}
+	resp, err := rm.BatchActivateRecords(ctx, &req)
+	if err != nil {
+		t.Fatalf("BatchActivateRecords() error = %v", err)
+	}
+	if resp.Activated != 2 {
+		t.Errorf("Activated = %d, want 2", resp.Activated)
+	}
+	results := resp.Results
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.want != "" {
+				if len(results) == 0 || results[0] != (responsex.RecordBatchResultDTO{RecordID: tt.id, Result: tt.want}) {
+					t.Fatalf("next result = %+v, want record [%d] %s", results, tt.id, tt.want)
+				}
+				results = results[1:]
+			}
+			if tt.id != 999 && recordActive(t, db, tt.id) != tt.wantActive {
+				t.Errorf("record [%d] active = %v, want %v", tt.id, !tt.wantActive, tt.wantActive)
+			}
+		})
+	}
+	if len(results) != 0 {
+		t.Errorf("unexpected results %+v", results)
+	}
+	if got := studentHours(t, db, student.ID); got != 4 {
+		t.Errorf("student hours = %d, want 4", got)
+	}
+	if got := studentHours(t, db, gone.ID); got != 10 {
+		t.Errorf("deleted student hours = %d, want 10", got)
+	}
+}
+
+func TestBatchActivateRecordsRollback(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	rm := newTestRecordManager(t, db)
+	student := createTestStudent(t, db, "张三", "李老师")
+	setStudentHours(t, db, student.ID, 10)
+	first := createTestRecord(t, db, rm, requestx.CreateRecordRequest{StudentID: student.ID, TeachingDate: "2026-03-02", StartTime: "08:00", EndTime: "09:00"})
+	second := createTestRecord(t, db, rm, requestx.CreateRecordRequest{StudentID: student.ID, TeachingDate: "2026-03-02", StartTime: "10:00", EndTime: "11:00"})
+	// activating the second record fails after the first one was activated in the same transaction
+	if err := db.Exec(fmt.Sprintf(`CREATE TRIGGER fail_activate BEFORE UPDATE OF active ON records
+		WHEN NEW.id = %d BEGIN SELECT RAISE(ABORT, 'activation failed'); END`, second)).Error; err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+
+	if _, err := rm.BatchActivateRecords(ctx, &requestx.BatchActivateRecordsRequest{RecordIDs: []uint{first, second}}); err == nil {
+		t.Fatal("BatchActivateRecords() error = nil, want the failure of the second record")
+	}
+	if recordActive(t, db, first) {
+		t.Error("first record stayed active after the batch failed")
+	}
+	if got := studentHours(t, db, student.ID); got != 10 {
+		t.Errorf("student hours = %d, want 10", got)
+	}
+	if logs := auditLogs(t, db, auditRecordActivate, first); len(logs) != 0 {
+		t.Errorf("rolled back activation left %d audit rows", len(logs))
+	}
+}
+
+func TestChargedHoursMatchesSQL(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	rm := newTestRecordManager(t, db)
+	student := createTestStudent(t, db, "张三", "李老师")
+	setStudentHours(t, db, student.ID, 20)
+	today := scheduleToday().Format("2006-01-02")
+	intPtr := func(v int) *int { return &v }
+	record := func(start, end string, hours *int, attendance string, makeupFor uint) uint {
+		return createTestRecord(t, db, rm, requestx.CreateRecordRequest{
+			StudentID: student.ID, TeachingDate: today, StartTime: start, EndTime: end,
+			Hours: hours, Attendance: attendance, MakeupForID: makeupFor,
+		})
+	}
+	attended := record("08:00", "09:00", nil, "", 0)
+	absent := record("10:00", "11:00", intPtr(3), dao.AttendanceAbsent, 0)
+	leave := record("12:00", "13:00", intPtr(4), dao.AttendanceLeave, 0)
+	makeup := record("14:00", "15:00", intPtr(1), dao.AttendanceMakeup, leave)
+	for _, id := range []uint{attended, absent, leave, makeup} {
+		if _, err := rm.ActivateRecord(ctx, &requestx.ActivateRecordRequest{RecordID: id}); err != nil {
+			t.Fatalf("ActivateRecord(%d) error = %v", id, err)
+		}
+	}
+
+	// attended 2 + absent 3 + makeup 1, the leave is free
+	const want = 6
+	if got := 20 - studentHours(t, db, student.ID); got != want {
+		t.Errorf("charged from balance = %d, want %d", got, want)
+	}
+	balances, err := loadLedgerBalances(ctx, db, []uint{student.ID}, false)
+	if err != nil {
+		t.Fatalf("loadLedgerBalances() error = %v", err)
+	}
+	if len(balances) != 1 || balances[0].ConsumedHours != want {
+		t.Errorf("ledger balances = %+v, want %d consumed hours", balances, want)
+	}
+	summary, err := NewDashboardManager().GetSummaryData(ctx)
+	if err != nil {
+		t.Fatalf("GetSummaryData() error = %v", err)
+	}
+	if summary.MonthlyHours != want {
+		t.Errorf("MonthlyHours = %d, want %d", summary.MonthlyHours, want)
+	}
+
+	// every record is charged the same by chargedHours and dao.ChargedHoursSQL
+	var rows []struct {
+		ID         uint
+		Hours      int
+		Attendance string
+		Charged    int
+	}
+	if err := db.Raw("SELECT r.id, r.hours, r.attendance, " + dao.ChargedHoursSQL("r") + " AS charged FROM records r").
+		Scan(&rows).Error; err != nil {
+		t.Fatalf("query charged hours: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("found %d records, want 4", len(rows))
+	}
+	for _, r := range rows {
+		if got := chargedHours(entity.Record{Hours: r.Hours, Attendance: r.Attendance}); got != r.Charged {
+			t.Errorf("record [%d] %s: chargedHours = %d, ChargedHoursSQL = %d", r.ID, r.Attendance, got, r.Charged)
+		}
+	}
+}
+
+func TestCheckMakeup(t *testing.T) {
+	type originals struct {
+		leave, absent, attended, otherStudent, madeUp uint
+	}
+	tests := []struct {
+		name       string
+		attendance string
+		makeupFor  func(o originals) uint
+		wantErr    int
+	}{
+		{name: "makeup for leave", attendance: dao.AttendanceMakeup, makeupFor: func(o originals) uint { return o.leave }},
+		{name: "makeup for absence", attendance: dao.AttendanceMakeup, makeupFor: func(o originals) uint { return o.absent }},
+		{name: "makeup for attended lesson", attendance: dao.AttendanceMakeup, makeupFor: func(o originals) uint { return o.attended }, wantErr: wraper.CodeValidation},
+		{name: "makeup for another student", attendance: dao.AttendanceMakeup, makeupFor: func(o originals) uint { return o.otherStudent }, wantErr: wraper.CodeValidation},
+		{name: "missing original", attendance: dao.AttendanceMakeup, makeupFor: func(originals) uint { return 999 }, wantErr: wraper.CodeNotFound},
+		{name: "original already made up", attendance: dao.AttendanceMakeup, makeupFor: func(o originals) uint { return o.madeUp }, wantErr: wraper.CodeConflict},
+		{name: "makeup without original", attendance: dao.AttendanceMakeup, makeupFor: func(originals) uint { return 0 }, wantErr: wraper.CodeValidation},
+		{name: "original on attended record", attendance: dao.AttendanceAttended, makeupFor: func(o originals) uint { return o.leave }, wantErr: wraper.CodeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			rm := newTestRecordManager(t, db)
+			a := createTestStudent(t, db, "张三", "李老师")
+			b := createTestStudent(t, db, "李四", "王老师")
+			record := func(studentID uint, start string, attendance string, makeupFor uint) uint {
+				return createTestRecord(t, db, rm, requestx.CreateRecordRequest{
+					StudentID: studentID, TeachingDate: "2026-03-02", StartTime: start, EndTime: start[:2] + ":30",
+					Attendance: attendance, MakeupForID: makeupFor,
+				})
+			}
+			o := originals{
+				leave:        record(a.ID, "08:00", dao.AttendanceLeave, 0),
+				absent:       record(a.ID, "09:00", dao.AttendanceAbsent, 0),
+				attended:     record(a.ID, "10:00", "", 0),
+				otherStudent: record(b.ID, "11:00", dao.AttendanceLeave, 0),
+				madeUp:       record(a.ID, "12:00", dao.AttendanceLeave, 0),
+			}
+			record(a.ID, "13:00", dao.AttendanceMakeup, o.madeUp)
+			var before int64
+			db.Model(&dao.Record{}).Count(&before)
+
+			_, err := rm.CreateRecord(context.Background(), &requestx.CreateRecordRequest{
+				StudentID: a.ID, TeachingDate: "2026-03-03", StartTime: "10:00", EndTime: "11:00",
+				Attendance: tt.attendance, MakeupForID: tt.makeupFor(o),
+			})
+			if tt.wantErr == 0 {
+				if err != nil {
+					t.Fatalf("CreateRecord() error = %v", err)
+				}
+				return
+			}
+			if got := dispatcher.CodeOf(err); got != tt.wantErr {
+				t.Fatalf("CreateRecord() error = %v (code %d), want code %d", err, got, tt.wantErr)
+			}
+			var after int64
+			db.Model(&dao.Record{}).Count(&after)
+			if after != before {
+				t.Errorf("rejected makeup created a record")
+			}
+		})
+	}
+
+	t.Run("makeup for itself", func(t *testing.T) {
+		db := openTestDB(t)
+		rm := newTestRecordManager(t, db)
+		a := createTestStudent(t, db, "张三", "李老师")
+		id := createTestRecord(t, db, rm, requestx.CreateRecordRequest{
+			StudentID: a.ID, TeachingDate: "2026-03-02", StartTime: "10:00", EndTime: "11:00", Attendance: dao.AttendanceLeave,
+		})
+		_, err := rm.UpdateRecord(context.Background(), &requestx.UpdateRecordRequest{
+			RecordID: id, StudentID: a.ID, TeachingDate: "2026-03-02", StartTime: "10:00", EndTime: "11:00",
+			Attendance: dao.AttendanceMakeup, MakeupForID: id,
+		})
+		if got := dispatcher.CodeOf(err); got != wraper.CodeValidation {
+			t.Errorf("UpdateRecord() error = %v (code %d), want code %d", err, got, wraper.CodeValidation)
+		}
+	})
+}
+
+func TestActivateAllPendingRecordsJob(t *testing.T) {
+	total := activateAllChunkSize + 50
+	tests := []struct {
+		name string
+		// cancelAt cancels the job when it reports that many records done
+		cancelAt      int
+		wantStatus    jobs.Status
+		wantActivated int
+	}{
+		{name: "finishes", wantStatus: jobs.StatusSucceeded, wantActivated: total},
+		// the first chunk is committed, the cancelled one rolls back
+		{name: "cancelled in second chunk", cancelAt: activateAllChunkSize + 10, wantStatus: jobs.StatusCancelled, wantActivated: activateAllChunkSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			student := createTestStudent(t, db, "张三", "李老师")
+			records := make([]dao.Record, total)
+			for i := range records {
+				records[i] = dao.Record{
+					StudentID: student.ID, TeacherID: student.TeacherID, TeachingDate: testDate("2026-01-01").AddDate(0, 0, i),
+					StartTime: "10:00", EndTime: "11:00", Hours: 1, Attendance: dao.AttendanceAttended,
+				}
+			}
+			if err := db.CreateInBatches(records, 50).Error; err != nil {
+				t.Fatalf("create records: %v", err)
+			}
+			runner := jobs.NewManager(nil, nil)
+			rm := newTestRecordManager(t, db)
+			rm.jobs = runner
+
+			snap, done := runTestJob(t, runner, tt.cancelAt, func() {
+				rm.ActivateAllPendingRecordsAsync(context.Background())
+			})
+			if snap.Status != tt.wantStatus {
+				t.Fatalf("job status = %s (%s), want %s", snap.Status, snap.Error, tt.wantStatus)
+			}
+			wantEvents := total
+			if tt.cancelAt != 0 {
+				wantEvents = tt.cancelAt
+			}
+			if len(done) != wantEvents || done[len(done)-1] != wantEvents {
+				t.Errorf("got %d progress events ending at %v, want %d", len(done), done[len(done)-1:], wantEvents)
+			}
+			var active int64
+			if err := db.Model(&dao.Record{}).Where("active = ?", true).Count(&active).Error; err != nil {
+				t.Fatalf("count active records: %v", err)
+			}
+			if int(active) != tt.wantActivated {
+				t.Errorf("active records = %d, want %d", active, tt.wantActivated)
+			}
+			if got := studentHours(t, db, student.ID); got != -tt.wantActivated {
+				t.Errorf("student hours = %d, want %d", got, -tt.wantActivated)
+			}
+			if got := len(hoursAuditAfter(t, db, student.ID)); got != tt.wantActivated {
+				t.Errorf("hours audit rows = %d, want %d", got, tt.wantActivated)
+			}
+		})
+	}
+}
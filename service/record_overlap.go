@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"teaching_manage/dao"
+	"teaching_manage/entity"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/repository"
+	"time"
+)
+
+// findRecordOverlap 返回同一天与 record 上课时间重叠的第一条记录：同一教师或同一学生的课不能交叠，
+// 首尾相接（10:00-11:00 与 11:00-12:00）不算重叠，请假的课与同一小组课其他学生的记录不算冲突。没有冲突时返回 nil；record.ID 不为 0 时跳过其自身
+func findRecordOverlap(ctx context.Context, repo repository.RecordRepository, record entity.Record) (*entity.Record, error) {
+	start, end, err := recordMinutes(record.StartTime, record.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	others, err := repo.GetRecordsOfDate(ctx, record.TeachingDate, record.Student.ID, record.Teacher.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range others {
+		other := others[i]
+		if (record.ID != 0 && other.ID == record.ID) || other.Attendance == dao.AttendanceLeave {
+			continue
+		}
+		if record.SessionID != 0 && other.SessionID == record.SessionID {
+			continue
+		}
+		otherStart, otherEnd, err := recordMinutes(other.StartTime, other.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		if start < otherEnd && otherStart < end {
+			return &other, nil
+		}
+	}
+	return nil, nil
+}
+
+// recordMinutes 将 HH:MM 格式的开始与结束时间换算为当天的分钟数
+func recordMinutes(start string, end string) (int, int, error) {
+	startTime, err := time.Parse("15:04", start)
+	if err != nil {
+		return 0, 0, errorx.Wrap(errorx.KindValidation, err, "invalid start time")
+	}
+	endTime, err := time.Parse("15:04", end)
+	if err != nil {
+		return 0, 0, errorx.Wrap(errorx.KindValidation, err, "invalid end time")
+	}
+	return startTime.Hour()*60 + startTime.Minute(), endTime.Hour()*60 + endTime.Minute(), nil
+}
+
+// recordOverlapError 返回指明冲突记录的错误，请求中设置 allow_overlap 可跳过该检查（如小组课）
+func recordOverlapError(record entity.Record, clash *entity.Record) error {
+	who := fmt.Sprintf("student %s", clash.Student.Name)
+	if clash.Teacher.ID == record.Teacher.ID {
+		who = fmt.Sprintf("teacher %s", clash.Teacher.Name)
+	}
+	return errorx.Conflict(fmt.Sprintf("overlap: %s already has record [%d] on %s %s-%s (student %s, teacher %s); set allow_overlap to keep both",
+		who, clash.ID, clash.TeachingDate.Format("2006-01-02"), clash.StartTime, clash.EndTime, clash.Student.Name, clash.Teacher.Name))
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"teaching_manage/dao"
+	"teaching_manage/entity"
+	"teaching_manage/repository"
+)
+
+// memRecordRepository keeps records in memory; only GetRecordsOfDate is
+// implemented, other methods panic through the nil embedded interface.
+type memRecordRepository struct {
+	repository.RecordRepository
+	records []entity.Record
+}
+
+func (m *memRecordRepository) GetRecordsOfDate(_ context.Context, date time.Time, studentID uint, teacherID uint) ([]entity.Record, error) {
+	var records []entity.Record
+	for _, r := range m.records {
+		if r.TeachingDate.Equal(date) && (r.Student.ID == studentID || r.Teacher.ID == teacherID) {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+func TestFindRecordOverlap(t *testing.T) {
+	day := testDate("2025-03-01")
+	rec := func(id, studentID, teacherID uint, start, end string) entity.Record {
+		return entity.Record{
+			ID:           id,
+			Student:      entity.Student{ID: studentID},
+			Teacher:      entity.Teacher{ID: teacherID},
+			TeachingDate: day,
+			StartTime:    start,
+			EndTime:      end,
+			Attendance:   dao.AttendanceAttended,
+		}
+	}
+	leave := rec(2, 1, 1, "09:00", "10:00")
+	leave.Attendance = dao.AttendanceLeave
+	sessionA := rec(3, 2, 1, "09:00", "10:00")
+	sessionA.SessionID = 7
+	sessionB := rec(0, 3, 1, "09:00", "10:00")
+	sessionB.SessionID = 7
+	otherSession := rec(0, 4, 1, "09:30", "10:30")
+	otherSession.SessionID = 8
+	otherDay := rec(0, 1, 1, "09:00", "10:00")
+	otherDay.TeachingDate = day.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name     string
+		existing []entity.Record
+		record   entity.Record
+		want     uint // ID of the clashing record, 0 for none
+	}{
+		{name: "no records", record: rec(0, 1, 1, "09:00", "10:00")},
+		{name: "same student overlaps", existing: []entity.Record{rec(1, 1, 2, "09:00", "10:00")}, record: rec(0, 1, 1, "09:30", "10:30"), want: 1},
+		{name: "same teacher overlaps", existing: []entity.Record{rec(1, 2, 1, "09:00", "10:00")}, record: rec(0, 1, 1, "08:30", "09:30"), want: 1},
+		{name: "containing lesson overlaps", existing: []entity.Record{rec(1, 1, 1, "08:00", "12:00")}, record: rec(0, 1, 1, "09:00", "10:00"), want: 1},
+		{name: "touching before", existing: []entity.Record{rec(1, 1, 1, "10:00", "11:00")}, record: rec(0, 1, 1, "09:00", "10:00")},
+		{name: "touching after", existing: []entity.Record{rec(1, 1, 1, "08:00", "09:00")}, record: rec(0, 1, 1, "09:00", "10:00")},
+		{name: "other student and teacher", existing: []entity.Record{rec(1, 2, 2, "09:00", "10:00")}, record: rec(0, 1, 1, "09:00", "10:00")},
+		{name: "other day", existing: []entity.Record{otherDay}, record: rec(0, 1, 1, "09:00", "10:00")},
+		{name: "leave is ignored", existing: []entity.Record{leave}, record: rec(0, 1, 1, "09:00", "10:00")},
+		{name: "leave is skipped for later clashes", existing: []entity.Record{leave, rec(4, 1, 1, "09:30", "10:30")}, record: rec(0, 1, 1, "09:00", "10:00"), want: 4},
+		{name: "same session is ignored", existing: []entity.Record{sessionA}, record: sessionB},
+		{name: "other session overlaps", existing: []entity.Record{sessionA}, record: otherSession, want: 3},
+		{name: "record itself is skipped", existing: []entity.Record{rec(5, 1, 1, "09:00", "10:00")}, record: rec(5, 1, 1, "09:30", "10:30")},
+		{name: "other record of an updated record", existing: []entity.Record{rec(5, 1, 1, "09:00", "10:00"), rec(6, 1, 1, "10:00", "11:00")}, record: rec(5, 1, 1, "09:30", "10:30"), want: 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &memRecordRepository{records: tt.existing}
+			clash, err := findRecordOverlap(context.Background(), repo, tt.record)
+			if err != nil {
+				t.Fatalf("findRecordOverlap() error = %v", err)
+			}
+			var got uint
+			if clash != nil {
+				got = clash.ID
+			}
+			if got != tt.want {
+				t.Fatalf("findRecordOverlap() = record %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindRecordOverlapInvalidTime(t *testing.T) {
+	record := entity.Record{TeachingDate: testDate("2025-03-01"), StartTime: "9.5", EndTime: "10:00"}
+	if _, err := findRecordOverlap(context.Background(), &memRecordRepository{}, record); err == nil {
+		t.Fatalf("findRecordOverlap() with invalid start time returned no error")
+	}
+}
@@ -0,0 +1,21 @@
+package requestx
+
+type QueryAuditLogRequest struct {
+	Operation string `json:"operation" validate:"max=64"`
+	Entity    string `json:"entity" validate:"omitempty,oneof=student teacher order record user schedule holiday session"`
+	EntityID  uint   `json:"entity_id"`
+	Actor     string `json:"actor" validate:"max=64"`
+	StartDate string `json:"start_date" validate:"omitempty,datetime=2006-01-02"`
+	EndDate   string `json:"end_date" validate:"omitempty,datetime=2006-01-02"`
+	Offset    int    `json:"offset" validate:"gte=0"`
+	Limit     int    `json:"limit" validate:"oneof=10 25 50 100 -1"`
+}
+
+type ExportAuditLogRequest struct {
+	Operation string `json:"operation" validate:"max=64"`
+	Entity    string `json:"entity" validate:"omitempty,oneof=student teacher order record user schedule holiday session"`
+	EntityID  uint   `json:"entity_id"`
+	Actor     string `json:"actor" validate:"max=64"`
+	StartDate string `json:"start_date" validate:"omitempty,datetime=2006-01-02"`
+	EndDate   string `json:"end_date" validate:"omitempty,datetime=2006-01-02"`
+}
@@ -0,0 +1,9 @@
+package requestx
+
+type GetJobStatusRequest struct {
+	JobID string `json:"job_id" validate:"required,max=64"`
+}
+
+type CancelJobRequest struct {
+	JobID string `json:"job_id" validate:"required,max=64"`
+}
@@ -0,0 +1,13 @@
+package requestx
+
+type ReconcileLedgerRequest struct {
+	// StudentIDs 为空时核对全部学生（含已删除学生）
+	StudentIDs []uint `json:"student_ids" validate:"omitempty,dive,gt=0"`
+}
+
+type ApplyLedgerCorrectionsRequest struct {
+	// 仅修正列出的学生，需先通过对账报告确认
+	StudentIDs []uint `json:"student_ids" validate:"omitempty,dive,gt=0"`
+	// All 修正全部未删除的学生，不能与 StudentIDs 同时使用
+	All bool `json:"all"`
+}
@@ -5,7 +5,32 @@ type CreateRecordRequest struct {
 	TeachingDate string `json:"teaching_date" validate:"required,datetime=2006-01-02"`
 	StartTime    string `json:"start_time" validate:"required,datetime=15:04"`
 	EndTime      string `json:"end_time" validate:"required,datetime=15:04"`
-	Remark       string `json:"remark" validate:"max=255"`
+	// Hours 为该记录消耗的课时，为空时按配置的课时规则计算
+	Hours  *int   `json:"hours" validate:"omitempty,gte=0,lte=24"`
+	Remark string `json:"remark" validate:"max=255"`
+	// AllowOverlap 允许与同一教师或同一学生的其他记录时间重叠（如小组课）
+	AllowOverlap bool `json:"allow_overlap"`
+	// Attendance 为出勤状态，为空时为正常上课；补课需在 MakeupForID 中指定所补的请假或缺课记录
+	Attendance  string `json:"attendance" validate:"omitempty,oneof=attended absent leave makeup"`
+	MakeupForID uint   `json:"makeup_for_id"`
+}
+
+type UpdateRecordRequest struct {
+	RecordID  uint `json:"record_id" validate:"required,gt=0"`
+	StudentID uint `json:"student_id" validate:"required"`
+	// TeacherID 为 0 时保留原教师；更换学生时默认使用新学生的教师
+	TeacherID    uint   `json:"teacher_id"`
+	TeachingDate string `json:"teaching_date" validate:"required,datetime=2006-01-02"`
+	StartTime    string `json:"start_time" validate:"required,datetime=15:04"`
+	EndTime      string `json:"end_time" validate:"required,datetime=15:04"`
+	// Hours 为空时保留原课时，修改了上课时间则按课时规则重新计算
+	Hours  *int   `json:"hours" validate:"omitempty,gte=0,lte=24"`
+	Remark string `json:"remark" validate:"max=255"`
+	// AllowOverlap 允许与同一教师或同一学生的其他记录时间重叠（如小组课）
+	AllowOverlap bool `json:"allow_overlap"`
+	// Attendance 为空时保留原出勤状态；MakeupForID 为 0 时保留原补课关联
+	Attendance  string `json:"attendance" validate:"omitempty,oneof=attended absent leave makeup"`
+	MakeupForID uint   `json:"makeup_for_id"`
 }
 
 type GetRecordListRequest struct {
@@ -13,6 +38,7 @@ type GetRecordListRequest struct {
 	TeacherKey string `json:"teacher_key" validate:"max=100"`
 	StartDate  string `json:"start_date" validate:"omitempty,datetime=2006-01-02"`
 	EndDate    string `json:"end_date" validate:"omitempty,datetime=2006-01-02"`
+	Attendance string `json:"attendance" validate:"omitempty,oneof=attended absent leave makeup"`
 	Offset     int    `json:"offset" validate:"gte=0"`
 	Limit      int    `json:"limit" validate:"oneof=10 25 50 100 -1"`
 }
@@ -22,7 +48,17 @@ type ActivateRecordRequest struct {
 }
 
 type BatchActivateRecordsRequest struct {
-	RecordIDs []uint `json:"record_ids" validate:"required,dive,gt=0"`
+	RecordIDs []uint `json:"record_ids" validate:"required,min=1,dive,gt=0"`
+}
+
+type DeactivateRecordRequest struct {
+	RecordID uint   `json:"record_id" validate:"required,gt=0"`
+	Reason   string `json:"reason" validate:"required,max=255"`
+}
+
+type BatchDeactivateRecordsRequest struct {
+	RecordIDs []uint `json:"record_ids" validate:"required,min=1,dive,gt=0"`
+	Reason    string `json:"reason" validate:"required,max=255"`
 }
 
 type DeleteRecordRequest struct {
@@ -34,8 +70,29 @@ type ExportRecordsRequest struct {
 	TeacherKey string `json:"teacher_key" validate:"max=100"`
 	StartDate  string `json:"start_date" validate:"omitempty,datetime=2006-01-02"`
 	EndDate    string `json:"end_date" validate:"omitempty,datetime=2006-01-02"`
+	Attendance string `json:"attendance" validate:"omitempty,oneof=attended absent leave makeup"`
 }
 
+// 导入模式
+const (
+	// ImportModeAll 任何一行出错则整个文件都不导入
+	ImportModeAll = "all"
+	// ImportModeDryRun 只检查每一行能否导入并返回逐行结果，不写入数据
+	ImportModeDryRun = "dry_run"
+	// ImportModeSkipErrors 导入没有错误的行，出错的行写入错误工作簿
+	ImportModeSkipErrors = "skip_errors"
+)
+
 type ImportRecordsRequest struct {
 	Filepath string `json:"filepath" validate:"required,max=2048,filepath"`
+	// AllowOverlap 允许导入的记录与同一教师或同一学生的其他记录时间重叠
+	AllowOverlap bool `json:"allow_overlap"`
+	// Mode 为空时按 ImportModeAll 导入
+	Mode string `json:"mode" validate:"omitempty,oneof=all dry_run skip_errors"`
+	// Sheet 为要导入的工作表，为空时自动选择第一个表头符合要求的工作表
+	Sheet string `json:"sheet" validate:"max=31"`
+}
+
+type GetImportSheetsRequest struct {
+	Filepath string `json:"filepath" validate:"required,max=2048,filepath"`
 }
@@ -0,0 +1,59 @@
+package requestx
+
+type CreateScheduleRequest struct {
+	StudentID uint `json:"student_id" validate:"required"`
+	// TeacherID 为 0 时使用学生的教师
+	TeacherID uint `json:"teacher_id"`
+	// Weekday 为星期几，0 为星期日
+	Weekday   int    `json:"weekday" validate:"gte=0,lte=6"`
+	StartTime string `json:"start_time" validate:"required,datetime=15:04"`
+	EndTime   string `json:"end_time" validate:"required,datetime=15:04"`
+	StartDate string `json:"start_date" validate:"required,datetime=2006-01-02"`
+	// EndDate 为空表示课表不结束，记录按配置的周数提前生成
+	EndDate      string `json:"end_date" validate:"omitempty,datetime=2006-01-02"`
+	SkipHolidays bool   `json:"skip_holidays"`
+	AllowOverlap bool   `json:"allow_overlap"`
+	// Hours 为每节课消耗的课时，为空时按配置的课时规则计算
+	Hours  *int   `json:"hours" validate:"omitempty,gte=0,lte=24"`
+	Remark string `json:"remark" validate:"max=255"`
+}
+
+type UpdateScheduleRequest struct {
+	ID uint `json:"id" validate:"required"`
+	// TeacherID 为 0 时保留原教师
+	TeacherID    uint   `json:"teacher_id"`
+	Weekday      int    `json:"weekday" validate:"gte=0,lte=6"`
+	StartTime    string `json:"start_time" validate:"required,datetime=15:04"`
+	EndTime      string `json:"end_time" validate:"required,datetime=15:04"`
+	StartDate    string `json:"start_date" validate:"required,datetime=2006-01-02"`
+	EndDate      string `json:"end_date" validate:"omitempty,datetime=2006-01-02"`
+	SkipHolidays bool   `json:"skip_holidays"`
+	AllowOverlap bool   `json:"allow_overlap"`
+	Hours        *int   `json:"hours" validate:"omitempty,gte=0,lte=24"`
+	Remark       string `json:"remark" validate:"max=255"`
+}
+
+type CancelScheduleRequest struct {
+	ID uint `json:"id" validate:"required"`
+}
+
+type GetScheduleListRequest struct {
+	// StudentID 为 0 时返回全部学生的课表
+	StudentID uint `json:"student_id"`
+	Offset    int  `json:"offset" validate:"gte=0"`
+	Limit     int  `json:"limit" validate:"oneof=10 25 50 100 -1"`
+}
+
+type CreateHolidayRequest struct {
+	Date string `json:"date" validate:"required,datetime=2006-01-02"`
+	Name string `json:"name" validate:"max=50"`
+}
+
+type DeleteHolidayRequest struct {
+	ID uint `json:"id" validate:"required"`
+}
+
+type GetHolidayListRequest struct {
+	StartDate string `json:"start_date" validate:"omitempty,datetime=2006-01-02"`
+	EndDate   string `json:"end_date" validate:"omitempty,datetime=2006-01-02"`
+}
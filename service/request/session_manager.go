@@ -0,0 +1,48 @@
+package requestx
+
+type CreateSessionRequest struct {
+	TeacherID    uint   `json:"teacher_id" validate:"required"`
+	TeachingDate string `json:"teaching_date" validate:"required,datetime=2006-01-02"`
+	StartTime    string `json:"start_time" validate:"required,datetime=15:04"`
+	EndTime      string `json:"end_time" validate:"required,datetime=15:04"`
+	Title        string `json:"title" validate:"max=100"`
+	// StudentIDs 为参加的学生，每名学生生成一条待激活记录
+	StudentIDs []uint `json:"student_ids" validate:"required,min=1,max=100,dive,required"`
+	// Hours 为每名学生消耗的课时，为空时按配置的课时规则计算
+	Hours        *int   `json:"hours" validate:"omitempty,gte=0,lte=24"`
+	Remark       string `json:"remark" validate:"max=255"`
+	AllowOverlap bool   `json:"allow_overlap"`
+}
+
+type AddSessionAttendeesRequest struct {
+	SessionID    uint   `json:"session_id" validate:"required"`
+	StudentIDs   []uint `json:"student_ids" validate:"required,min=1,max=100,dive,required"`
+	Hours        *int   `json:"hours" validate:"omitempty,gte=0,lte=24"`
+	AllowOverlap bool   `json:"allow_overlap"`
+}
+
+type UpdateSessionRequest struct {
+	SessionID uint `json:"session_id" validate:"required"`
+	// TeacherID 为 0 时保留原教师
+	TeacherID    uint   `json:"teacher_id"`
+	TeachingDate string `json:"teaching_date" validate:"required,datetime=2006-01-02"`
+	StartTime    string `json:"start_time" validate:"required,datetime=15:04"`
+	EndTime      string `json:"end_time" validate:"required,datetime=15:04"`
+	Title        string `json:"title" validate:"max=100"`
+	// Hours 不为空时所有学生改为该课时；为空时保留各自的课时，上课时间变化则按规则重新计算
+	Hours        *int   `json:"hours" validate:"omitempty,gte=0,lte=24"`
+	Remark       string `json:"remark" validate:"max=255"`
+	AllowOverlap bool   `json:"allow_overlap"`
+}
+
+type SessionIDRequest struct {
+	SessionID uint `json:"session_id" validate:"required"`
+}
+
+type GetSessionListRequest struct {
+	TeacherKey string `json:"teacher_key"`
+	StartDate  string `json:"start_date" validate:"omitempty,datetime=2006-01-02"`
+	EndDate    string `json:"end_date" validate:"omitempty,datetime=2006-01-02"`
+	Offset     int    `json:"offset" validate:"gte=0"`
+	Limit      int    `json:"limit" validate:"oneof=10 25 50 100 -1"`
+}
@@ -0,0 +1,6 @@
+package requestx
+
+type RestoreBackupRequest struct {
+	// Name 为备份目录中的文件名，见 system:list_backups
+	Name string `json:"name" validate:"required,max=255"`
+}
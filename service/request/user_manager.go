@@ -0,0 +1,35 @@
+package requestx
+
+type LoginRequest struct {
+	Username string `json:"username" validate:"required,max=32"`
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" validate:"required,max=72"`
+	NewPassword string `json:"new_password" validate:"required,min=8,max=72"`
+}
+
+type CreateUserRequest struct {
+	Username    string `json:"username" validate:"required,max=32"`
+	Password    string `json:"password" validate:"required,min=8,max=72"`
+	DisplayName string `json:"display_name" validate:"max=32"`
+	Role        string `json:"role" validate:"required,oneof=admin front_desk teacher"`
+}
+
+type GetUserListRequest struct {
+	Offset int `json:"offset" validate:"gte=0"`
+	Limit  int `json:"limit" validate:"oneof=10 25 50 100 -1"`
+}
+
+type UpdateUserRequest struct {
+	ID          uint   `json:"id" validate:"required"`
+	DisplayName string `json:"display_name" validate:"max=32"`
+	Role        string `json:"role" validate:"required,oneof=admin front_desk teacher"`
+	Disabled    bool   `json:"disabled"`
+}
+
+type ResetPasswordRequest struct {
+	ID       uint   `json:"id" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
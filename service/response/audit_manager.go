@@ -0,0 +1,22 @@
+package responsex
+
+import "encoding/json"
+
+type QueryAuditLogResponse struct {
+	Logs  []AuditLogDTO `json:"logs"`
+	Total int64         `json:"total"`
+}
+
+type AuditLogDTO struct {
+	ID        uint   `json:"id"`
+	CreatedAt int64  `json:"created_at"`
+	Operation string `json:"operation"`
+	Entity    string `json:"entity"`
+	EntityID  uint   `json:"entity_id"`
+	// Before 新建时为 null，After 删除时为 null
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	RequestID string          `json:"request_id"`
+	Route     string          `json:"route"`
+	Actor     string          `json:"actor"`
+}
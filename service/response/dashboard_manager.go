@@ -4,7 +4,7 @@ package responsex
 type DashboardSummaryResponse struct {
 	TotalStudents        int64  `json:"total_students"`
 	NewStudentsThisMonth int64  `json:"new_students_this_month"` // 本月新增
-	MonthlyHours         int64  `json:"monthly_hours"`           // 本月消课数 (节)
+	MonthlyHours         int64  `json:"monthly_hours"`           // 本月消耗课时
 	MonthOverMonth       string `json:"month_over_month"`        // 环比增长
 	TotalRemainingHours  int64  `json:"total_remaining_hours"`   // 剩余总课时
 	TotalArrears         int64  `json:"total_arrears"`           // 欠费人数
@@ -27,8 +27,10 @@ type FinanceChartDTO struct {
 
 // TeacherRankDTO 教师排行
 type TeacherRankDTO struct {
-	Names  []string `json:"names"`
-	Values []int64  `json:"values"`
+	Names []string `json:"names"`
+	// Values 为学生课时之和，小组课按人数计入；Sessions 为上课的课次数，一节小组课只算一次
+	Values   []int64 `json:"values"`
+	Sessions []int64 `json:"sessions"`
 }
 
 type EngagementStat struct {
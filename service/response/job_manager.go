@@ -0,0 +1,5 @@
+package responsex
+
+type StartJobResponse struct {
+	JobID string `json:"job_id"`
+}
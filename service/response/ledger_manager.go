@@ -0,0 +1,50 @@
+package responsex
+
+// LedgerReportResponse 课时对账报告
+type LedgerReportResponse struct {
+	CheckedStudents int              `json:"checked_students"`
+	Drifted         []LedgerDriftDTO `json:"drifted"`
+	GeneratedAt     int64            `json:"generated_at"`
+}
+
+// LedgerDriftDTO 余额与流水不一致的学生
+type LedgerDriftDTO struct {
+	StudentID     uint              `json:"student_id"`
+	StudentName   string            `json:"student_name"`
+	Deleted       bool              `json:"deleted"`
+	Hours         int               `json:"hours"`          // 当前余额
+	ExpectedHours int               `json:"expected_hours"` // 按流水计算的余额
+	Diff          int               `json:"diff"`           // 当前余额 - 流水余额
+	OrderHours    int               `json:"order_hours"`    // 生效订单课时合计
+	ConsumedHours int               `json:"consumed_hours"` // 已激活上课记录消耗课时合计
+	Orders        []LedgerOrderDTO  `json:"orders"`
+	Records       []LedgerRecordDTO `json:"records"`
+}
+
+type LedgerOrderDTO struct {
+	ID        uint   `json:"id"`
+	CreatedAt int64  `json:"created_at"`
+	Hours     int    `json:"hours"`
+	Comment   string `json:"comment"`
+}
+
+type LedgerRecordDTO struct {
+	ID           uint   `json:"id"`
+	TeachingDate string `json:"teaching_date"`
+	StartTime    string `json:"start_time"`
+	EndTime      string `json:"end_time"`
+	// Hours 为该记录实际扣除的课时，请假记录为 0
+	Hours      int    `json:"hours"`
+	Attendance string `json:"attendance"`
+}
+
+type ApplyLedgerCorrectionsResponse struct {
+	Corrections []LedgerCorrectionDTO `json:"corrections"`
+}
+
+// LedgerCorrectionDTO 为一条补偿订单；余额在对账时已一致的学生不会生成
+type LedgerCorrectionDTO struct {
+	StudentID   uint   `json:"student_id"`
+	StudentName string `json:"student_name"`
+	Hours       int    `json:"hours"`
+}
@@ -16,15 +16,75 @@ type RecordDTO struct {
 	StartTime    string `json:"start_time"`
 	EndTime      string `json:"end_time"`
 	Active       bool   `json:"active"`
+	Hours        int    `json:"hours"`
 	Remark       string `json:"remark"`
+	ScheduleID   uint   `json:"schedule_id"`
+	Attendance   string `json:"attendance"`
+	MakeupForID  uint   `json:"makeup_for_id"`
+	SessionID    uint   `json:"session_id"`
 	CreatedAt    int64  `json:"created_at"`
 	UpdatedAt    int64  `json:"updated_at"`
 }
 
+// 批量操作中单条记录的处理结果
+const (
+	RecordResultActivated      = "activated"
+	RecordResultAlreadyActive  = "already_active"
+	RecordResultStudentDeleted = "student_deleted"
+	RecordResultDeactivated    = "deactivated"
+	RecordResultAlreadyPending = "already_pending"
+	RecordResultNotFound       = "not_found"
+)
+
+type RecordBatchResultDTO struct {
+	RecordID uint   `json:"record_id"`
+	Result   string `json:"result"`
+}
+
+type BatchActivateRecordsResponse struct {
+	Results   []RecordBatchResultDTO `json:"results"`
+	Activated int                    `json:"activated"`
+}
+
+type BatchDeactivateRecordsResponse struct {
+	Results     []RecordBatchResultDTO `json:"results"`
+	Deactivated int                    `json:"deactivated"`
+}
+
+// 导入时单行的处理结果
+const (
+	// ImportRowOK 试导入时该行可以导入
+	ImportRowOK       = "ok"
+	ImportRowImported = "imported"
+	ImportRowFailed   = "failed"
+)
+
+type ImportRowDTO struct {
+	// Row 为 Excel 中的行号，表头为第 1 行
+	Row         int      `json:"row"`
+	StudentName string   `json:"student_name"`
+	Status      string   `json:"status"`
+	Errors      []string `json:"errors"`
+}
+
 type ImportFromExcelResponse struct {
-	Filepath   string     `json:"filepath"`
-	TotalRows  int        `json:"total_rows"`
-	ErrorInfos [][]string `json:"error_infos"`
+	Filepath  string `json:"filepath"`
+	Mode      string `json:"mode"`
+	TotalRows int    `json:"total_rows"`
+	// ErrorInfos 为每个数据行的错误信息，没有错误的行为空
+	ErrorInfos [][]string     `json:"error_infos"`
+	Rows       []ImportRowDTO `json:"rows"`
+	// Passed 为已导入（试导入时为可以导入）的行数，Failed 为出错的行数
+	Passed int `json:"passed"`
+	Failed int `json:"failed"`
+	// ErrorFilepath 为跳过出错行导入时生成的错误工作簿，包含出错行的原始内容与错误信息，可修改后重新导入
+	ErrorFilepath string `json:"error_filepath"`
+}
+
+type GetImportSheetsResponse struct {
+	Sheets []string `json:"sheets"`
+	// Detected 为不指定工作表时自动选择的工作表，没有符合要求的工作表时为空
+	Detected string `json:"detected"`
 }
 
 type SelectFileResponse struct {
@@ -0,0 +1,63 @@
+package responsex
+
+type ScheduleDTO struct {
+	ID             uint   `json:"id"`
+	StudentID      uint   `json:"student_id"`
+	StudentName    string `json:"student_name"`
+	TeacherID      uint   `json:"teacher_id"`
+	TeacherName    string `json:"teacher_name"`
+	Weekday        int    `json:"weekday"`
+	StartTime      string `json:"start_time"`
+	EndTime        string `json:"end_time"`
+	StartDate      string `json:"start_date"`
+	EndDate        string `json:"end_date"`
+	SkipHolidays   bool   `json:"skip_holidays"`
+	AllowOverlap   bool   `json:"allow_overlap"`
+	Hours          *int   `json:"hours"`
+	Remark         string `json:"remark"`
+	GeneratedUntil string `json:"generated_until"`
+	CreatedAt      int64  `json:"created_at"`
+	UpdatedAt      int64  `json:"updated_at"`
+}
+
+type GetScheduleListResponse struct {
+	Schedules []ScheduleDTO `json:"schedules"`
+	Total     int64         `json:"total"`
+}
+
+// 课表某一天没有生成记录的原因
+const (
+	ScheduleSkipOverlap        = "overlap"
+	ScheduleSkipStudentDeleted = "student_deleted"
+	ScheduleSkipTeacherDeleted = "teacher_deleted"
+	// ScheduleSkipEdited 为修改或取消课表时保留的、手工修改过的待激活记录
+	ScheduleSkipEdited = "edited"
+)
+
+type ScheduleSkipDTO struct {
+	ScheduleID uint   `json:"schedule_id"`
+	Date       string `json:"date"`
+	Reason     string `json:"reason"`
+	Detail     string `json:"detail"`
+}
+
+// ScheduleRecordsResponse 为课表操作对待激活记录的影响
+type ScheduleRecordsResponse struct {
+	// ScheduleID 为新建或修改的课表，批量生成时为 0
+	ScheduleID uint `json:"schedule_id"`
+	// Created 为新生成的记录数，Updated 为按新规则原地修改的待激活记录数，Removed 为删除的未来待激活记录数
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Removed int               `json:"removed"`
+	Skipped []ScheduleSkipDTO `json:"skipped"`
+}
+
+type HolidayDTO struct {
+	ID   uint   `json:"id"`
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+type GetHolidayListResponse struct {
+	Holidays []HolidayDTO `json:"holidays"`
+}
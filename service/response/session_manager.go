@@ -0,0 +1,28 @@
+package responsex
+
+type SessionDTO struct {
+	ID           uint   `json:"id"`
+	TeacherID    uint   `json:"teacher_id"`
+	TeacherName  string `json:"teacher_name"`
+	TeachingDate string `json:"teaching_date"`
+	StartTime    string `json:"start_time"`
+	EndTime      string `json:"end_time"`
+	Title        string `json:"title"`
+	Remark       string `json:"remark"`
+	// Attendees 为学生人数，ActiveAttendees 为已激活（已扣课时）的人数
+	Attendees       int   `json:"attendees"`
+	ActiveAttendees int   `json:"active_attendees"`
+	CreatedAt       int64 `json:"created_at"`
+	UpdatedAt       int64 `json:"updated_at"`
+}
+
+// GetSessionResponse 为课次及其每名学生的上课记录，单个学生的激活、出勤与删除使用 record_manager 的接口
+type GetSessionResponse struct {
+	Session   SessionDTO  `json:"session"`
+	Attendees []RecordDTO `json:"attendees"`
+}
+
+type GetSessionListResponse struct {
+	Sessions []SessionDTO `json:"sessions"`
+	Total    int64        `json:"total"`
+}
@@ -0,0 +1,15 @@
+package responsex
+
+type BackupDTO struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Size      int64  `json:"size"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type RestoreBackupResponse struct {
+	Restored BackupDTO `json:"restored"`
+	// SafetyBackup 是恢复前为当前数据库做的备份，可用于撤销本次恢复
+	SafetyBackup BackupDTO `json:"safety_backup"`
+	Version      int       `json:"version"`
+}
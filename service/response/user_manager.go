@@ -0,0 +1,28 @@
+package responsex
+
+type UserDTO struct {
+	ID          uint   `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	Role        string `json:"role"`
+	Disabled    bool   `json:"disabled"`
+	CreatedAt   int64  `json:"created_at"`
+	UpdatedAt   int64  `json:"updated_at"`
+}
+
+type LoginResponse struct {
+	// Token 需要通过 HTTP 调用时放在 Authorization: Bearer 头中，桌面窗口无需携带
+	Token string  `json:"token"`
+	User  UserDTO `json:"user"`
+}
+
+type CurrentUserResponse struct {
+	User UserDTO `json:"user"`
+	// Setup 为 true 表示还没有任何账号，桌面窗口以临时管理员身份运行，需先创建管理员
+	Setup bool `json:"setup"`
+}
+
+type GetUserListResponse struct {
+	Users []UserDTO `json:"users"`
+	Total int64     `json:"total"`
+}
@@ -0,0 +1,678 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"teaching_manage/dao"
+	"teaching_manage/entity"
+	"teaching_manage/pkg/config"
+	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/logger"
+	"teaching_manage/repository"
+	requestx "teaching_manage/service/request"
+	responsex "teaching_manage/service/response"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ScheduleManager 管理每周固定时间的课表，并按课表提前生成待激活的上课记录。
+// 记录从今天起生成到配置的周数之后；修改课表时未经手工修改的待激活记录按新规则原地更新，
+// 取消课表只删除明天及以后未经修改的待激活记录；已激活或修改过的记录保持不变。
+type ScheduleManager struct {
+	Ctx   context.Context
+	repo  repository.ScheduleRepository
+	repoS repository.StudentRepository
+	hours HourRule
+	cfg   config.ScheduleConfig
+}
+
+func NewScheduleManager(repo repository.ScheduleRepository, repoS repository.StudentRepository, hours HourRule, cfg config.ScheduleConfig) *ScheduleManager {
+	return &ScheduleManager{repo: repo, repoS: repoS, hours: hours, cfg: cfg}
+}
+
+// scheduleToday 返回本地日期的当天，与上课日期一样表示为 UTC 零点
+func scheduleToday() time.Time {
+	y, m, d := time.Now().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func laterDate(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// parseScheduleRule 校验课表的时间与起止日期，返回开始与结束日期，结束日期为空时返回零值
+func parseScheduleRule(startDate string, endDate string, startTime string, endTime string) (time.Time, time.Time, error) {
+	start, err := parseRecordSchedule(startDate, startTime, endTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if endDate == "" {
+		return start, time.Time{}, nil
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, errorx.Wrap(errorx.KindValidation, err, "invalid end date")
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, errorx.Validation("end date must not be before start date")
+	}
+	return start, end, nil
+}
+
+func (sm *ScheduleManager) CreateSchedule(ctx context.Context, req *requestx.CreateScheduleRequest) (responsex.ScheduleRecordsResponse, error) {
+	log := logger.FromContext(ctx).With(logger.UInt("student_id", req.StudentID))
+	log.Info("Creating schedule",
+		logger.Int("weekday", req.Weekday),
+		logger.String("start_time", req.StartTime),
+		logger.String("end_time", req.EndTime),
+		logger.String("start_date", req.StartDate),
+		logger.String("end_date", req.EndDate),
+	)
+
+	startDate, endDate, err := parseScheduleRule(req.StartDate, req.EndDate, req.StartTime, req.EndTime)
+	if err != nil {
+		return responsex.ScheduleRecordsResponse{}, err
+	}
+	if err := sm.hours.checkExplicit(ctx, req.StartTime, req.EndTime, req.Hours); err != nil {
+		return responsex.ScheduleRecordsResponse{}, err
+	}
+	if _, err := sm.hours.Hours(req.StartTime, req.EndTime, req.Hours); err != nil {
+		return responsex.ScheduleRecordsResponse{}, err
+	}
+
+	var result responsex.ScheduleRecordsResponse
+	err = dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txScheduleRepo := repository.NewScheduleRepository(dao.NewScheduleDao(tx))
+
+		student, err := repository.NewStudentRepository(dao.NewStudentDao(tx)).GetStudentByID(ctx, req.StudentID)
+		if err != nil {
+			return errorx.Wrap(errorx.KindNotFound, err, fmt.Sprintf("student [%d] not found", req.StudentID))
+		}
+		teacher := student.Teacher
+		if req.TeacherID != 0 {
+			t, err := repository.NewTeacherRepository(dao.NewTeacherDao(tx)).GetTeacherByID(ctx, req.TeacherID)
+			if err != nil {
+				return errorx.Wrap(errorx.KindNotFound, err, fmt.Sprintf("teacher [%d] not found", req.TeacherID))
+			}
+			teacher = *t
+		}
+		if teacher.ID == 0 || !teacher.DeletedAt.IsZero() {
+			return errorx.NotFound(fmt.Sprintf("associated teacher not found for student ID %d", req.StudentID))
+		}
+
+		schedule := entity.Schedule{
+			Student:      entity.Student{ID: student.ID, Name: student.Name},
+			Teacher:      entity.Teacher{ID: teacher.ID, Name: teacher.Name},
+			Weekday:      time.Weekday(req.Weekday),
+			StartTime:    req.StartTime,
+			EndTime:      req.EndTime,
+			StartDate:    startDate,
+			EndDate:      endDate,
+			SkipHolidays: req.SkipHolidays,
+			AllowOverlap: req.AllowOverlap,
+			Hours:        req.Hours,
+			Remark:       req.Remark,
+		}
+		if err := txScheduleRepo.CreateSchedule(ctx, &schedule); err != nil {
+			return err
+		}
+		if err := writeAudit(ctx, tx, auditScheduleCreate, auditEntitySchedule, schedule.ID, nil, scheduleSnapshot(&schedule)); err != nil {
+			return err
+		}
+
+		result.ScheduleID = schedule.ID
+		return sm.generate(ctx, tx, schedule, laterDate(startDate, scheduleToday()), nil, &result)
+	})
+	if err != nil {
+		log.Error("failed to create schedule", logger.ErrorType(err))
+		return responsex.ScheduleRecordsResponse{}, err
+	}
+	return result, nil
+}
+
+// UpdateSchedule 修改课表规则：今天及以后未经修改的待激活记录按新规则原地更新，修改过的记录保持不变并记入 Skipped，
+// 再按新规则补足生成范围内缺少的记录；已有记录、保留的记录或被手工删除的记录所在的周不再生成
+func (sm *ScheduleManager) UpdateSchedule(ctx context.Context, req *requestx.UpdateScheduleRequest) (responsex.ScheduleRecordsResponse, error) {
+	log := logger.FromContext(ctx).With(logger.UInt("schedule_id", req.ID))
+	log.Info("Updating schedule",
+		logger.Int("weekday", req.Weekday),
+		logger.String("start_time", req.StartTime),
+		logger.String("end_time", req.EndTime),
+		logger.String("start_date", req.StartDate),
+		logger.String("end_date", req.EndDate),
+	)
+
+	startDate, endDate, err := parseScheduleRule(req.StartDate, req.EndDate, req.StartTime, req.EndTime)
+	if err != nil {
+		return responsex.ScheduleRecordsResponse{}, err
+	}
+	if err := sm.hours.checkExplicit(ctx, req.StartTime, req.EndTime, req.Hours); err != nil {
+		return responsex.ScheduleRecordsResponse{}, err
+	}
+	hours, err := sm.hours.Hours(req.StartTime, req.EndTime, req.Hours)
+	if err != nil {
+		return responsex.ScheduleRecordsResponse{}, err
+	}
+
+	result := responsex.ScheduleRecordsResponse{ScheduleID: req.ID}
+	err = dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txScheduleRepo := repository.NewScheduleRepository(dao.NewScheduleDao(tx))
+
+		before, err := txScheduleRepo.GetScheduleByID(ctx, req.ID)
+		if err != nil {
+			return err
+		}
+		after := *before
+		if req.TeacherID != 0 && req.TeacherID != before.Teacher.ID {
+			t, err := repository.NewTeacherRepository(dao.NewTeacherDao(tx)).GetTeacherByID(ctx, req.TeacherID)
+			if err != nil {
+				return errorx.Wrap(errorx.KindNotFound, err, fmt.Sprintf("teacher [%d] not found", req.TeacherID))
+			}
+			after.Teacher = entity.Teacher{ID: t.ID, Name: t.Name}
+		}
+		after.Weekday = time.Weekday(req.Weekday)
+		after.StartTime = req.StartTime
+		after.EndTime = req.EndTime
+		after.StartDate = startDate
+		after.EndDate = endDate
+		after.SkipHolidays = req.SkipHolidays
+		after.AllowOverlap = req.AllowOverlap
+		after.Hours = req.Hours
+		after.Remark = req.Remark
+		after.GeneratedUntil = time.Time{}
+
+		if err := txScheduleRepo.UpdateSchedule(ctx, after); err != nil {
+			return err
+		}
+		today := scheduleToday()
+		blocked, err := sm.rescheduleRecords(ctx, tx, *before, after, hours, today, &result)
+		if err != nil {
+			return err
+		}
+		if err := txScheduleRepo.SetGeneratedUntil(ctx, after.ID, time.Time{}); err != nil {
+			return err
+		}
+		if err := sm.generate(ctx, tx, after, laterDate(startDate, today), blocked, &result); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, auditScheduleUpdate, auditEntitySchedule, after.ID, scheduleSnapshot(before), scheduleSnapshot(&after))
+	})
+	if err != nil {
+		log.Error("failed to update schedule", logger.ErrorType(err))
+		return responsex.ScheduleRecordsResponse{}, err
+	}
+	return result, nil
+}
+
+// CancelSchedule 取消课表：删除明天及以后未经修改的待激活记录，已激活或修改过的记录保留，修改过的记入 Skipped
+func (sm *ScheduleManager) CancelSchedule(ctx context.Context, req *requestx.CancelScheduleRequest) (responsex.ScheduleRecordsResponse, error) {
+	log := logger.FromContext(ctx).With(logger.UInt("schedule_id", req.ID))
+	log.Info("Cancelling schedule")
+
+	result := responsex.ScheduleRecordsResponse{ScheduleID: req.ID}
+	err := dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txScheduleRepo := repository.NewScheduleRepository(dao.NewScheduleDao(tx))
+
+		schedule, err := txScheduleRepo.GetScheduleByID(ctx, req.ID)
+		if err != nil {
+			return err
+		}
+		if err := sm.removePendingScheduleRecords(ctx, tx, *schedule, scheduleToday().AddDate(0, 0, 1), &result); err != nil {
+			return err
+		}
+		if err := txScheduleRepo.DeleteSchedule(ctx, schedule.ID); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, auditScheduleCancel, auditEntitySchedule, schedule.ID, scheduleSnapshot(schedule), nil)
+	})
+	if err != nil {
+		log.Error("failed to cancel schedule", logger.ErrorType(err))
+		return responsex.ScheduleRecordsResponse{}, err
+	}
+	return result, nil
+}
+
+func (sm *ScheduleManager) GetScheduleList(ctx context.Context, req *requestx.GetScheduleListRequest) (responsex.GetScheduleListResponse, error) {
+	schedules, total, err := sm.repo.GetScheduleList(ctx, req.StudentID, req.Offset, req.Limit)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get schedule list", logger.ErrorType(err))
+		return responsex.GetScheduleListResponse{}, err
+	}
+	result := make([]responsex.ScheduleDTO, 0, len(schedules))
+	for i := range schedules {
+		result = append(result, scheduleSnapshot(&schedules[i]))
+	}
+	return responsex.GetScheduleListResponse{Schedules: result, Total: total}, nil
+}
+
+// GenerateRecords 将所有课表的记录补足到配置的周数之后，程序启动时以及之后每天会自动执行一次
+func (sm *ScheduleManager) GenerateRecords(ctx context.Context) (responsex.ScheduleRecordsResponse, error) {
+	logger.InfoContext(ctx, "Generating records for all schedules", logger.Int("horizon_weeks", sm.cfg.HorizonWeeks))
+
+	var result responsex.ScheduleRecordsResponse
+	err := dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		schedules, _, err := repository.NewScheduleRepository(dao.NewScheduleDao(tx)).GetScheduleList(ctx, 0, 0, -1)
+		if err != nil {
+			return err
+		}
+		today := scheduleToday()
+		for _, schedule := range schedules {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			from := laterDate(schedule.StartDate, today)
+			if !schedule.GeneratedUntil.IsZero() {
+				from = laterDate(from, schedule.GeneratedUntil.AddDate(0, 0, 1))
+			}
+			if err := sm.generate(ctx, tx, schedule, from, nil, &result); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to generate schedule records", logger.ErrorType(err))
+		return responsex.ScheduleRecordsResponse{}, err
+	}
+	logger.InfoContext(ctx, "schedule records generated", logger.Int("created", result.Created), logger.Int("skipped", len(result.Skipped)))
+	return result, nil
+}
+
+// RunGenerate 启动时生成一次课表记录，之后每天生成一次，直到 ctx 结束
+func (sm *ScheduleManager) RunGenerate(ctx context.Context) {
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+	for {
+		if _, err := sm.GenerateRecords(ctx); err != nil {
+			logger.Error("scheduled record generation failed", logger.ErrorType(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// generate 为课表生成 [from, 生成截止日期] 内的待激活记录，并把结果累加到 result。
+// 已有该课表记录的日期、跳过的节假日以及 blocked 中的日期（YYYY-MM-DD）不生成；与其他记录时间重叠的日期记入 Skipped
+func (sm *ScheduleManager) generate(ctx context.Context, tx *gorm.DB, schedule entity.Schedule, from time.Time,
+	blocked map[string]bool, result *responsex.ScheduleRecordsResponse) error {
+	to := scheduleToday().AddDate(0, 0, 7*sm.cfg.HorizonWeeks)
+	if !schedule.EndDate.IsZero() && schedule.EndDate.Before(to) {
+		to = schedule.EndDate
+	}
+	if from.After(to) {
+		return nil
+	}
+
+	txRecordRepo := repository.NewRecordRepository(dao.NewRecordDao(tx))
+	txScheduleRepo := repository.NewScheduleRepository(dao.NewScheduleDao(tx))
+
+	skip := func(reason string, detail string) {
+		result.Skipped = append(result.Skipped, responsex.ScheduleSkipDTO{
+			ScheduleID: schedule.ID, Date: from.Format("2006-01-02"), Reason: reason, Detail: detail,
+		})
+	}
+	student, err := repository.NewStudentRepository(dao.NewStudentDao(tx)).GetStudentByIdWithDeleted(ctx, schedule.Student.ID)
+	if err != nil {
+		return err
+	}
+	if !student.DeletedAt.IsZero() {
+		skip(responsex.ScheduleSkipStudentDeleted, fmt.Sprintf("student %s is deleted", student.Name))
+		return nil
+	}
+	if _, err := repository.NewTeacherRepository(dao.NewTeacherDao(tx)).GetTeacherByID(ctx, schedule.Teacher.ID); err != nil {
+		if errors.Is(err, dao.ErrRecordNotFound) {
+			skip(responsex.ScheduleSkipTeacherDeleted, fmt.Sprintf("teacher [%d] is deleted", schedule.Teacher.ID))
+			return nil
+		}
+		return err
+	}
+	schedule.Student.Name = student.Name
+
+	hours, err := sm.hours.Hours(schedule.StartTime, schedule.EndTime, schedule.Hours)
+	if err != nil {
+		return err
+	}
+
+	existing, err := txRecordRepo.GetRecordsOfSchedule(ctx, schedule.ID, from)
+	if err != nil {
+		return err
+	}
+	taken := make(map[string]bool, len(existing)+len(blocked))
+	for date := range blocked {
+		taken[date] = true
+	}
+	for _, r := range existing {
+		taken[r.TeachingDate.Format("2006-01-02")] = true
+	}
+	if schedule.SkipHolidays {
+		holidays, err := txScheduleRepo.GetHolidayList(ctx, from, to)
+		if err != nil {
+			return err
+		}
+		for _, h := range holidays {
+			taken[h.Date.Format("2006-01-02")] = true
+		}
+	}
+
+	first := from.AddDate(0, 0, (int(schedule.Weekday)-int(from.Weekday())+7)%7)
+	for date := first; !date.After(to); date = date.AddDate(0, 0, 7) {
+		if taken[date.Format("2006-01-02")] {
+			continue
+		}
+		record := entity.Record{
+			Student:      entity.Student{ID: schedule.Student.ID, Name: schedule.Student.Name},
+			Teacher:      entity.Teacher{ID: schedule.Teacher.ID, Name: schedule.Teacher.Name},
+			TeachingDate: date,
+			StartTime:    schedule.StartTime,
+			EndTime:      schedule.EndTime,
+			Hours:        hours,
+			Remark:       schedule.Remark,
+			ScheduleID:   schedule.ID,
+			Attendance:   dao.AttendanceAttended,
+		}
+		if !schedule.AllowOverlap {
+			clash, err := findRecordOverlap(ctx, txRecordRepo, record)
+			if err != nil {
+				return err
+			}
+			if clash != nil {
+				result.Skipped = append(result.Skipped, responsex.ScheduleSkipDTO{
+					ScheduleID: schedule.ID, Date: date.Format("2006-01-02"),
+					Reason: responsex.ScheduleSkipOverlap, Detail: recordOverlapError(record, clash).Error(),
+				})
+				continue
+			}
+		}
+		if err := txRecordRepo.CreateRecord(ctx, &record); err != nil {
+			if errors.Is(err, dao.ErrDuplicatedKey) {
+				result.Skipped = append(result.Skipped, responsex.ScheduleSkipDTO{
+					ScheduleID: schedule.ID, Date: date.Format("2006-01-02"),
+					Reason: responsex.ScheduleSkipOverlap, Detail: "duplicate: record already exists",
+				})
+				continue
+			}
+			return err
+		}
+		if err := writeAudit(ctx, tx, auditRecordGenerate, auditEntityRecord, record.ID, nil, recordSnapshot(&record)); err != nil {
+			return err
+		}
+		result.Created++
+	}
+	return txScheduleRepo.SetGeneratedUntil(ctx, schedule.ID, to)
+}
+
+// scheduleRecordEdit 返回课表生成的待激活记录与课表规则不同的地方，未经手工修改时返回空字符串。
+// hours 为按课表规则计算的课时；被补课记录引用的记录也视为修改过
+func scheduleRecordEdit(ctx context.Context, repo repository.RecordRepository, record entity.Record, schedule entity.Schedule,
+	hours int) (string, error) {
+	switch {
+	case record.Attendance != dao.AttendanceAttended:
+		return fmt.Sprintf("attendance is %s", record.Attendance), nil
+	case record.MakeupForID != 0:
+		return "is a makeup lesson", nil
+	case record.SessionID != 0:
+		return "belongs to a group session", nil
+	case record.Student.ID != schedule.Student.ID:
+		return "student was changed", nil
+	case record.Teacher.ID != schedule.Teacher.ID:
+		return "teacher was changed", nil
+	case record.TeachingDate.Weekday() != schedule.Weekday:
+		return "date was changed", nil
+	case record.StartTime != schedule.StartTime || record.EndTime != schedule.EndTime:
+		return "time was changed", nil
+	case record.Hours != hours:
+		return "hours were changed", nil
+	case record.Remark != schedule.Remark:
+		return "remark was changed", nil
+	}
+	n, err := repo.CountMakeupReferences(ctx, record.ID)
+	if err != nil {
+		return "", err
+	}
+	if n > 0 {
+		return "has makeup lessons", nil
+	}
+	return "", nil
+}
+
+// skipEditedRecord 将保留的手工修改过的记录记入 result.Skipped
+func skipEditedRecord(result *responsex.ScheduleRecordsResponse, schedule entity.Schedule, record entity.Record, edit string) {
+	result.Skipped = append(result.Skipped, responsex.ScheduleSkipDTO{
+		ScheduleID: schedule.ID, Date: record.TeachingDate.Format("2006-01-02"), Reason: responsex.ScheduleSkipEdited,
+		Detail: fmt.Sprintf("record [%d] %s, kept as is", record.ID, edit),
+	})
+}
+
+// rescheduleRecords 把课表 before 在 from 及以后未经修改的待激活记录按新规则 after 原地更新：
+// 星期变化时移到同一周之后对应的那一天，新日期超出课表起止日期或生成范围、是跳过的节假日、或与其他记录重叠时删除。
+// 已激活与修改过的记录保持不变，修改过的记入 Skipped。返回不应再按新规则生成记录的日期：
+// 保留的记录与被手工删除的记录所在的周对应的新日期，以免找回已取消的课或在同一周多排一节课
+func (sm *ScheduleManager) rescheduleRecords(ctx context.Context, tx *gorm.DB, before entity.Schedule, after entity.Schedule,
+	hours int, from time.Time, result *responsex.ScheduleRecordsResponse) (map[string]bool, error) {
+	txRecordRepo := repository.NewRecordRepository(dao.NewRecordDao(tx))
+	oldHours, err := sm.hours.Hours(before.StartTime, before.EndTime, before.Hours)
+	if err != nil {
+		return nil, err
+	}
+	offset := (int(after.Weekday) - int(before.Weekday) + 7) % 7
+	shift := func(date time.Time) time.Time { return date.AddDate(0, 0, offset) }
+
+	blocked := make(map[string]bool)
+	deleted, err := txRecordRepo.GetDeletedRecordsOfSchedule(ctx, before.ID, from)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range deleted {
+		blocked[shift(r.TeachingDate).Format("2006-01-02")] = true
+	}
+
+	records, err := txRecordRepo.GetRecordsOfSchedule(ctx, before.ID, from)
+	if err != nil || len(records) == 0 {
+		return blocked, err
+	}
+	to := scheduleToday().AddDate(0, 0, 7*sm.cfg.HorizonWeeks)
+	if !after.EndDate.IsZero() && after.EndDate.Before(to) {
+		to = after.EndDate
+	}
+	holidays := make(map[string]bool)
+	if after.SkipHolidays {
+		list, err := repository.NewScheduleRepository(dao.NewScheduleDao(tx)).GetHolidayList(ctx, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range list {
+			holidays[h.Date.Format("2006-01-02")] = true
+		}
+	}
+
+	for i := range records {
+		record := records[i]
+		record.Student.Name = before.Student.Name
+		if record.Teacher.ID == before.Teacher.ID {
+			record.Teacher.Name = before.Teacher.Name
+		}
+		date := shift(record.TeachingDate)
+		if record.Active {
+			blocked[date.Format("2006-01-02")] = true
+			continue
+		}
+		edit, err := scheduleRecordEdit(ctx, txRecordRepo, record, before, oldHours)
+		if err != nil {
+			return nil, err
+		}
+		if edit != "" {
+			blocked[date.Format("2006-01-02")] = true
+			skipEditedRecord(result, after, record, edit)
+			continue
+		}
+
+		updated := record
+		updated.TeachingDate = date
+		updated.Teacher = after.Teacher
+		updated.StartTime = after.StartTime
+		updated.EndTime = after.EndTime
+		updated.Hours = hours
+		updated.Remark = after.Remark
+
+		drop := date.Before(after.StartDate) || date.After(to) || holidays[date.Format("2006-01-02")]
+		if !drop && !after.AllowOverlap {
+			clash, err := findRecordOverlap(ctx, txRecordRepo, updated)
+			if err != nil {
+				return nil, err
+			}
+			if clash != nil {
+				result.Skipped = append(result.Skipped, responsex.ScheduleSkipDTO{
+					ScheduleID: after.ID, Date: date.Format("2006-01-02"),
+					Reason: responsex.ScheduleSkipOverlap, Detail: recordOverlapError(updated, clash).Error(),
+				})
+				drop = true
+			}
+		}
+		if !drop {
+			err := txRecordRepo.UpdateRecord(ctx, updated)
+			if err == nil {
+				if err := writeAudit(ctx, tx, auditRecordUpdate, auditEntityRecord, record.ID, recordSnapshot(&record), recordSnapshot(&updated)); err != nil {
+					return nil, err
+				}
+				result.Updated++
+				continue
+			}
+			if !errors.Is(err, dao.ErrDuplicatedKey) {
+				return nil, err
+			}
+			result.Skipped = append(result.Skipped, responsex.ScheduleSkipDTO{
+				ScheduleID: after.ID, Date: date.Format("2006-01-02"),
+				Reason: responsex.ScheduleSkipOverlap, Detail: "duplicate: record already exists",
+			})
+		}
+		// 由课表维护删除的记录不保留软删除的行，以免之后被当作手工删除的课
+		if err := txRecordRepo.PurgeRecordByID(ctx, record.ID); err != nil {
+			return nil, err
+		}
+		if err := writeAudit(ctx, tx, auditRecordDelete, auditEntityRecord, record.ID, recordSnapshot(&record), nil); err != nil {
+			return nil, err
+		}
+		result.Removed++
+	}
+	return blocked, nil
+}
+
+// removePendingScheduleRecords 删除课表在 from 及以后未经修改的待激活记录，修改过的记录保留并记入 Skipped
+func (sm *ScheduleManager) removePendingScheduleRecords(ctx context.Context, tx *gorm.DB, schedule entity.Schedule, from time.Time,
+	result *responsex.ScheduleRecordsResponse) error {
+	txRecordRepo := repository.NewRecordRepository(dao.NewRecordDao(tx))
+	hours, err := sm.hours.Hours(schedule.StartTime, schedule.EndTime, schedule.Hours)
+	if err != nil {
+		return err
+	}
+	records, err := txRecordRepo.GetRecordsOfSchedule(ctx, schedule.ID, from)
+	if err != nil {
+		return err
+	}
+	for i := range records {
+		record := records[i]
+		if record.Active {
+			continue
+		}
+		edit, err := scheduleRecordEdit(ctx, txRecordRepo, record, schedule, hours)
+		if err != nil {
+			return err
+		}
+		if edit != "" {
+			skipEditedRecord(result, schedule, record, edit)
+			continue
+		}
+		if err := txRecordRepo.DeleteRecordByID(ctx, record.ID); err != nil {
+			return err
+		}
+		record.Student.Name = schedule.Student.Name
+		record.Teacher.Name = schedule.Teacher.Name
+		if err := writeAudit(ctx, tx, auditRecordDelete, auditEntityRecord, record.ID, recordSnapshot(&record), nil); err != nil {
+			return err
+		}
+		result.Removed++
+	}
+	return nil
+}
+
+// CreateHoliday 添加节假日，之后生成的记录会跳过该日期；已生成的记录不受影响，需要时可修改课表重新生成
+func (sm *ScheduleManager) CreateHoliday(ctx context.Context, req *requestx.CreateHolidayRequest) (responsex.HolidayDTO, error) {
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		return responsex.HolidayDTO{}, errorx.Wrap(errorx.KindValidation, err, "invalid date")
+	}
+	holiday := entity.Holiday{Date: date, Name: req.Name}
+	err = dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := repository.NewScheduleRepository(dao.NewScheduleDao(tx)).CreateHoliday(ctx, &holiday); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, auditHolidayCreate, auditEntityHoliday, holiday.ID, nil, holidaySnapshot(&holiday))
+	})
+	if errors.Is(err, dao.ErrDuplicatedKey) {
+		return responsex.HolidayDTO{}, errorx.Wrap(errorx.KindConflict, err, fmt.Sprintf("duplicate: %s is already a holiday", req.Date))
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to create holiday", logger.String("date", req.Date), logger.ErrorType(err))
+		return responsex.HolidayDTO{}, err
+	}
+	return holidaySnapshot(&holiday), nil
+}
+
+func (sm *ScheduleManager) DeleteHoliday(ctx context.Context, req *requestx.DeleteHolidayRequest) (string, error) {
+	err := dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txScheduleRepo := repository.NewScheduleRepository(dao.NewScheduleDao(tx))
+		holiday, err := txScheduleRepo.GetHolidayByID(ctx, req.ID)
+		if err != nil {
+			return err
+		}
+		if err := txScheduleRepo.DeleteHoliday(ctx, req.ID); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, auditHolidayDelete, auditEntityHoliday, req.ID, holidaySnapshot(holiday), nil)
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to delete holiday", logger.UInt("holiday_id", req.ID), logger.ErrorType(err))
+		return "", err
+	}
+	return "Holiday deleted successfully", nil
+}
+
+func (sm *ScheduleManager) GetHolidayList(ctx context.Context, req *requestx.GetHolidayListRequest) (responsex.GetHolidayListResponse, error) {
+	var from, to time.Time
+	if req.StartDate != "" {
+		from, _ = time.Parse("2006-01-02", req.StartDate)
+	}
+	if req.EndDate != "" {
+		to, _ = time.Parse("2006-01-02", req.EndDate)
+	}
+	holidays, err := sm.repo.GetHolidayList(ctx, from, to)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get holiday list", logger.ErrorType(err))
+		return responsex.GetHolidayListResponse{}, err
+	}
+	result := make([]responsex.HolidayDTO, 0, len(holidays))
+	for i := range holidays {
+		result = append(result, holidaySnapshot(&holidays[i]))
+	}
+	return responsex.GetHolidayListResponse{Holidays: result}, nil
+}
+
+func (sm *ScheduleManager) RegisterRoute(d *dispatcher.Dispatcher) {
+	dispatcher.RegisterTyped(d, "schedule_manager:create_schedule", sm.CreateSchedule)
+	dispatcher.RegisterTyped(d, "schedule_manager:update_schedule", sm.UpdateSchedule)
+	dispatcher.RegisterTyped(d, "schedule_manager:cancel_schedule", sm.CancelSchedule)
+	dispatcher.RegisterTyped(d, "schedule_manager:get_schedule_list", sm.GetScheduleList)
+	dispatcher.RegisterNoReq(d, "schedule_manager:generate_records", sm.GenerateRecords)
+	dispatcher.RegisterTyped(d, "schedule_manager:create_holiday", sm.CreateHoliday)
+	dispatcher.RegisterTyped(d, "schedule_manager:delete_holiday", sm.DeleteHoliday)
+	dispatcher.RegisterTyped(d, "schedule_manager:get_holiday_list", sm.GetHolidayList)
+}
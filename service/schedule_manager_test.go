@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"teaching_manage/dao"
+	"teaching_manage/pkg/config"
+	"teaching_manage/repository"
+	requestx "teaching_manage/service/request"
+	responsex "teaching_manage/service/response"
+
+	"gorm.io/gorm"
+)
+
+// newTestSchedule creates a schedule for a new student on today's weekday,
+// 10:00-11:00, generating four weeks ahead, and returns its ID and records.
+func newTestSchedule(t *testing.T) (*ScheduleManager, *gorm.DB, uint, []dao.Record) {
+	t.Helper()
+	db := openTestDB(t)
+	rule, err := NewHourRule(config.HoursConfig{Mode: config.HoursModeFixed, PerRecord: 2})
+	if err != nil {
+		t.Fatalf("NewHourRule() error = %v", err)
+	}
+	sm := NewScheduleManager(repository.NewScheduleRepository(dao.NewScheduleDao(db)),
+		repository.NewStudentRepository(dao.NewStudentDao(db)), rule, config.ScheduleConfig{HorizonWeeks: 4})
+	student := createTestStudent(t, db, "张三", "李老师")
+	today := scheduleToday()
+	created, err := sm.CreateSchedule(context.Background(), &requestx.CreateScheduleRequest{
+		StudentID: student.ID, Weekday: int(today.Weekday()), StartTime: "10:00", EndTime: "11:00",
+		StartDate: today.Format("2006-01-02"), Remark: "钢琴",
+	})
+	if err != nil {
+		t.Fatalf("CreateSchedule() error = %v", err)
+	}
+	records := scheduleRecords(t, db, created.ScheduleID)
+	if len(records) != 5 {
+		t.Fatalf("CreateSchedule() generated %d records, want 5", len(records))
+	}
+	return sm, db, created.ScheduleID, records
+}
+
+// scheduleRecords returns the schedule's undeleted records ordered by date.
+func scheduleRecords(t *testing.T, db *gorm.DB, scheduleID uint) []dao.Record {
+	t.Helper()
+	var records []dao.Record
+	if err := db.Where("schedule_id = ?", scheduleID).Order("teaching_date").Find(&records).Error; err != nil {
+		t.Fatalf("find records: %v", err)
+	}
+	return records
+}
+
+func recordsOn(records []dao.Record, date time.Time) []dao.Record {
+	var out []dao.Record
+	for _, r := range records {
+		if r.TeachingDate.Equal(date) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func skippedEdited(result responsex.ScheduleRecordsResponse, date time.Time) bool {
+	for _, s := range result.Skipped {
+		if s.Reason == responsex.ScheduleSkipEdited && s.Date == date.Format("2006-01-02") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestUpdateScheduleKeepsEditedRecords(t *testing.T) {
+	setColumn := func(column string, value any) func(*testing.T, *gorm.DB, dao.Record) {
+		return func(t *testing.T, db *gorm.DB, r dao.Record) {
+			if err := db.Model(&dao.Record{}).Where("id = ?", r.ID).Update(column, value).Error; err != nil {
+				t.Fatalf("update %s: %v", column, err)
+			}
+		}
+	}
+	tests := []struct {
+		name string
+		// edit changes next week's record before the schedule is updated
+		edit func(*testing.T, *gorm.DB, dao.Record)
+		// kept is whether the record stays as it was; deleted lessons are
+		// neither kept nor generated again
+		kept    bool
+		deleted bool
+	}{
+		{name: "unedited record moves", edit: func(*testing.T, *gorm.DB, dao.Record) {}},
+		{name: "leave mark", edit: setColumn("attendance", dao.AttendanceLeave), kept: true},
+		{name: "absent mark", edit: setColumn("attendance", dao.AttendanceAbsent), kept: true},
+		{name: "remark", edit: setColumn("remark", "带琴谱"), kept: true},
+		{name: "hours override", edit: setColumn("hours", 3), kept: true},
+		{name: "makeup reference", kept: true, edit: func(t *testing.T, db *gorm.DB, r dao.Record) {
+			makeup := dao.Record{
+				StudentID: r.StudentID, TeacherID: r.TeacherID, TeachingDate: r.TeachingDate.AddDate(0, 0, 2),
+				StartTime: "18:00", EndTime: "19:00", Hours: 2, Attendance: dao.AttendanceAttended, MakeupForID: &r.ID,
+			}
+			if err := dao.NewRecordDao(db).CreateRecord(context.Background(), &makeup); err != nil {
+				t.Fatalf("create makeup: %v", err)
+			}
+		}},
+		{name: "deleted lesson", deleted: true, edit: func(t *testing.T, db *gorm.DB, r dao.Record) {
+			if err := dao.NewRecordDao(db).DeleteRecordByID(context.Background(), r.ID); err != nil {
+				t.Fatalf("delete record: %v", err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm, db, id, records := newTestSchedule(t)
+			today := scheduleToday()
+			nextWeek := records[1]
+			tt.edit(t, db, nextWeek)
+
+			// Move to the next day's afternoon; the last week's record leaves the horizon.
+			result, err := sm.UpdateSchedule(context.Background(), &requestx.UpdateScheduleRequest{
+				ID: id, Weekday: int(today.AddDate(0, 0, 1).Weekday()), StartTime: "14:00", EndTime: "15:00",
+				StartDate: today.Format("2006-01-02"), Remark: "钢琴",
+			})
+			if err != nil {
+				t.Fatalf("UpdateSchedule() error = %v", err)
+			}
+			after := scheduleRecords(t, db, id)
+
+			moved := recordsOn(after, today.AddDate(0, 0, 1))
+			if len(moved) != 1 || moved[0].ID != records[0].ID || moved[0].StartTime != "14:00" {
+				t.Errorf("today's record = %+v, want record [%d] moved to tomorrow 14:00", moved, records[0].ID)
+			}
+			if got := recordsOn(after, today.AddDate(0, 0, 28)); len(got) != 0 {
+				t.Errorf("last week's record still on its old date: %+v", got)
+			}
+
+			old := recordsOn(after, nextWeek.TeachingDate)
+			shifted := recordsOn(after, nextWeek.TeachingDate.AddDate(0, 0, 1))
+			switch {
+			case tt.kept:
+				if len(old) != 1 || old[0].ID != nextWeek.ID || old[0].StartTime != "10:00" {
+					t.Errorf("edited record = %+v, want record [%d] kept at 10:00", old, nextWeek.ID)
+				}
+				if len(shifted) != 0 {
+					t.Errorf("generated %+v next to the kept record", shifted)
+				}
+				if !skippedEdited(result, nextWeek.TeachingDate) {
+					t.Errorf("Skipped = %+v, want the edited record reported", result.Skipped)
+				}
+			case tt.deleted:
+				if len(old)+len(shifted) != 0 {
+					t.Errorf("deleted lesson came back: %+v %+v", old, shifted)
+				}
+			default:
+				if len(old) != 0 || len(shifted) != 1 || shifted[0].ID != nextWeek.ID || shifted[0].StartTime != "14:00" {
+					t.Errorf("unedited record = %+v %+v, want record [%d] moved in place", old, shifted, nextWeek.ID)
+				}
+			}
+			if result.Created != 0 {
+				t.Errorf("Created = %d, want 0", result.Created)
+			}
+			if result.Removed != 1 {
+				t.Errorf("Removed = %d, want 1", result.Removed)
+			}
+			// A record moved out of range must not stay soft deleted, or the next
+			// update would take it for a lesson the user deleted.
+			var purged int64
+			if err := db.Unscoped().Model(&dao.Record{}).Where("id = ?", records[4].ID).Count(&purged).Error; err != nil {
+				t.Fatalf("count purged record: %v", err)
+			}
+			if purged != 0 {
+				t.Errorf("record [%d] moved out of range was soft deleted, want purged", records[4].ID)
+			}
+		})
+	}
+}
+
+func TestCancelScheduleKeepsEditedRecords(t *testing.T) {
+	sm, db, id, records := newTestSchedule(t)
+	if err := db.Model(&dao.Record{}).Where("id = ?", records[2].ID).Update("remark", "带琴谱").Error; err != nil {
+		t.Fatalf("update remark: %v", err)
+	}
+
+	result, err := sm.CancelSchedule(context.Background(), &requestx.CancelScheduleRequest{ID: id})
+	if err != nil {
+		t.Fatalf("CancelSchedule() error = %v", err)
+	}
+	if result.Removed != 3 {
+		t.Errorf("Removed = %d, want 3", result.Removed)
+	}
+	if !skippedEdited(result, records[2].TeachingDate) {
+		t.Errorf("Skipped = %+v, want the edited record reported", result.Skipped)
+	}
+	after := scheduleRecords(t, db, id)
+	if len(after) != 2 || after[0].ID != records[0].ID || after[1].ID != records[2].ID {
+		t.Errorf("records after cancel = %+v, want today's and the edited record", after)
+	}
+}
@@ -0,0 +1,406 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"teaching_manage/dao"
+	"teaching_manage/entity"
+	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/logger"
+	"teaching_manage/repository"
+	requestx "teaching_manage/service/request"
+	responsex "teaching_manage/service/response"
+
+	"gorm.io/gorm"
+)
+
+// SessionManager 管理小组课：一节课有一名教师和多名学生，每名学生各有一条上课记录（session_id 指向课次）。
+// 每名学生的记录单独激活、扣课时与记出勤，因此单个学生的操作仍使用 record_manager 的接口；
+// 课次的日期、时间与教师只能在这里修改，修改会同步到所有学生的记录
+type SessionManager struct {
+	Ctx   context.Context
+	repo  repository.SessionRepository
+	repoR repository.RecordRepository
+	hours HourRule
+}
+
+func NewSessionManager(repo repository.SessionRepository, repoR repository.RecordRepository, hours HourRule) *SessionManager {
+	return &SessionManager{repo: repo, repoR: repoR, hours: hours}
+}
+
+func (sm *SessionManager) CreateSession(ctx context.Context, req *requestx.CreateSessionRequest) (responsex.GetSessionResponse, error) {
+	log := logger.FromContext(ctx).With(logger.UInt("teacher_id", req.TeacherID))
+	log.Info("Creating class session",
+		logger.String("teaching_date", req.TeachingDate),
+		logger.String("start_time", req.StartTime),
+		logger.String("end_time", req.EndTime),
+		logger.Any("student_ids", req.StudentIDs),
+	)
+
+	teachingDate, err := parseRecordSchedule(req.TeachingDate, req.StartTime, req.EndTime)
+	if err != nil {
+		return responsex.GetSessionResponse{}, err
+	}
+	if err := sm.hours.checkExplicit(ctx, req.StartTime, req.EndTime, req.Hours); err != nil {
+		return responsex.GetSessionResponse{}, err
+	}
+	hours, err := sm.hours.Hours(req.StartTime, req.EndTime, req.Hours)
+	if err != nil {
+		return responsex.GetSessionResponse{}, err
+	}
+
+	var result responsex.GetSessionResponse
+	err = dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		teacher, err := repository.NewTeacherRepository(dao.NewTeacherDao(tx)).GetTeacherByID(ctx, req.TeacherID)
+		if err != nil {
+			return errorx.Wrap(errorx.KindNotFound, err, fmt.Sprintf("teacher [%d] not found", req.TeacherID))
+		}
+		session := entity.ClassSession{
+			Teacher:      entity.Teacher{ID: teacher.ID, Name: teacher.Name},
+			TeachingDate: teachingDate,
+			StartTime:    req.StartTime,
+			EndTime:      req.EndTime,
+			Title:        req.Title,
+			Remark:       req.Remark,
+		}
+		if err := repository.NewSessionRepository(dao.NewSessionDao(tx)).CreateSession(ctx, &session); err != nil {
+			return err
+		}
+		if err := writeAudit(ctx, tx, auditSessionCreate, auditEntitySession, session.ID, nil, sessionSnapshot(&session)); err != nil {
+			return err
+		}
+		if err := addSessionAttendees(ctx, tx, session, req.StudentIDs, hours, req.AllowOverlap); err != nil {
+			return err
+		}
+		result, err = getSession(ctx, tx, session.ID)
+		return err
+	})
+	if err != nil {
+		log.Error("failed to create class session", logger.ErrorType(err))
+		return responsex.GetSessionResponse{}, err
+	}
+	return result, nil
+}
+
+// AddAttendees 为课次添加学生，新学生的记录为待激活状态；移除学生直接删除其记录
+func (sm *SessionManager) AddAttendees(ctx context.Context, req *requestx.AddSessionAttendeesRequest) (responsex.GetSessionResponse, error) {
+	log := logger.FromContext(ctx).With(logger.UInt("session_id", req.SessionID))
+	log.Info("Adding class session attendees", logger.Any("student_ids", req.StudentIDs))
+
+	var result responsex.GetSessionResponse
+	err := dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		session, err := repository.NewSessionRepository(dao.NewSessionDao(tx)).GetSessionByID(ctx, req.SessionID)
+		if err != nil {
+			return err
+		}
+		if err := sm.hours.checkExplicit(ctx, session.StartTime, session.EndTime, req.Hours); err != nil {
+			return err
+		}
+		hours, err := sm.hours.Hours(session.StartTime, session.EndTime, req.Hours)
+		if err != nil {
+			return err
+		}
+		if err := addSessionAttendees(ctx, tx, *session, req.StudentIDs, hours, req.AllowOverlap); err != nil {
+			return err
+		}
+		result, err = getSession(ctx, tx, session.ID)
+		return err
+	})
+	if err != nil {
+		log.Error("failed to add class session attendees", logger.ErrorType(err))
+		return responsex.GetSessionResponse{}, err
+	}
+	return result, nil
+}
+
+// addSessionAttendees 为每名学生创建课次的待激活记录，重复的学生 ID 只创建一次
+func addSessionAttendees(ctx context.Context, tx *gorm.DB, session entity.ClassSession, studentIDs []uint, hours int, allowOverlap bool) error {
+	txStudentRepo := repository.NewStudentRepository(dao.NewStudentDao(tx))
+	txRecordRepo := repository.NewRecordRepository(dao.NewRecordDao(tx))
+
+	seen := make(map[uint]bool, len(studentIDs))
+	for _, id := range studentIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		student, err := txStudentRepo.GetStudentByID(ctx, id)
+		if err != nil {
+			return errorx.Wrap(errorx.KindNotFound, err, fmt.Sprintf("student [%d] not found", id))
+		}
+		record := entity.Record{
+			Student:      entity.Student{ID: student.ID, Name: student.Name},
+			Teacher:      session.Teacher,
+			TeachingDate: session.TeachingDate,
+			StartTime:    session.StartTime,
+			EndTime:      session.EndTime,
+			Hours:        hours,
+			Attendance:   dao.AttendanceAttended,
+			SessionID:    session.ID,
+		}
+		if !allowOverlap {
+			clash, err := findRecordOverlap(ctx, txRecordRepo, record)
+			if err != nil {
+				return err
+			}
+			if clash != nil {
+				return recordOverlapError(record, clash)
+			}
+		}
+		if err := txRecordRepo.CreateRecord(ctx, &record); err != nil {
+			if errors.Is(err, dao.ErrDuplicatedKey) {
+				return errorx.Wrap(errorx.KindConflict, err, fmt.Sprintf("duplicate: student %s already has a record at this time", student.Name))
+			}
+			return err
+		}
+		if err := writeAudit(ctx, tx, auditRecordCreate, auditEntityRecord, record.ID, nil, recordSnapshot(&record)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateSession 修改课次，并把日期、时间、教师与课时同步到每名学生的记录；已激活的记录按新旧课时的差额调整学生余额
+func (sm *SessionManager) UpdateSession(ctx context.Context, req *requestx.UpdateSessionRequest) (responsex.GetSessionResponse, error) {
+	log := logger.FromContext(ctx).With(logger.UInt("session_id", req.SessionID))
+	log.Info("Updating class session",
+		logger.UInt("teacher_id", req.TeacherID),
+		logger.String("teaching_date", req.TeachingDate),
+		logger.String("start_time", req.StartTime),
+		logger.String("end_time", req.EndTime),
+	)
+
+	teachingDate, err := parseRecordSchedule(req.TeachingDate, req.StartTime, req.EndTime)
+	if err != nil {
+		return responsex.GetSessionResponse{}, err
+	}
+	if err := sm.hours.checkExplicit(ctx, req.StartTime, req.EndTime, req.Hours); err != nil {
+		return responsex.GetSessionResponse{}, err
+	}
+
+	var result responsex.GetSessionResponse
+	err = dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txSessionRepo := repository.NewSessionRepository(dao.NewSessionDao(tx))
+		txRecordRepo := repository.NewRecordRepository(dao.NewRecordDao(tx))
+		txStudentRepo := repository.NewStudentRepository(dao.NewStudentDao(tx))
+
+		before, err := txSessionRepo.GetSessionByID(ctx, req.SessionID)
+		if err != nil {
+			return err
+		}
+		after := *before
+		if req.TeacherID != 0 && req.TeacherID != before.Teacher.ID {
+			t, err := repository.NewTeacherRepository(dao.NewTeacherDao(tx)).GetTeacherByID(ctx, req.TeacherID)
+			if err != nil {
+				return errorx.Wrap(errorx.KindNotFound, err, fmt.Sprintf("teacher [%d] not found", req.TeacherID))
+			}
+			after.Teacher = entity.Teacher{ID: t.ID, Name: t.Name}
+		}
+		after.TeachingDate = teachingDate
+		after.StartTime = req.StartTime
+		after.EndTime = req.EndTime
+		after.Title = req.Title
+		after.Remark = req.Remark
+		if err := txSessionRepo.UpdateSession(ctx, after); err != nil {
+			return err
+		}
+
+		records, err := txRecordRepo.GetRecordsOfSession(ctx, after.ID)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			updated := record
+			updated.Teacher = after.Teacher
+			updated.TeachingDate = after.TeachingDate
+			updated.StartTime = after.StartTime
+			updated.EndTime = after.EndTime
+			explicit := req.Hours
+			if explicit == nil && updated.StartTime == record.StartTime && updated.EndTime == record.EndTime {
+				explicit = &record.Hours
+			}
+			if updated.Hours, err = sm.hours.Hours(updated.StartTime, updated.EndTime, explicit); err != nil {
+				return err
+			}
+			if err := requireChargeIncrease(ctx, record, updated); err != nil {
+				return err
+			}
+
+			if !req.AllowOverlap {
+				clash, err := findRecordOverlap(ctx, txRecordRepo, updated)
+				if err != nil {
+					return err
+				}
+				if clash != nil {
+					return recordOverlapError(updated, clash)
+				}
+			}
+			if err := txRecordRepo.UpdateRecord(ctx, updated); err != nil {
+				return err
+			}
+			if record.Active {
+				student, err := txStudentRepo.GetStudentByIdWithDeleted(ctx, record.Student.ID)
+				if err != nil {
+					return err
+				}
+				if err := moveRecordHours(ctx, tx, txStudentRepo, student, chargedHours(record), student, chargedHours(updated)); err != nil {
+					return err
+				}
+			}
+			if err := writeAudit(ctx, tx, auditRecordUpdate, auditEntityRecord, record.ID, recordSnapshot(&record), recordSnapshot(&updated)); err != nil {
+				return err
+			}
+		}
+		if err := writeAudit(ctx, tx, auditSessionUpdate, auditEntitySession, after.ID, sessionSnapshot(before), sessionSnapshot(&after)); err != nil {
+			return err
+		}
+		result, err = getSession(ctx, tx, after.ID)
+		return err
+	})
+	if errors.Is(err, dao.ErrDuplicatedKey) {
+		return responsex.GetSessionResponse{}, errorx.Wrap(errorx.KindConflict, err, "duplicate: record already exists")
+	}
+	if err != nil {
+		log.Error("failed to update class session", logger.ErrorType(err))
+		return responsex.GetSessionResponse{}, err
+	}
+	return result, nil
+}
+
+// CancelSession 取消课次并删除所有学生的记录；有已激活的记录时拒绝，需先取消激活以退回课时
+func (sm *SessionManager) CancelSession(ctx context.Context, req *requestx.SessionIDRequest) (string, error) {
+	log := logger.FromContext(ctx).With(logger.UInt("session_id", req.SessionID))
+	log.Info("Cancelling class session")
+
+	err := dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txSessionRepo := repository.NewSessionRepository(dao.NewSessionDao(tx))
+		txRecordRepo := repository.NewRecordRepository(dao.NewRecordDao(tx))
+
+		session, err := txSessionRepo.GetSessionByID(ctx, req.SessionID)
+		if err != nil {
+			return err
+		}
+		records, err := txRecordRepo.GetRecordsOfSession(ctx, session.ID)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if record.Active {
+				return errorx.Conflict(fmt.Sprintf("session [%d] has activated record [%d] (student %s); deactivate it first",
+					session.ID, record.ID, record.Student.Name))
+			}
+		}
+		for i := range records {
+			if err := txRecordRepo.DeleteRecordByID(ctx, records[i].ID); err != nil {
+				return err
+			}
+			if err := writeAudit(ctx, tx, auditRecordDelete, auditEntityRecord, records[i].ID, recordSnapshot(&records[i]), nil); err != nil {
+				return err
+			}
+		}
+		if err := txSessionRepo.DeleteSession(ctx, session.ID); err != nil {
+			return err
+		}
+		session.Attendees = len(records)
+		return writeAudit(ctx, tx, auditSessionCancel, auditEntitySession, session.ID, sessionSnapshot(session), nil)
+	})
+	if err != nil {
+		log.Error("failed to cancel class session", logger.ErrorType(err))
+		return "", err
+	}
+	return "Session cancelled successfully", nil
+}
+
+// ActivateSession 激活课次所有待激活的学生记录，逐条返回处理结果，与批量激活记录的规则一致
+func (sm *SessionManager) ActivateSession(ctx context.Context, req *requestx.SessionIDRequest) (responsex.BatchActivateRecordsResponse, error) {
+	log := logger.FromContext(ctx).With(logger.UInt("session_id", req.SessionID))
+	log.Info("Activating class session")
+
+	var resp responsex.BatchActivateRecordsResponse
+	err := dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txStudentRepo := repository.NewStudentRepository(dao.NewStudentDao(tx))
+		txRecordRepo := repository.NewRecordRepository(dao.NewRecordDao(tx))
+
+		if _, err := repository.NewSessionRepository(dao.NewSessionDao(tx)).GetSessionByID(ctx, req.SessionID); err != nil {
+			return err
+		}
+		records, err := txRecordRepo.GetRecordsOfSession(ctx, req.SessionID)
+		if err != nil {
+			return err
+		}
+		resp.Results = make([]responsex.RecordBatchResultDTO, 0, len(records))
+		for _, record := range records {
+			result, err := batchActivateOne(ctx, record.ID, txRecordRepo, txStudentRepo, tx)
+			if err != nil {
+				return err
+			}
+			if result == responsex.RecordResultActivated {
+				resp.Activated++
+			}
+			resp.Results = append(resp.Results, responsex.RecordBatchResultDTO{RecordID: record.ID, Result: result})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error("failed to activate class session", logger.ErrorType(err))
+		return responsex.BatchActivateRecordsResponse{}, err
+	}
+	log.Info("Class session activated", logger.Int("activated", resp.Activated))
+	return resp, nil
+}
+
+func (sm *SessionManager) GetSession(ctx context.Context, req *requestx.SessionIDRequest) (responsex.GetSessionResponse, error) {
+	result, err := getSession(ctx, dao.GetDBFromContext(ctx), req.SessionID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get class session", logger.UInt("session_id", req.SessionID), logger.ErrorType(err))
+		return responsex.GetSessionResponse{}, err
+	}
+	return result, nil
+}
+
+// getSession 返回课次及其学生记录，人数按记录统计
+func getSession(ctx context.Context, db *gorm.DB, id uint) (responsex.GetSessionResponse, error) {
+	session, err := repository.NewSessionRepository(dao.NewSessionDao(db)).GetSessionByID(ctx, id)
+	if err != nil {
+		return responsex.GetSessionResponse{}, err
+	}
+	records, err := repository.NewRecordRepository(dao.NewRecordDao(db)).GetRecordsOfSession(ctx, id)
+	if err != nil {
+		return responsex.GetSessionResponse{}, err
+	}
+	attendees := make([]responsex.RecordDTO, 0, len(records))
+	for i := range records {
+		attendees = append(attendees, recordSnapshot(&records[i]))
+		session.Attendees++
+		if records[i].Active {
+			session.ActiveAttendees++
+		}
+	}
+	return responsex.GetSessionResponse{Session: sessionSnapshot(session), Attendees: attendees}, nil
+}
+
+func (sm *SessionManager) GetSessionList(ctx context.Context, req *requestx.GetSessionListRequest) (responsex.GetSessionListResponse, error) {
+	sessions, total, err := sm.repo.GetSessionList(ctx, req.TeacherKey, req.StartDate, req.EndDate, req.Offset, req.Limit)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get class session list", logger.ErrorType(err))
+		return responsex.GetSessionListResponse{}, err
+	}
+	result := make([]responsex.SessionDTO, 0, len(sessions))
+	for i := range sessions {
+		result = append(result, sessionSnapshot(&sessions[i]))
+	}
+	return responsex.GetSessionListResponse{Sessions: result, Total: total}, nil
+}
+
+func (sm *SessionManager) RegisterRoute(d *dispatcher.Dispatcher) {
+	dispatcher.RegisterTyped(d, "session_manager:create_session", sm.CreateSession)
+	dispatcher.RegisterTyped(d, "session_manager:add_attendees", sm.AddAttendees)
+	dispatcher.RegisterTyped(d, "session_manager:update_session", sm.UpdateSession)
+	dispatcher.RegisterTyped(d, "session_manager:cancel_session", sm.CancelSession)
+	dispatcher.RegisterTyped(d, "session_manager:activate_session", sm.ActivateSession)
+	dispatcher.RegisterTyped(d, "session_manager:get_session", sm.GetSession)
+	dispatcher.RegisterTyped(d, "session_manager:get_session_list", sm.GetSessionList)
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"teaching_manage/dao"
+	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/wraper"
+	"teaching_manage/repository"
+	requestx "teaching_manage/service/request"
+
+	"gorm.io/gorm"
+)
+
+func newTestSessionManager(t *testing.T, db *gorm.DB, rm *RecordManager) *SessionManager {
+	t.Helper()
+	return NewSessionManager(repository.NewSessionRepository(dao.NewSessionDao(db)), repository.NewRecordRepository(dao.NewRecordDao(db)), rm.hours)
+}
+
+// sessionRecordOf returns the student's record in a session, or a zero
+// record when the student has none.
+func sessionRecordOf(t *testing.T, db *gorm.DB, sessionID uint, studentID uint) dao.Record {
+	t.Helper()
+	var records []dao.Record
+	if err := db.Where("session_id = ? AND student_id = ?", sessionID, studentID).Find(&records).Error; err != nil {
+		t.Fatalf("find session record: %v", err)
+	}
+	if len(records) == 0 {
+		return dao.Record{}
+	}
+	return records[0]
+}
+
+func TestSessionAttendeeHours(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	rm := newTestRecordManager(t, db)
+	sm := newTestSessionManager(t, db, rm)
+	a := createTestStudent(t, db, "张三", "李老师")
+	b := createTestStudent(t, db, "李四", "王老师")
+	c := createTestStudent(t, db, "王五", "赵老师")
+	for _, s := range []dao.Student{a, b, c} {
+		setStudentHours(t, db, s.ID, 10)
+	}
+	created, err := sm.CreateSession(ctx, &requestx.CreateSessionRequest{
+		TeacherID: a.TeacherID, TeachingDate: "2026-03-02", StartTime: "10:00", EndTime: "11:00", StudentIDs: []uint{a.ID, b.ID},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	id := created.Session.ID
+	threeHours := 3
+	update := requestx.UpdateSessionRequest{SessionID: id, TeachingDate: "2026-03-02", StartTime: "10:00", EndTime: "11:00"}
+
+	// the steps run in order on the same session
+	tests := []struct {
+		name string
+		do   func() error
+		// wantErr is the response code of a failed step
+		wantErr int
+		// balances of students a, b and c
+		want []int
+		// wantHours is the record hours of a, b and c, 0 when the student has no record
+		wantHours []int
+		// wantAttendees and wantActive are the session's attendee counts
+		wantAttendees, wantActive int
+	}{
+		{
+			name: "activate session",
+			do: func() error {
+				_, err := sm.ActivateSession(ctx, &requestx.SessionIDRequest{SessionID: id})
+				return err
+			},
+			want: []int{8, 8, 10}, wantHours: []int{2, 2, 0}, wantAttendees: 2, wantActive: 2,
+		},
+		{
+			name: "add attendee to active session",
+			do: func() error {
+				_, err := sm.AddAttendees(ctx, &requestx.AddSessionAttendeesRequest{SessionID: id, StudentIDs: []uint{c.ID, c.ID}})
+				return err
+			},
+			want: []int{8, 8, 10}, wantHours: []int{2, 2, 2}, wantAttendees: 3, wantActive: 2,
+		},
+		{
+			name: "remove active attendee",
+			do: func() error {
+				_, err := rm.DeleteRecordByID(ctx, &requestx.DeleteRecordRequest{RecordID: sessionRecordOf(t, db, id, b.ID).ID})
+				return err
+			},
+			want: []int{8, 10, 10}, wantHours: []int{2, 0, 2}, wantAttendees: 2, wantActive: 1,
+		},
+		{
+			name: "raise hours",
+			do: func() error {
+				req := update
+				req.Hours = &threeHours
+				_, err := sm.UpdateSession(ctx, &req)
+				return err
+			},
+			want: []int{7, 10, 10}, wantHours: []int{3, 0, 3}, wantAttendees: 2, wantActive: 1,
+		},
+		{
+			name: "keep hours when only the title changes",
+			do: func() error {
+				req := update
+				req.Title = "钢琴小组"
+				_, err := sm.UpdateSession(ctx, &req)
+				return err
+			},
+			want: []int{7, 10, 10}, wantHours: []int{3, 0, 3}, wantAttendees: 2, wantActive: 1,
+		},
+		{
+			name: "new times recompute hours",
+			do: func() error {
+				req := update
+				req.EndTime = "12:00"
+				_, err := sm.UpdateSession(ctx, &req)
+				return err
+			},
+			want: []int{8, 10, 10}, wantHours: []int{2, 0, 2}, wantAttendees: 2, wantActive: 1,
+		},
+		{
+			name: "cancel with an active attendee",
+			do: func() error {
+				_, err := sm.CancelSession(ctx, &requestx.SessionIDRequest{SessionID: id})
+				return err
+			},
+			wantErr: wraper.CodeConflict,
+			want:    []int{8, 10, 10}, wantHours: []int{2, 0, 2}, wantAttendees: 2, wantActive: 1,
+		},
+		{
+			name: "deactivate last active attendee",
+			do: func() error {
+				_, err := rm.DeactivateRecord(ctx, &requestx.DeactivateRecordRequest{RecordID: sessionRecordOf(t, db, id, a.ID).ID, Reason: "取消课次"})
+				return err
+			},
+			want: []int{10, 10, 10}, wantHours: []int{2, 0, 2}, wantAttendees: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.do()
+			if tt.wantErr != 0 {
+				if got := dispatcher.CodeOf(err); got != tt.wantErr {
+					t.Fatalf("error = %v (code %d), want code %d", err, got, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			var balances, hours []int
+			for _, s := range []dao.Student{a, b, c} {
+				balances = append(balances, studentHours(t, db, s.ID))
+				hours = append(hours, sessionRecordOf(t, db, id, s.ID).Hours)
+			}
+			if !slices.Equal(balances, tt.want) {
+				t.Errorf("balances = %v, want %v", balances, tt.want)
+			}
+			if !slices.Equal(hours, tt.wantHours) {
+				t.Errorf("record hours = %v, want %v", hours, tt.wantHours)
+			}
+			session, err := sm.GetSession(ctx, &requestx.SessionIDRequest{SessionID: id})
+			if err != nil {
+				t.Fatalf("GetSession() error = %v", err)
+			}
+			if session.Session.Attendees != tt.wantAttendees || session.Session.ActiveAttendees != tt.wantActive {
+				t.Errorf("attendees = %d (%d active), want %d (%d active)",
+					session.Session.Attendees, session.Session.ActiveAttendees, tt.wantAttendees, tt.wantActive)
+			}
+		})
+	}
+
+	if _, err := sm.CancelSession(ctx, &requestx.SessionIDRequest{SessionID: id}); err != nil {
+		t.Fatalf("CancelSession() error = %v", err)
+	}
+	var left int64
+	if err := db.Model(&dao.Record{}).Where("session_id = ?", id).Count(&left).Error; err != nil {
+		t.Fatalf("count records: %v", err)
+	}
+	if left != 0 {
+		t.Errorf("cancelled session kept %d records", left)
+	}
+}
+
+func TestTeacherRankCountsSessions(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	rm := newTestRecordManager(t, db)
+	sm := newTestSessionManager(t, db, rm)
+	a := createTestStudent(t, db, "张三", "李老师")
+	b := createTestStudent(t, db, "李四", "王老师")
+	c := createTestStudent(t, db, "王五", "赵老师")
+	today := scheduleToday().Format("2006-01-02")
+
+	// a group lesson of three students, one of them on leave
+	group, err := sm.CreateSession(ctx, &requestx.CreateSessionRequest{
+		TeacherID: a.TeacherID, TeachingDate: today, StartTime: "10:00", EndTime: "11:00", StudentIDs: []uint{a.ID, b.ID, c.ID},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	if _, err := rm.UpdateRecord(ctx, &requestx.UpdateRecordRequest{
+		RecordID: sessionRecordOf(t, db, group.Session.ID, c.ID).ID, StudentID: c.ID,
+		TeachingDate: today, StartTime: "10:00", EndTime: "11:00", Attendance: dao.AttendanceLeave,
+	}); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if _, err := sm.ActivateSession(ctx, &requestx.SessionIDRequest{SessionID: group.Session.ID}); err != nil {
+		t.Fatalf("ActivateSession() error = %v", err)
+	}
+	// a one-to-one lesson of the same teacher, and a pending one that does not count
+	single := createTestRecord(t, db, rm, requestx.CreateRecordRequest{StudentID: a.ID, TeachingDate: today, StartTime: "14:00", EndTime: "15:00"})
+	if _, err := rm.ActivateRecord(ctx, &requestx.ActivateRecordRequest{RecordID: single}); err != nil {
+		t.Fatalf("ActivateRecord() error = %v", err)
+	}
+	createTestRecord(t, db, rm, requestx.CreateRecordRequest{StudentID: a.ID, TeachingDate: today, StartTime: "16:00", EndTime: "17:00"})
+
+	rank, err := NewDashboardManager().GetTeacherRankData(ctx)
+	if err != nil {
+		t.Fatalf("GetTeacherRankData() error = %v", err)
+	}
+	if !slices.Equal(rank.Names, []string{"李老师"}) {
+		t.Fatalf("Names = %v, want [李老师]", rank.Names)
+	}
+	// two attendees of the group lesson and the one-to-one lesson, 2 hours each
+	if rank.Values[0] != 6 {
+		t.Errorf("Values = %v, want [6] attendee hours", rank.Values)
+	}
+	if rank.Sessions[0] != 2 {
+		t.Errorf("Sessions = %v, want [2] lessons", rank.Sessions)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"teaching_manage/entity"
 	"teaching_manage/pkg"
 	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/errorx"
 	"teaching_manage/pkg/logger"
 	"teaching_manage/repository"
 	requestx "teaching_manage/service/request"
@@ -15,8 +16,12 @@ import (
 	"time"
 
 	wails "github.com/wailsapp/wails/v2/pkg/runtime"
+	"gorm.io/gorm"
 )
 
+// studentOpeningComment 为新建学生时初始课时订单的备注
+const studentOpeningComment = "初始课时"
+
 type StudentManager struct {
 	Ctx   context.Context
 	repo  repository.StudentRepository
@@ -56,7 +61,7 @@ func (sm StudentManager) GetStudentList(ctx context.Context, req *requestx.GetSt
 }
 
 func (sm StudentManager) CreateStudent(ctx context.Context, req *requestx.CreateStudentRequest) (string, error) {
-	logger.Info("Creating one student",
+	logger.InfoContext(ctx, "Creating one student",
 		logger.String("student_name", req.Name),
 		logger.String("phone", req.Phone),
 		logger.Int("hours", req.Hours),
@@ -64,40 +69,97 @@ func (sm StudentManager) CreateStudent(ctx context.Context, req *requestx.Create
 		logger.String("remark", req.Remark),
 	)
 
-	err := sm.repo.CreateStudent(ctx, &entity.Student{
+	created := entity.Student{
 		Name:      req.Name,
 		Gender:    req.Gender,
 		Hours:     req.Hours,
 		Phone:     req.Phone,
 		TeacherID: req.TeacherID,
 		Remark:    req.Remark,
+	}
+	err := dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := repository.NewStudentRepository(dao.NewStudentDao(tx)).CreateStudent(ctx, &created); err != nil {
+			return err
+		}
+		if err := writeAudit(ctx, tx, auditStudentCreate, auditEntityStudent, created.ID, nil, studentSnapshot(&created)); err != nil {
+			return err
+		}
+		if req.Hours == 0 {
+			return nil
+		}
+		// 初始课时记为一条订单，使课时流水与余额一致；余额已在创建学生时写入，这里不再增加
+		order := entity.Order{
+			Student: entity.Student{ID: created.ID},
+			Hours:   req.Hours,
+			Comment: studentOpeningComment,
+			Active:  true,
+		}
+		if err := repository.NewOrderRepository(dao.NewOrderDao(tx)).CreateOrder(ctx, &order); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, auditOrderCreate, auditEntityOrder, order.Id, nil, orderSnapshot(&order))
 	})
 
 	if errors.Is(err, dao.ErrDuplicatedKey) {
-		logger.Error("duplicate student name", logger.String("student_name", req.Name))
-		return "", fmt.Errorf("duplicate : student name [%s] already exists", req.Name)
+		logger.ErrorContext(ctx, "duplicate student name", logger.String("student_name", req.Name))
+		return "", errorx.Conflict(fmt.Sprintf("duplicate : student name [%s] already exists", req.Name))
 	}
 
 	if err != nil {
-		logger.Error("failed to create student", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "failed to create student", logger.ErrorType(err))
 		return "", fmt.Errorf("failed to create student: %w", err)
 	}
 	return "student created", nil
 }
 
 func (sm StudentManager) UpdateStudent(ctx context.Context, req *requestx.UpdateStudentRequest) (string, error) {
-	return "updated successfully", sm.repo.UpdateStudentByID(ctx, &entity.Student{
-		ID:        req.ID,
-		Name:      req.Name,
-		Gender:    req.Gender,
-		Phone:     req.Phone,
-		TeacherID: req.TeacherID,
-		Remark:    req.Remark,
+	err := dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txRepo := repository.NewStudentRepository(dao.NewStudentDao(tx))
+		before, err := txRepo.GetStudentByID(ctx, req.ID)
+		if err != nil {
+			return err
+		}
+		err = txRepo.UpdateStudentByID(ctx, &entity.Student{
+			ID:        req.ID,
+			Name:      req.Name,
+			Gender:    req.Gender,
+			Phone:     req.Phone,
+			TeacherID: req.TeacherID,
+			Remark:    req.Remark,
+		})
+		if err != nil {
+			return err
+		}
+		after, err := txRepo.GetStudentByID(ctx, req.ID)
+		if err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, auditStudentUpdate, auditEntityStudent, req.ID, studentSnapshot(before), studentSnapshot(after))
 	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to update student", logger.UInt("student_id", req.ID), logger.ErrorType(err))
+		return "", err
+	}
+	return "updated successfully", nil
 }
 
 func (sm StudentManager) DeleteStudent(ctx context.Context, req *requestx.DeleteStudentRequest) (string, error) {
-	return "deleted successfully", sm.repo.DeleteStudentByID(ctx, req.ID)
+	err := dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txRepo := repository.NewStudentRepository(dao.NewStudentDao(tx))
+		before, err := txRepo.GetStudentByID(ctx, req.ID)
+		if err != nil {
+			return err
+		}
+		if err := txRepo.DeleteStudentByID(ctx, req.ID); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, auditStudentDelete, auditEntityStudent, req.ID, studentSnapshot(before), nil)
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to delete student", logger.UInt("student_id", req.ID), logger.ErrorType(err))
+		return "", err
+	}
+	return "deleted successfully", nil
 }
 
 func (sm StudentManager) Export2Excel(ctx context.Context) (string, error) {
@@ -165,5 +227,5 @@ func (sm StudentManager) RegisterRoute(d *dispatcher.Dispatcher) {
 	dispatcher.RegisterTyped(d, "student_manager:create_student", sm.CreateStudent)
 	dispatcher.RegisterTyped(d, "student_manager:update_student", sm.UpdateStudent)
 	dispatcher.RegisterTyped(d, "student_manager:delete_student", sm.DeleteStudent)
-	dispatcher.RegisterNoReq(d, "student_manager:export_students", sm.Export2Excel)
+	dispatcher.RegisterNoReq(d, "student_manager:export_students", sm.Export2Excel, dispatcher.DesktopOnly())
 }
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"teaching_manage/dao"
+	"teaching_manage/pkg/config"
+	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/logger"
+	requestx "teaching_manage/service/request"
+	responsex "teaching_manage/service/response"
+	"time"
+)
+
+// SystemManager 负责数据库的备份、定时快照与恢复
+type SystemManager struct {
+	Ctx    context.Context
+	dbPath string
+	cfg    config.BackupConfig
+	// mu 串行化备份与恢复，避免恢复过程中写出半新半旧的快照
+	mu sync.Mutex
+}
+
+func NewSystemManager(dbPath string, cfg config.BackupConfig) *SystemManager {
+	return &SystemManager{dbPath: dbPath, cfg: cfg}
+}
+
+// BackupNow 立即生成一份快照，并按保留数量清理旧快照
+func (sm *SystemManager) BackupNow(ctx context.Context) (responsex.BackupDTO, error) {
+	// VACUUM INTO 不能在事务中执行
+	if dao.HasTx(ctx) {
+		return responsex.BackupDTO{}, errorx.Validation("backup cannot run inside a transaction")
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.snapshot(ctx)
+}
+
+func (sm *SystemManager) snapshot(ctx context.Context) (responsex.BackupDTO, error) {
+	name := dao.BackupFileName(sm.dbPath, dao.BackupKindSnapshot, time.Now())
+	dest := filepath.Join(sm.cfg.Dir, name)
+	logger.InfoContext(ctx, "Creating database snapshot", logger.String("path", dest))
+	if err := dao.Backup(dao.GetDBFromContext(ctx), dest); err != nil {
+		logger.ErrorContext(ctx, "failed to create database snapshot", logger.ErrorType(err))
+		return responsex.BackupDTO{}, err
+	}
+	sm.prune(ctx)
+	return sm.backupInfo(name)
+}
+
+// prune 只清理快照，迁移前与恢复前的备份需要人工删除
+func (sm *SystemManager) prune(ctx context.Context) {
+	if sm.cfg.Keep <= 0 {
+		return
+	}
+	backups, err := sm.listBackups()
+	if err != nil {
+		logger.WarnContext(ctx, "failed to list backups for pruning", logger.ErrorType(err))
+		return
+	}
+	kept := 0
+	for _, b := range backups {
+		if b.Kind != dao.BackupKindSnapshot {
+			continue
+		}
+		kept++
+		if kept <= sm.cfg.Keep {
+			continue
+		}
+		if err := os.Remove(filepath.Join(sm.cfg.Dir, b.Name)); err != nil {
+			logger.WarnContext(ctx, "failed to remove old snapshot", logger.String("name", b.Name), logger.ErrorType(err))
+			continue
+		}
+		logger.InfoContext(ctx, "Removed old snapshot", logger.String("name", b.Name))
+	}
+}
+
+func (sm *SystemManager) ListBackups(ctx context.Context) ([]responsex.BackupDTO, error) {
+	return sm.listBackups()
+}
+
+// listBackups 返回备份目录中的全部备份，按时间从新到旧排列
+func (sm *SystemManager) listBackups() ([]responsex.BackupDTO, error) {
+	entries, err := os.ReadDir(sm.cfg.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []responsex.BackupDTO{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	backups := make([]responsex.BackupDTO, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		b, err := sm.backupInfo(e.Name())
+		if err != nil {
+			continue
+		}
+		backups = append(backups, b)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt > backups[j].CreatedAt })
+	return backups, nil
+}
+
+func (sm *SystemManager) backupInfo(name string) (responsex.BackupDTO, error) {
+	kind, createdAt, ok := dao.ParseBackupFileName(sm.dbPath, name)
+	if !ok {
+		return responsex.BackupDTO{}, errorx.NotFound(fmt.Sprintf("backup [%s] not found", name))
+	}
+	info, err := os.Stat(filepath.Join(sm.cfg.Dir, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return responsex.BackupDTO{}, errorx.NotFound(fmt.Sprintf("backup [%s] not found", name))
+		}
+		return responsex.BackupDTO{}, err
+	}
+	return responsex.BackupDTO{
+		Name:      name,
+		Kind:      kind,
+		Size:      info.Size(),
+		CreatedAt: createdAt.UnixMilli(),
+	}, nil
+}
+
+// RestoreBackup 校验备份完整性后，先为当前数据库做一份恢复前备份，再用备份内容替换当前数据库；
+// 备份版本较旧时恢复后会执行迁移。登录账号不随备份回退：恢复后从恢复前备份中还原 users 表，
+// 以免找回已停用的账号、旧密码，或使当前管理员无法登录。
+func (sm *SystemManager) RestoreBackup(ctx context.Context, req *requestx.RestoreBackupRequest) (responsex.RestoreBackupResponse, error) {
+	log := logger.FromContext(ctx).With(logger.String("name", req.Name))
+	log.Info("Restoring database backup")
+
+	// 恢复需要独占数据库连接，不能在批量调用的事务中执行
+	if dao.HasTx(ctx) {
+		return responsex.RestoreBackupResponse{}, errorx.Validation("restore cannot run inside a transaction")
+	}
+	// 只允许恢复备份目录中的文件
+	if filepath.Base(req.Name) != req.Name {
+		return responsex.RestoreBackupResponse{}, errorx.Validation("invalid backup name")
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	restored, err := sm.backupInfo(req.Name)
+	if err != nil {
+		return responsex.RestoreBackupResponse{}, err
+	}
+	src := filepath.Join(sm.cfg.Dir, req.Name)
+	version, err := dao.CheckBackup(ctx, src)
+	if err != nil {
+		log.Warn("backup failed verification", logger.ErrorType(err))
+		if errors.Is(err, dao.ErrSchemaTooNew) {
+			return responsex.RestoreBackupResponse{}, errorx.Wrap(errorx.KindConflict, err, "backup was created by a newer version")
+		}
+		return responsex.RestoreBackupResponse{}, errorx.Wrap(errorx.KindValidation, err, fmt.Sprintf("backup [%s] is damaged or invalid", req.Name))
+	}
+
+	db := dao.GetDB()
+	safetyName := dao.BackupFileName(sm.dbPath, dao.BackupKindPreRestore, time.Now())
+	if err := dao.Backup(db, filepath.Join(sm.cfg.Dir, safetyName)); err != nil {
+		log.Error("failed to back up current database before restore", logger.ErrorType(err))
+		return responsex.RestoreBackupResponse{}, err
+	}
+	safety, err := sm.backupInfo(safetyName)
+	if err != nil {
+		return responsex.RestoreBackupResponse{}, err
+	}
+
+	if err := dao.Restore(ctx, db, src); err != nil {
+		log.Error("failed to restore database", logger.ErrorType(err))
+		return responsex.RestoreBackupResponse{}, err
+	}
+	if err := dao.Migrate(db, sm.dbPath, sm.cfg.Dir); err != nil {
+		log.Error("failed to migrate restored database", logger.ErrorType(err))
+		return responsex.RestoreBackupResponse{}, err
+	}
+	if err := dao.ReplaceTableFrom(ctx, db, filepath.Join(sm.cfg.Dir, safetyName), "users"); err != nil {
+		log.Error("failed to keep current users after restore", logger.ErrorType(err))
+		return responsex.RestoreBackupResponse{}, fmt.Errorf("restored database still has the users of the backup, "+
+			"restore [%s] to undo: %w", safetyName, err)
+	}
+
+	log.Info("database restored", logger.Int("backup_version", version), logger.String("safety_backup", safetyName))
+	return responsex.RestoreBackupResponse{Restored: restored, SafetyBackup: safety, Version: version}, nil
+}
+
+// RunSchedule 按配置的间隔生成快照，直到 ctx 结束。
+// 桌面程序不会一直运行，因此启动时若距上次快照已超过间隔会先补做一次。
+func (sm *SystemManager) RunSchedule(ctx context.Context) {
+	if sm.cfg.IntervalHours <= 0 {
+		logger.Info("scheduled backups disabled")
+		return
+	}
+	interval := time.Duration(sm.cfg.IntervalHours) * time.Hour
+
+	wait := time.Duration(0)
+	if backups, err := sm.listBackups(); err == nil {
+		for _, b := range backups {
+			if b.Kind == dao.BackupKindSnapshot {
+				wait = max(0, interval-time.Since(time.UnixMilli(b.CreatedAt)))
+				break
+			}
+		}
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			if _, err := sm.BackupNow(ctx); err != nil {
+				logger.Error("scheduled backup failed", logger.ErrorType(err))
+			}
+			timer.Reset(interval)
+		}
+	}
+}
+
+func (sm *SystemManager) RegisterRoute(d *dispatcher.Dispatcher) {
+	dispatcher.RegisterNoReq(d, "system:backup_now", sm.BackupNow)
+	dispatcher.RegisterNoReq(d, "system:list_backups", sm.ListBackups)
+	dispatcher.RegisterTyped(d, "system:restore_backup", sm.RestoreBackup)
+}
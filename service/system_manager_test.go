@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"teaching_manage/dao"
+	"teaching_manage/pkg/config"
+	requestx "teaching_manage/service/request"
+)
+
+func TestRestoreBackupKeepsUsers(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+	backupDir := filepath.Join(dir, "backups")
+	if err := dao.InitDB(dbPath, backupDir); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	db := dao.GetDB()
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	sm := NewSystemManager(dbPath, config.BackupConfig{Dir: backupDir})
+
+	admin := dao.User{Username: "admin", PasswordHash: "old-hash", Role: "admin"}
+	if err := db.Create(&admin).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	createTestStudent(t, db, "张三", "王老师")
+	backup, err := sm.BackupNow(ctx)
+	if err != nil {
+		t.Fatalf("BackupNow() error = %v", err)
+	}
+
+	// 备份之后：新增学生与账号，修改密码并停用旧账号
+	createTestStudent(t, db, "李四", "赵老师")
+	if err := db.Model(&admin).Updates(map[string]any{"password_hash": "new-hash", "disabled": true}).Error; err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+	clerk := dao.User{Username: "clerk", PasswordHash: "clerk-hash", Role: "front_desk"}
+	if err := db.Create(&clerk).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	if _, err := sm.RestoreBackup(ctx, &requestx.RestoreBackupRequest{Name: backup.Name}); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+
+	var students []dao.Student
+	if err := db.Order("id").Find(&students).Error; err != nil {
+		t.Fatalf("list students: %v", err)
+	}
+	if len(students) != 1 || students[0].Name != "张三" {
+		t.Fatalf("students after restore = %v, want only 张三", students)
+	}
+
+	var users []dao.User
+	if err := db.Order("id").Find(&users).Error; err != nil {
+		t.Fatalf("list users: %v", err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("%d users after restore, want 2", len(users))
+	}
+	if users[0].ID != admin.ID || users[0].PasswordHash != "new-hash" || !users[0].Disabled {
+		t.Fatalf("admin after restore = %+v, want the current password and disabled flag", users[0])
+	}
+	if users[1].ID != clerk.ID || users[1].Username != "clerk" {
+		t.Fatalf("user created after the backup = %+v, want clerk with id %d", users[1], clerk.ID)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"teaching_manage/entity"
 	"teaching_manage/pkg"
 	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/errorx"
 	"teaching_manage/pkg/logger"
 	"teaching_manage/repository"
 	requestx "teaching_manage/service/request"
@@ -16,6 +17,7 @@ import (
 	"time"
 
 	wails "github.com/wailsapp/wails/v2/pkg/runtime"
+	"gorm.io/gorm"
 )
 
 type TeacherManager struct {
@@ -28,26 +30,32 @@ func NewTeacherManager(repo repository.TeacherRepository) *TeacherManager {
 }
 
 func (tm TeacherManager) CreateTeacher(ctx context.Context, teacher *requestx.CreateTeacherRequest) (string, error) {
-	logger.Info("Creating one teacher",
+	logger.InfoContext(ctx, "Creating one teacher",
 		logger.String("teacher_name", teacher.Name),
 		logger.String("phone", teacher.Phone),
 		logger.String("remark", teacher.Remark),
 	)
 
-	err := tm.repo.CreateTeacher(ctx, entity.Teacher{
+	created := entity.Teacher{
 		Name:   strings.TrimSpace(teacher.Name),
 		Phone:  strings.TrimSpace(teacher.Phone),
 		Gender: pkg.Gender(teacher.Gender),
 		Remark: strings.TrimSpace(teacher.Remark),
+	}
+	err := dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := repository.NewTeacherRepository(dao.NewTeacherDao(tx)).CreateTeacher(ctx, &created); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, auditTeacherCreate, auditEntityTeacher, created.ID, nil, teacherSnapshot(&created))
 	})
 
 	if errors.Is(err, dao.ErrDuplicatedKey) {
-		logger.Error("duplicate teacher name", logger.String("teacher_name", teacher.Name))
-		return "", fmt.Errorf("duplicate: teacher name [%s] already exists", teacher.Name)
+		logger.ErrorContext(ctx, "duplicate teacher name", logger.String("teacher_name", teacher.Name))
+		return "", errorx.Conflict(fmt.Sprintf("duplicate: teacher name [%s] already exists", teacher.Name))
 	}
 
 	if err != nil {
-		logger.Error("failed to create teacher", logger.ErrorType(err))
+		logger.ErrorContext(ctx, "failed to create teacher", logger.ErrorType(err))
 		return "", fmt.Errorf("failed to create teacher: %w", err)
 	}
 	return "teacher created", nil
@@ -57,7 +65,7 @@ func (tm TeacherManager) GetTeacherList(ctx context.Context, req *requestx.GetTe
 
 	teachers, total, err := tm.repo.GetTeacherList(ctx, req.Key, req.Offset, req.Limit)
 	if err != nil {
-		return responsex.GetTeacherListResponse{}, fmt.Errorf("internal server error")
+		return responsex.GetTeacherListResponse{}, errorx.Internal(err, "internal server error")
 	}
 
 	teacherDtos := make([]responsex.TeacherDTO, len(teachers))
@@ -79,7 +87,19 @@ func (tm TeacherManager) GetTeacherList(ctx context.Context, req *requestx.GetTe
 }
 
 func (tm TeacherManager) DeleteTeacher(ctx context.Context, req *requestx.DeleteTeacherRequest) (string, error) {
-	if err := tm.repo.DeleteTeacher(ctx, req.Id); err != nil {
+	err := dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txRepo := repository.NewTeacherRepository(dao.NewTeacherDao(tx))
+		before, err := txRepo.GetTeacherByID(ctx, req.Id)
+		if err != nil {
+			return err
+		}
+		if err := txRepo.DeleteTeacher(ctx, req.Id); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, auditTeacherDelete, auditEntityTeacher, req.Id, teacherSnapshot(before), nil)
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to delete teacher", logger.UInt("teacher_id", req.Id), logger.ErrorType(err))
 		return "", err
 	}
 	return "teacher deleted", nil
@@ -93,7 +113,23 @@ func (tm TeacherManager) UpdateTeacher(ctx context.Context, req *requestx.Update
 		Gender: pkg.Gender(req.Gender),
 		Remark: req.Remark,
 	}
-	if err := tm.repo.UpdateTeacher(ctx, teacher); err != nil {
+	err := dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txRepo := repository.NewTeacherRepository(dao.NewTeacherDao(tx))
+		before, err := txRepo.GetTeacherByID(ctx, req.Id)
+		if err != nil {
+			return err
+		}
+		if err := txRepo.UpdateTeacher(ctx, teacher); err != nil {
+			return err
+		}
+		after, err := txRepo.GetTeacherByID(ctx, req.Id)
+		if err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, auditTeacherUpdate, auditEntityTeacher, req.Id, teacherSnapshot(before), teacherSnapshot(after))
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to update teacher", logger.UInt("teacher_id", req.Id), logger.ErrorType(err))
 		return "", err
 	}
 	return "teacher updated", nil
@@ -145,5 +181,5 @@ func (tm TeacherManager) RegisterRoute(d *dispatcher.Dispatcher) {
 	dispatcher.RegisterTyped(d, "teacher_manager:get_teacher_list", tm.GetTeacherList)
 	dispatcher.RegisterTyped(d, "teacher_manager:delete_teacher", tm.DeleteTeacher)
 	dispatcher.RegisterTyped(d, "teacher_manager:update_teacher", tm.UpdateTeacher)
-	dispatcher.RegisterNoReq(d, "teacher_manager:export_teacher_to_excel", tm.ExportTeacher2Excel)
+	dispatcher.RegisterNoReq(d, "teacher_manager:export_teacher_to_excel", tm.ExportTeacher2Excel, dispatcher.DesktopOnly())
 }
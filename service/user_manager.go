@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"teaching_manage/dao"
+	"teaching_manage/entity"
+	"teaching_manage/pkg/auth"
+	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/pkg/logger"
+	"teaching_manage/repository"
+	requestx "teaching_manage/service/request"
+	responsex "teaching_manage/service/response"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// sessionTTL 为登录会话在无操作后的有效期
+const sessionTTL = 12 * time.Hour
+
+// 同一用户名在同一地址 loginFailureWindow 内登录失败 loginMaxFailures 次后，锁定 loginLockout
+const (
+	loginMaxFailures   = 5
+	loginFailureWindow = 15 * time.Minute
+	loginLockout       = 15 * time.Minute
+)
+
+// setupPrincipal 为还没有任何账号时桌面窗口使用的临时管理员，只用于创建第一个管理员
+var setupPrincipal = auth.Principal{Username: "setup", Role: RoleAdmin}
+
+// UserManager 负责账号管理与登录，同时作为 dispatcher 的 auth.Authenticator。
+// HTTP 调用通过 Authorization 头携带会话 token；桌面窗口只有一个用户，会话保存在服务端。
+type UserManager struct {
+	Ctx      context.Context
+	repo     repository.UserRepository
+	sessions *auth.Sessions
+	throttle *auth.Throttle
+
+	mu           sync.Mutex
+	desktopToken string
+}
+
+func NewUserManager(repo repository.UserRepository) *UserManager {
+	return &UserManager{
+		repo:     repo,
+		sessions: auth.NewSessions(sessionTTL),
+		throttle: auth.NewThrottle(loginMaxFailures, loginFailureWindow, loginLockout),
+	}
+}
+
+// Authenticate 实现 auth.Authenticator
+func (um *UserManager) Authenticate(ctx context.Context, caller dispatcher.Caller) (auth.Principal, error) {
+	token := caller.Token
+	if caller.Transport == dispatcher.TransportDesktop {
+		token = um.getDesktopToken()
+	}
+	if token != "" {
+		if p, ok := um.sessions.Get(token); ok {
+			return p, nil
+		}
+	}
+
+	// 首次使用时还没有账号，允许桌面窗口以临时管理员身份创建账号；HTTP 调用始终需要登录
+	if caller.Transport == dispatcher.TransportDesktop {
+		setup, err := um.inSetup(ctx)
+		if err != nil {
+			return auth.Principal{}, err
+		}
+		if setup {
+			return setupPrincipal, nil
+		}
+	}
+	return auth.Principal{}, auth.ErrUnauthenticated
+}
+
+// inSetup 判断是否还没有可以登录的账号
+func (um *UserManager) inSetup(ctx context.Context) (bool, error) {
+	n, err := um.repo.CountActiveUsers(ctx, "")
+	if err != nil {
+		return false, err
+	}
+	return n == 0, nil
+}
+
+func (um *UserManager) getDesktopToken() string {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+	return um.desktopToken
+}
+
+func (um *UserManager) setDesktopToken(token string) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+	if um.desktopToken != "" {
+		um.sessions.Revoke(um.desktopToken)
+	}
+	um.desktopToken = token
+}
+
+func (um *UserManager) Login(ctx context.Context, req *requestx.LoginRequest) (responsex.LoginResponse, error) {
+	log := logger.FromContext(ctx).With(logger.String("username", req.Username),
+		logger.String("transport", string(dispatcher.TransportOf(ctx))))
+
+	username := strings.TrimSpace(req.Username)
+	key := loginThrottleKey(ctx, username)
+	if wait, ok := um.throttle.Allow(key); !ok {
+		log.Warn("login throttled", logger.String("retry_after", wait.Round(time.Second).String()))
+		return responsex.LoginResponse{}, errorx.Unauthenticated(
+			fmt.Sprintf("too many failed logins, try again in %d minutes", int(wait.Minutes())+1))
+	}
+
+	user, err := um.repo.GetUserByUsername(ctx, username)
+	if err != nil && !errors.Is(err, dao.ErrRecordNotFound) {
+		log.Error("failed to load user", logger.ErrorType(err))
+		return responsex.LoginResponse{}, err
+	}
+	// 用户名不存在、密码错误与账号停用返回相同的错误，避免泄露账号是否存在
+	if user == nil || user.Disabled || !auth.CheckPassword(user.PasswordHash, req.Password) {
+		um.throttle.Fail(key)
+		log.Warn("login failed")
+		return responsex.LoginResponse{}, errorx.Unauthenticated("invalid username or password")
+	}
+	um.throttle.Reset(key)
+
+	token, err := um.sessions.Create(auth.Principal{UserID: user.ID, Username: user.Username, Role: user.Role})
+	if err != nil {
+		return responsex.LoginResponse{}, err
+	}
+	if dispatcher.TransportOf(ctx) == dispatcher.TransportDesktop {
+		um.setDesktopToken(token)
+	}
+	log.Info("user logged in", logger.UInt("user_id", user.ID))
+	return responsex.LoginResponse{Token: token, User: userSnapshot(user)}, nil
+}
+
+// loginThrottleKey 按用户名与调用方地址限制登录失败次数：网络上的尝试不会锁住桌面窗口的登录
+func loginThrottleKey(ctx context.Context, username string) string {
+	caller := dispatcher.CallerOf(ctx)
+	host := caller.Addr
+	if h, _, err := net.SplitHostPort(caller.Addr); err == nil {
+		host = h
+	}
+	return fmt.Sprintf("%s|%s|%s", caller.Transport, host, strings.ToLower(username))
+}
+
+// currentToken 返回 ctx 中调用方的会话 token
+func (um *UserManager) currentToken(ctx context.Context) string {
+	caller := dispatcher.CallerOf(ctx)
+	if caller.Transport == dispatcher.TransportDesktop {
+		return um.getDesktopToken()
+	}
+	return caller.Token
+}
+
+func (um *UserManager) Logout(ctx context.Context) (string, error) {
+	caller := dispatcher.CallerOf(ctx)
+	if caller.Transport == dispatcher.TransportDesktop {
+		um.setDesktopToken("")
+	} else {
+		um.sessions.Revoke(caller.Token)
+	}
+	return "logged out", nil
+}
+
+func (um *UserManager) CurrentUser(ctx context.Context) (responsex.CurrentUserResponse, error) {
+	p, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return responsex.CurrentUserResponse{}, auth.ErrUnauthenticated
+	}
+	if p == setupPrincipal {
+		return responsex.CurrentUserResponse{User: responsex.UserDTO{Username: p.Username, Role: p.Role}, Setup: true}, nil
+	}
+	user, err := um.repo.GetUserByID(ctx, p.UserID)
+	if err != nil {
+		return responsex.CurrentUserResponse{}, err
+	}
+	return responsex.CurrentUserResponse{User: userSnapshot(user)}, nil
+}
+
+func (um *UserManager) ChangePassword(ctx context.Context, req *requestx.ChangePasswordRequest) (string, error) {
+	p, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return "", auth.ErrUnauthenticated
+	}
+	if p.UserID == 0 {
+		return "", errorx.Validation("create an admin account first")
+	}
+	err := dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txRepo := repository.NewUserRepository(dao.NewUserDao(tx))
+		user, err := txRepo.GetUserByID(ctx, p.UserID)
+		if err != nil {
+			return err
+		}
+		if !auth.CheckPassword(user.PasswordHash, req.OldPassword) {
+			return errorx.Validation("old password is incorrect")
+		}
+		hash, err := auth.HashPassword(req.NewPassword)
+		if err != nil {
+			return err
+		}
+		if err := txRepo.UpdatePassword(ctx, user.ID, hash); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, auditUserChangePassword, auditEntityUser, user.ID, nil, nil)
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to change password", logger.UInt("user_id", p.UserID), logger.ErrorType(err))
+		return "", err
+	}
+	// 其他设备上的会话可能来自泄露的旧密码，只保留当前会话
+	um.sessions.RevokeOthers(p.UserID, um.currentToken(ctx))
+	return "password changed", nil
+}
+
+func (um *UserManager) CreateUser(ctx context.Context, req *requestx.CreateUserRequest) (responsex.UserDTO, error) {
+	log := logger.FromContext(ctx).With(logger.String("username", req.Username), logger.String("role", req.Role))
+	log.Info("Creating user")
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		return responsex.UserDTO{}, err
+	}
+	user := entity.User{
+		Username:     strings.TrimSpace(req.Username),
+		PasswordHash: hash,
+		DisplayName:  strings.TrimSpace(req.DisplayName),
+		Role:         req.Role,
+	}
+	err = dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txRepo := repository.NewUserRepository(dao.NewUserDao(tx))
+		// 第一个账号必须是管理员，否则没有人能管理账号
+		n, err := txRepo.CountActiveUsers(ctx, "")
+		if err != nil {
+			return err
+		}
+		if n == 0 && user.Role != RoleAdmin {
+			return errorx.Validation("the first user must be an admin")
+		}
+		if err := txRepo.CreateUser(ctx, &user); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, auditUserCreate, auditEntityUser, user.ID, nil, userSnapshot(&user))
+	})
+	if errors.Is(err, dao.ErrDuplicatedKey) {
+		return responsex.UserDTO{}, errorx.Conflict(fmt.Sprintf("duplicate: username [%s] already exists", user.Username))
+	}
+	if err != nil {
+		log.Error("failed to create user", logger.ErrorType(err))
+		return responsex.UserDTO{}, err
+	}
+	return userSnapshot(&user), nil
+}
+
+func (um *UserManager) GetUserList(ctx context.Context, req *requestx.GetUserListRequest) (responsex.GetUserListResponse, error) {
+	users, total, err := um.repo.GetUserList(ctx, req.Offset, req.Limit)
+	if err != nil {
+		return responsex.GetUserListResponse{}, errorx.Internal(err, "internal server error")
+	}
+	dtos := make([]responsex.UserDTO, 0, len(users))
+	for i := range users {
+		dtos = append(dtos, userSnapshot(&users[i]))
+	}
+	return responsex.GetUserListResponse{Users: dtos, Total: total}, nil
+}
+
+// UpdateUser 修改账号的显示名称、角色与停用状态；角色变化或停用后该账号需要重新登录
+func (um *UserManager) UpdateUser(ctx context.Context, req *requestx.UpdateUserRequest) (string, error) {
+	log := logger.FromContext(ctx).With(logger.UInt("user_id", req.ID))
+	log.Info("Updating user", logger.String("role", req.Role), logger.Bool("disabled", req.Disabled))
+
+	var revoke bool
+	err := dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txRepo := repository.NewUserRepository(dao.NewUserDao(tx))
+		before, err := txRepo.GetUserByID(ctx, req.ID)
+		if err != nil {
+			if errors.Is(err, dao.ErrRecordNotFound) {
+				return errorx.NotFound(fmt.Sprintf("user [%d] not found", req.ID))
+			}
+			return err
+		}
+		after := *before
+		after.DisplayName = strings.TrimSpace(req.DisplayName)
+		after.Role = req.Role
+		after.Disabled = req.Disabled
+
+		if err := um.keepLastAdmin(ctx, txRepo, before, &after); err != nil {
+			return err
+		}
+		if err := txRepo.UpdateUser(ctx, after); err != nil {
+			return err
+		}
+		revoke = before.Role != after.Role || after.Disabled
+		return writeAudit(ctx, tx, auditUserUpdate, auditEntityUser, req.ID, userSnapshot(before), userSnapshot(&after))
+	})
+	if err != nil {
+		log.Error("failed to update user", logger.ErrorType(err))
+		return "", err
+	}
+	if revoke {
+		um.sessions.RevokeUser(req.ID)
+	}
+	return "user updated", nil
+}
+
+// keepLastAdmin 拒绝停用或降级最后一个可用的管理员
+func (um *UserManager) keepLastAdmin(ctx context.Context, repo repository.UserRepository, before *entity.User, after *entity.User) error {
+	wasAdmin := before.Role == RoleAdmin && !before.Disabled
+	isAdmin := after.Role == RoleAdmin && !after.Disabled
+	if !wasAdmin || isAdmin {
+		return nil
+	}
+	n, err := repo.CountActiveUsers(ctx, RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if n <= 1 {
+		return errorx.Conflict("cannot disable or demote the last admin")
+	}
+	return nil
+}
+
+func (um *UserManager) ResetPassword(ctx context.Context, req *requestx.ResetPasswordRequest) (string, error) {
+	logger.InfoContext(ctx, "Resetting user password", logger.UInt("user_id", req.ID))
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		return "", err
+	}
+	err = dao.GetDBFromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txRepo := repository.NewUserRepository(dao.NewUserDao(tx))
+		if _, err := txRepo.GetUserByID(ctx, req.ID); err != nil {
+			if errors.Is(err, dao.ErrRecordNotFound) {
+				return errorx.NotFound(fmt.Sprintf("user [%d] not found", req.ID))
+			}
+			return err
+		}
+		if err := txRepo.UpdatePassword(ctx, req.ID, hash); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, auditUserResetPassword, auditEntityUser, req.ID, nil, nil)
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to reset password", logger.UInt("user_id", req.ID), logger.ErrorType(err))
+		return "", err
+	}
+	um.sessions.RevokeUser(req.ID)
+	return "password reset", nil
+}
+
+func (um *UserManager) RegisterRoute(d *dispatcher.Dispatcher) {
+	dispatcher.RegisterTyped(d, "auth:login", um.Login)
+	dispatcher.RegisterNoReq(d, "auth:logout", um.Logout)
+	dispatcher.RegisterNoReq(d, "auth:current_user", um.CurrentUser)
+	dispatcher.RegisterTyped(d, "auth:change_password", um.ChangePassword)
+	dispatcher.RegisterTyped(d, "user_manager:create_user", um.CreateUser)
+	dispatcher.RegisterTyped(d, "user_manager:get_user_list", um.GetUserList)
+	dispatcher.RegisterTyped(d, "user_manager:update_user", um.UpdateUser)
+	dispatcher.RegisterTyped(d, "user_manager:reset_password", um.ResetPassword)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"teaching_manage/dao"
+	"teaching_manage/pkg/auth"
+	"teaching_manage/pkg/dispatcher"
+	"teaching_manage/pkg/errorx"
+	"teaching_manage/repository"
+	requestx "teaching_manage/service/request"
+)
+
+func newTestUserManager(t *testing.T) *UserManager {
+	t.Helper()
+	db := openTestDB(t)
+	um := NewUserManager(repository.NewUserRepository(dao.NewUserDao(db)))
+	if _, err := um.CreateUser(context.Background(), &requestx.CreateUserRequest{
+		Username: "clerk", Password: "correct-password", Role: RoleAdmin,
+	}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	return um
+}
+
+func httpContext(addr string, token string) context.Context {
+	return dispatcher.WithCaller(context.Background(), dispatcher.Caller{Transport: dispatcher.TransportHTTP, Addr: addr, Token: token})
+}
+
+func TestLoginThrottle(t *testing.T) {
+	um := newTestUserManager(t)
+	attacker := httpContext("10.0.0.9:5000", "")
+	login := func(ctx context.Context, password string) error {
+		_, err := um.Login(ctx, &requestx.LoginRequest{Username: "clerk", Password: password})
+		return err
+	}
+
+	for i := 0; i < loginMaxFailures; i++ {
+		if err := login(attacker, "wrong-password"); errorx.KindOf(err) != errorx.KindUnauthenticated {
+			t.Fatalf("attempt %d error = %v, want unauthenticated", i+1, err)
+		}
+	}
+	// 锁定期间正确的密码也被拒绝，同一地址的其他端口与大小写不同的用户名同样受限
+	if err := login(attacker, "correct-password"); err == nil {
+		t.Fatalf("login succeeded while throttled")
+	}
+	if _, err := um.Login(httpContext("10.0.0.9:6000", ""), &requestx.LoginRequest{Username: "CLERK", Password: "correct-password"}); err == nil {
+		t.Fatalf("login from another port succeeded while throttled")
+	}
+	// 其他地址与桌面窗口不受影响
+	if err := login(httpContext("10.0.0.10:5000", ""), "correct-password"); err != nil {
+		t.Fatalf("login from another address error = %v", err)
+	}
+	if err := login(context.Background(), "correct-password"); err != nil {
+		t.Fatalf("desktop login error = %v", err)
+	}
+}
+
+func TestLoginSuccessResetsThrottle(t *testing.T) {
+	um := newTestUserManager(t)
+	ctx := httpContext("10.0.0.9:5000", "")
+	for round := 0; round < 3; round++ {
+		for i := 0; i < loginMaxFailures-1; i++ {
+			um.Login(ctx, &requestx.LoginRequest{Username: "clerk", Password: "wrong-password"})
+		}
+		if _, err := um.Login(ctx, &requestx.LoginRequest{Username: "clerk", Password: "correct-password"}); err != nil {
+			t.Fatalf("round %d: login error = %v", round, err)
+		}
+	}
+}
+
+func TestChangePasswordRevokesOtherSessions(t *testing.T) {
+	um := newTestUserManager(t)
+	login := func(ctx context.Context) string {
+		resp, err := um.Login(ctx, &requestx.LoginRequest{Username: "clerk", Password: "correct-password"})
+		if err != nil {
+			t.Fatalf("Login() error = %v", err)
+		}
+		return resp.Token
+	}
+	current := login(httpContext("10.0.0.1:5000", ""))
+	other := login(httpContext("10.0.0.2:5000", ""))
+	desktop := login(context.Background())
+
+	p, ok := um.sessions.Get(current)
+	if !ok {
+		t.Fatalf("current session not found")
+	}
+	ctx := auth.WithPrincipal(httpContext("10.0.0.1:5000", current), p)
+	if _, err := um.ChangePassword(ctx, &requestx.ChangePasswordRequest{OldPassword: "correct-password", NewPassword: "new-password"}); err != nil {
+		t.Fatalf("ChangePassword() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{name: "current session", token: current, want: true},
+		{name: "other http session", token: other, want: false},
+		{name: "desktop session", token: desktop, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := um.sessions.Get(tt.token); ok != tt.want {
+				t.Fatalf("session valid = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
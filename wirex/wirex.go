@@ -3,6 +3,7 @@ package wirex
 import (
 	"os"
 	"teaching_manage/dao"
+	"teaching_manage/pkg/config"
 	"teaching_manage/pkg/logger"
 
 	"go.uber.org/zap"
@@ -11,22 +12,34 @@ import (
 	"gorm.io/gorm"
 )
 
-func NewGormDB() (*gorm.DB, error) {
-	err := dao.InitDB("./data/teaching_manage.db")
+func NewGormDB(cfg config.DatabaseConfig, backup config.BackupConfig) (*gorm.DB, error) {
+	err := dao.InitDB(cfg.Path, backup.Dir)
 	if err != nil {
 		return nil, err
 	}
 	return dao.GetDB(), nil
 }
 
-func InitLogger() logger.Logger {
+func InitLogger(cfg config.LogConfig) (logger.Logger, error) {
+	level, err := zapcore.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
 	// 实现按文件大小切割日志
 	w := zapcore.AddSync(&lumberjack.Logger{
-		Filename:   "logs/teaching_manage.log",
-		MaxSize:    10, // megabytes
-		MaxBackups: 3,
+		Filename:   cfg.FilePath(),
+		MaxSize:    cfg.MaxSizeMB, // megabytes
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAgeDays,
+		Compress:   cfg.Compress,
 	})
 
+	ws := w
+	if cfg.Stdout {
+		ws = zapcore.NewMultiWriteSyncer(zapcore.AddSync(os.Stdout), w)
+	}
+
 	// 配置编码器
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
@@ -34,10 +47,10 @@ func InitLogger() logger.Logger {
 	// 创建核心
 	core := zapcore.NewCore(
 		zapcore.NewJSONEncoder(encoderConfig),
-		zapcore.NewMultiWriteSyncer(zapcore.AddSync(os.Stdout), w),
-		zap.DebugLevel,
+		ws,
+		level,
 	)
 
 	l := zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
-	return logger.NewZapLogger(l)
+	return logger.NewZapLogger(l), nil
 }